@@ -0,0 +1,18 @@
+import json
+import sqlite3
+from domain.device import Device
+
+class SQLiteDeviceRepository:
+    """Reads the devices table laber keeps in a SQLite file (laber devicedb)."""
+
+    def __init__(self, path):
+        self.path = path
+
+    def get_devices(self):
+        devices = []
+        with sqlite3.connect(self.path) as conn:
+            cursor = conn.execute("SELECT hostname, ip_address, loopback_ip, username, password, infrastructure_interfaces FROM devices ORDER BY hostname")
+            for row in cursor.fetchall():
+                # infrastructure_interfaces is a JSON array where Postgres has text[]
+                devices.append(Device(*row[:5], json.loads(row[5])))
+        return devices
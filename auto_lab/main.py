@@ -1,17 +1,24 @@
+import os
 import psycopg2
 from jinja2 import Environment, FileSystemLoader
 from application.configuration_service import ConfigurationService
 from infrastructure.postgres_device_repository import PostgresDeviceRepository
+from infrastructure.sqlite_device_repository import SQLiteDeviceRepository
 from infrastructure.arista_device_connector import AristaDeviceConnector
 
 def main():
-    db_connection = psycopg2.connect(
-        host="localhost",
-        database="device_inventory",
-        user="lab",
-        password="password"
-    )
-    device_repository = PostgresDeviceRepository(db_connection)
+    # DEVICE_DB points at the SQLite file laber devicedb manages; without it
+    # the inventory comes from Postgres as before
+    if os.environ.get("DEVICE_DB"):
+        device_repository = SQLiteDeviceRepository(os.environ["DEVICE_DB"])
+    else:
+        db_connection = psycopg2.connect(
+            host="localhost",
+            database="device_inventory",
+            user="lab",
+            password="password"
+        )
+        device_repository = PostgresDeviceRepository(db_connection)
     template_env = Environment(loader=FileSystemLoader("templates"))
     device_connector = AristaDeviceConnector()
     configuration_service = ConfigurationService(template_env, device_repository, device_connector)
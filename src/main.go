@@ -7,38 +7,44 @@ import (
 	"embed"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/montybeatnik/arista-lab/laber/pkgs/addressing"
 	"github.com/montybeatnik/arista-lab/laber/pkgs/arista"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/consistency"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devicedb"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eoslint"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/fabric"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/hosts"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/ipam"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/logging"
 	"github.com/montybeatnik/arista-lab/laber/pkgs/renderer"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/services"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/textdiff"
 )
 
 type InspectResult map[string][]ContainerInfo
 
-type ContainerInfo struct {
-	LabName     string `json:"lab_name"`
-	LabPath     string `json:"labPath"`
-	AbsLabPath  string `json:"absLabPath"`
-	Name        string `json:"name"`
-	ContainerID string `json:"container_id"`
-	Image       string `json:"image"`
-	Kind        string `json:"kind"`
-	State       string `json:"state"`
-	Status      string `json:"status"`
-	IPv4        string `json:"ipv4_address"`
-	IPv6        string `json:"ipv6_address"`
-	Owner       string `json:"owner"`
-}
+// ContainerInfo is a lab container from containerlab inspect.
+type ContainerInfo = clab.Container
 
 // ======= Config =======
 
@@ -48,6 +54,20 @@ type serverCfg struct {
 }
 
 func (c serverCfg) sanitizeLabPath(p string) (string, error) {
+	abs, err := c.labFile(p)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil || info.IsDir() {
+		return "", errors.New("lab file not found")
+	}
+	return abs, nil
+}
+
+// labFile resolves p against basedir like sanitizeLabPath, for files that
+// need not exist yet.
+func (c serverCfg) labFile(p string) (string, error) {
 	if p == "" {
 		return "", errors.New("lab file required")
 	}
@@ -62,10 +82,6 @@ func (c serverCfg) sanitizeLabPath(p string) (string, error) {
 	if abs != baseAbs && !strings.HasPrefix(abs, baseAbs+string(os.PathSeparator)) {
 		return "", errors.New("lab file must be under basedir")
 	}
-	info, err := os.Stat(abs)
-	if err != nil || info.IsDir() {
-		return "", errors.New("lab file not found")
-	}
 	return abs, nil
 }
 
@@ -104,17 +120,63 @@ func indexHandler(cfg serverCfg, t *template.Template) http.HandlerFunc {
 }
 
 type inspectReq struct {
-	Lab        string `json:"lab"`
-	UseSudo    bool   `json:"sudo"`
-	TimeoutSec int    `json:"timeoutSec"`
+	Lab          string `json:"lab"`
+	UseSudo      bool   `json:"sudo"`
+	TimeoutSec   int    `json:"timeoutSec"`
+	Bootstrap    bool   `json:"bootstrap"` // enable eAPI through docker exec where missing
+	BootstrapVRF string `json:"bootstrapVrf"`
+	User         string `json:"user"`
+	Pass         string `json:"pass"`
+	Inventory    string `json:"inventory"` // defaults to inventory.json under basedir
+	Selector     string `json:"selector"`  // inventory selector for the nodes to bootstrap
 }
 
 type inspectResp struct {
-	OK      bool            `json:"ok"`
-	Error   string          `json:"error,omitempty"`
-	LabKey  string          `json:"labKey,omitempty"`
-	Nodes   []ContainerInfo `json:"nodes,omitempty"`
-	RawJSON json.RawMessage `json:"rawJson,omitempty"`
+	OK        bool            `json:"ok"`
+	Error     string          `json:"error,omitempty"`
+	LabKey    string          `json:"labKey,omitempty"`
+	Nodes     []ContainerInfo `json:"nodes,omitempty"`
+	Bootstrap []nodeBootstrap `json:"bootstrap,omitempty"`
+	RawJSON   json.RawMessage `json:"rawJson,omitempty"`
+}
+
+type nodeBootstrap struct {
+	Name   string                 `json:"name"`
+	Result arista.BootstrapResult `json:"result"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// bootstrapNode enables eAPI on n through docker exec when its startup
+// config left it out, then waits for eAPI to answer on the mgmt address.
+func bootstrapNode(ctx context.Context, log *slog.Logger, n ContainerInfo, user, pass, vrf string, useSudo bool) (arista.BootstrapResult, error) {
+	cli := arista.WithLogging(arista.NewDockerClient(n.Name, useSudo).WithContext(ctx), log.With("transport", "docker"))
+	probe := arista.WithLogging(arista.NewEosClient("https://"+cidrIP(n.IPv4)+"/command-api").WithCreds(user, pass).WithContext(ctx), log.With("transport", "eapi"))
+	res, err := arista.Bootstrap(ctx, cli, probe, arista.BootstrapOptions{VRF: vrf, User: user, Password: pass})
+	if err != nil {
+		log.Warn("eAPI bootstrap failed", "err", err)
+	} else {
+		log.Info("eAPI bootstrap", "already_enabled", res.AlreadyEnabled, "user_created", res.UserCreated)
+	}
+	return res, err
+}
+
+// nodeLogger tags log records with the request ID and the node they are about.
+func nodeLogger(ctx context.Context, n ContainerInfo) *slog.Logger {
+	return logging.FromContext(ctx).With("device", n.Name, "mgmt_ip", cidrIP(n.IPv4))
+}
+
+// bootstrapDetail summarises a bootstrap result for humans.
+func bootstrapDetail(res arista.BootstrapResult) string {
+	var parts []string
+	if res.AlreadyEnabled {
+		parts = append(parts, "eAPI already enabled")
+	} else {
+		parts = append(parts, "enabled eAPI")
+	}
+	if res.UserCreated {
+		parts = append(parts, "created API user")
+	}
+	return strings.Join(parts, ", ")
 }
 
 func runInspect(ctx context.Context, labPath string, useSudo bool) ([]byte, error) {
@@ -122,9 +184,12 @@ func runInspect(ctx context.Context, labPath string, useSudo bool) ([]byte, erro
 	if useSudo {
 		args = append([]string{"sudo", "-n"}, args...)
 	}
+	start := time.Now()
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	cmd.Stderr = os.Stderr
-	return cmd.Output()
+	out, err := cmd.Output()
+	logging.FromContext(ctx).Debug("containerlab inspect", "lab", labPath, "sudo", useSudo, "duration", time.Since(start), "err", err)
+	return out, err
 }
 
 func inspectHandler(cfg serverCfg) http.HandlerFunc {
@@ -158,68 +223,1213 @@ func inspectHandler(cfg serverCfg) http.HandlerFunc {
 
 		out, err := runInspect(ctx, labAbs, req.UseSudo)
 		if err != nil {
+			logging.FromContext(r.Context()).Warn("inspect failed", "lab", labAbs, "err", err)
 			writeJSON(w, http.StatusBadRequest, inspectResp{OK: false, Error: "inspect failed: " + err.Error()})
 			return
 		}
 
-		var parsed InspectResult
-		if err := json.Unmarshal(out, &parsed); err != nil {
-			writeJSON(w, http.StatusInternalServerError, inspectResp{OK: false, Error: "parse failed: " + err.Error()})
-			return
+		var parsed InspectResult
+		if err := json.Unmarshal(out, &parsed); err != nil {
+			writeJSON(w, http.StatusInternalServerError, inspectResp{OK: false, Error: "parse failed: " + err.Error()})
+			return
+		}
+
+		var key string
+		var nodes []ContainerInfo
+		for k, v := range parsed {
+			key, nodes = k, v
+			break
+		}
+
+		var boots []nodeBootstrap
+		if req.Bootstrap {
+			inv, err := cfg.optionalInventory(req.Inventory)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, inspectResp{OK: false, Error: err.Error()})
+				return
+			}
+			ceos, _ := ceosNodesFromInspect(out)
+			if ceos, err = selectNodes(inv, req.Selector, ceos); err != nil {
+				writeJSON(w, http.StatusBadRequest, inspectResp{OK: false, Error: err.Error()})
+				return
+			}
+			boots = make([]nodeBootstrap, len(ceos))
+			sem := make(chan struct{}, 5)
+			var wg sync.WaitGroup
+			for i, n := range ceos {
+				wg.Add(1)
+				go func(i int, n ContainerInfo) {
+					defer wg.Done()
+					sem <- struct{}{}
+					defer func() { <-sem }()
+
+					user, pass := nodeCreds(inv, n, req.User, req.Pass)
+					res, err := bootstrapNode(ctx, nodeLogger(r.Context(), n), n, user, pass, req.BootstrapVRF, req.UseSudo)
+					boots[i] = nodeBootstrap{Name: n.Name, Result: res}
+					if err != nil {
+						boots[i].Error = err.Error()
+					}
+				}(i, n)
+			}
+			wg.Wait()
+		}
+
+		writeJSON(w, http.StatusOK, inspectResp{
+			OK:        true,
+			LabKey:    key,
+			Nodes:     nodes,
+			Bootstrap: boots,
+			RawJSON:   out,
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// statusWriter remembers the status code written by a handler.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// withRequestLog tags every request with an ID (taken from X-Request-ID
+// when the caller sent one) and logs it once it has been served.
+func withRequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = logging.NewRequestID()
+		}
+		ctx := logging.WithRequestID(r.Context(), id)
+		w.Header().Set("X-Request-ID", id)
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		logging.FromContext(ctx).Info("http request",
+			"method", r.Method, "path", r.URL.Path, "status", sw.code, "duration", time.Since(start))
+	})
+}
+
+type logLevelMsg struct {
+	Level string `json:"level"`
+	Error string `json:"error,omitempty"`
+}
+
+// logLevelHandler reports the log level on GET and changes it on POST,
+// e.g. {"level": "debug"} to start dumping (redacted) eAPI payloads.
+func logLevelHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut:
+			var req logLevelMsg
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, logLevelMsg{Error: "bad JSON: " + err.Error()})
+				return
+			}
+			if err := logging.SetLevel(req.Level); err != nil {
+				writeJSON(w, http.StatusBadRequest, logLevelMsg{Error: err.Error()})
+				return
+			}
+			logging.FromContext(r.Context()).Info("log level changed", "level", logging.Level.Level().String())
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, logLevelMsg{Level: strings.ToLower(logging.Level.Level().String())})
+	}
+}
+
+type runCmdsReq struct {
+	Lab        string   `json:"lab"`
+	UseSudo    bool     `json:"sudo"`
+	TimeoutSec int      `json:"timeoutSec"`
+	User       string   `json:"user"`
+	Pass       string   `json:"pass"`
+	Format     string   `json:"format"`
+	Cmds       []string `json:"cmds"`
+	Transport  string   `json:"transport"` // auto|eapi|ssh|docker
+	Inventory  string   `json:"inventory"` // defaults to inventory.json under basedir
+	Selector   string   `json:"selector"`  // e.g. "role=leaf,tag=rack1"; every node when empty
+}
+
+type cmdResult struct {
+	Name      string `json:"name"`
+	IP        string `json:"ip"`
+	Kind      string `json:"kind"`
+	Transport string `json:"transport"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	Body      string `json:"body,omitempty"`
+}
+
+type runCmdsResp struct {
+	OK      bool        `json:"ok"`
+	Error   string      `json:"error,omitempty"`
+	Results []cmdResult `json:"results,omitempty"`
+}
+
+// nodeClient picks the transport used to reach a node. "auto" is eAPI with
+// SSH fallback; "docker" goes through the container and needs no mgmt IP.
+func nodeClient(log *slog.Logger, transport string, n ContainerInfo, user, pass string, useSudo bool) (arista.Client, error) {
+	ip := cidrIP(n.IPv4)
+	var c arista.Client
+	switch transport {
+	case "", "auto":
+		c = arista.NewClient(ip, user, pass)
+	case "eapi":
+		c = arista.NewEosClient("https://"+ip+"/command-api").WithCreds(user, pass)
+	case "ssh":
+		c = arista.NewSSHClient(ip, arista.SSHConfig{User: user, Password: pass})
+	case "docker":
+		c = arista.NewDockerClient(n.Name, useSudo)
+	default:
+		return nil, fmt.Errorf("unknown transport %q", transport)
+	}
+	return arista.WithLogging(c, log.With("transport", transport)), nil
+}
+
+// labInventory loads the inventory a request names, inventory.json under
+// basedir by default.
+func (c serverCfg) labInventory(path string) (*inventory.Inventory, error) {
+	if path == "" {
+		path = "inventory.json"
+	}
+	p, err := c.sanitizeLabPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("inventory: %w", err)
+	}
+	return inventory.LoadFile(p)
+}
+
+// optionalInventory is labInventory for handlers that can do without one:
+// when the default inventory doesn't exist it returns nil, and the handler
+// acts on every cEOS node containerlab reports.
+func (c serverCfg) optionalInventory(path string) (*inventory.Inventory, error) {
+	if path == "" {
+		// sanitizeLabPath doesn't say why it refused, so look first
+		if _, err := os.Stat(filepath.Join(c.BaseDir, "inventory.json")); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	return c.labInventory(path)
+}
+
+// selectNodes keeps the lab nodes whose inventory device matches selector.
+// Nodes missing from the inventory are dropped, so the inventory decides
+// what the handlers touch.
+func selectNodes(inv *inventory.Inventory, selector string, nodes []ContainerInfo) ([]ContainerInfo, error) {
+	if inv == nil {
+		if strings.TrimSpace(selector) != "" {
+			return nil, errors.New("a selector needs an inventory")
+		}
+		return nodes, nil
+	}
+	devs, err := inv.Select(selector)
+	if err != nil {
+		return nil, err
+	}
+	want := map[string]bool{}
+	for _, d := range devs {
+		want[d.Name] = true
+	}
+	var out []ContainerInfo
+	for _, n := range nodes {
+		if want[clab.NodeName(n.LabName, n.Name)] {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// nodeCreds returns the login for n: the request's when it has one, else
+// the credentials n's inventory entry refers to.
+func nodeCreds(inv *inventory.Inventory, n ContainerInfo, user, pass string) (string, string) {
+	if user != "" || inv == nil {
+		return user, pass
+	}
+	d, ok := inv.Get(clab.NodeName(n.LabName, n.Name))
+	if !ok {
+		return user, pass
+	}
+	c, err := inv.Credential(d)
+	if err != nil {
+		return user, pass
+	}
+	return c.Username, c.Password
+}
+
+// runCmdHandler runs the requested commands on the cEOS nodes of the lab
+// that the inventory selector picks, or on all of them.
+// By default nodes are reached over eAPI and, when eAPI is refused, over
+// SSH; transport "docker" runs them through docker exec instead.
+func runCmdHandler(cfg serverCfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req runCmdsReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, runCmdsResp{OK: false, Error: "bad JSON: " + err.Error()})
+			return
+		}
+		cmds := onlyNonEmpty(req.Cmds)
+		if len(cmds) == 0 {
+			writeJSON(w, http.StatusBadRequest, runCmdsResp{OK: false, Error: "no commands"})
+			return
+		}
+		if req.Format == "" {
+			req.Format = "json"
+		}
+		if _, err := nodeClient(slog.Default(), req.Transport, ContainerInfo{}, "", "", false); err != nil {
+			writeJSON(w, http.StatusBadRequest, runCmdsResp{OK: false, Error: err.Error()})
+			return
+		}
+		labAbs, err := cfg.sanitizeLabPath(req.Lab)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, runCmdsResp{OK: false, Error: err.Error()})
+			return
+		}
+		inv, err := cfg.optionalInventory(req.Inventory)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, runCmdsResp{OK: false, Error: err.Error()})
+			return
+		}
+
+		tout := time.Duration(req.TimeoutSec) * time.Second
+		if tout <= 0 || tout > 60*time.Second {
+			tout = 15 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), tout)
+		defer cancel()
+		out, err := runInspect(ctx, labAbs, req.UseSudo)
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("inspect failed", "lab", labAbs, "err", err)
+			writeJSON(w, http.StatusBadRequest, runCmdsResp{OK: false, Error: "inspect failed: " + err.Error()})
+			return
+		}
+		// docker exec doesn't need a management address
+		nodes, err := ceosNodes(out, req.Transport != "docker")
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, runCmdsResp{OK: false, Error: "parse inspect: " + err.Error()})
+			return
+		}
+		if nodes, err = selectNodes(inv, req.Selector, nodes); err != nil {
+			writeJSON(w, http.StatusBadRequest, runCmdsResp{OK: false, Error: err.Error()})
+			return
+		}
+
+		body, err := renderer.Render("eapi_payload", renderer.PayloadData{
+			Method:  "runCmds",
+			Version: 1,
+			Format:  req.Format,
+			Cmds:    cmds,
+			ID:      1,
+		})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, runCmdsResp{OK: false, Error: "render payload: " + err.Error()})
+			return
+		}
+
+		results := make([]cmdResult, len(nodes))
+		sem := make(chan struct{}, 5)
+		var wg sync.WaitGroup
+		for i, n := range nodes {
+			wg.Add(1)
+			go func(i int, n ContainerInfo) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				res := cmdResult{Name: n.Name, IP: cidrIP(n.IPv4), Kind: n.Kind, Transport: req.Transport}
+				if res.Transport == "" {
+					res.Transport = "auto"
+				}
+				user, pass := nodeCreds(inv, n, req.User, req.Pass)
+				client, _ := nodeClient(nodeLogger(r.Context(), n), req.Transport, n, user, pass, req.UseSudo)
+				var raw json.RawMessage
+				if err := client.Run(body, &raw); err != nil {
+					res.Error = err.Error()
+				} else {
+					res.OK, res.Body = true, string(raw)
+				}
+				results[i] = res
+			}(i, n)
+		}
+		wg.Wait()
+		writeJSON(w, http.StatusOK, runCmdsResp{OK: true, Results: results})
+	}
+}
+
+// ----- Derived inventory -----
+
+type inventoryReq struct {
+	Lab        string `json:"lab"`
+	UseSudo    bool   `json:"sudo"`
+	TimeoutSec int    `json:"timeoutSec"`
+	Live       bool   `json:"live"` // add container names, state and mgmt addresses from inspect
+}
+
+type inventoryResp struct {
+	OK           bool                 `json:"ok"`
+	Error        string               `json:"error,omitempty"`
+	InspectError string               `json:"inspectError,omitempty"`
+	Inventory    *inventory.Inventory `json:"inventory,omitempty"`
+}
+
+// deriveInventory builds the inventory of the lab at labAbs; with live it
+// also runs containerlab inspect. An inspect failure is returned separately
+// since the topology and configs alone still make an inventory.
+func deriveInventory(ctx context.Context, labAbs string, live, useSudo bool) (inv *inventory.Inventory, inspectErr, err error) {
+	topo, err := clab.LoadTopology(labAbs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var containers []clab.Container
+	if live {
+		out, err := runInspect(ctx, labAbs, useSudo)
+		if err == nil {
+			containers, err = clab.ParseInspect(out)
+		}
+		inspectErr = err
+	}
+	inv, err = inventory.Derive(topo, filepath.Dir(labAbs), containers)
+	return inv, inspectErr, err
+}
+
+// inventoryHandler returns the inventory derived from the lab's topology
+// file, its startup configs and, with live, containerlab inspect.
+func inventoryHandler(cfg serverCfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req inventoryReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, inventoryResp{OK: false, Error: "bad JSON: " + err.Error()})
+			return
+		}
+		labAbs, err := cfg.sanitizeLabPath(req.Lab)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, inventoryResp{OK: false, Error: err.Error()})
+			return
+		}
+		tout := time.Duration(req.TimeoutSec) * time.Second
+		if tout <= 0 || tout > 60*time.Second {
+			tout = 15 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), tout)
+		defer cancel()
+
+		inv, inspectErr, err := deriveInventory(ctx, labAbs, req.Live, req.UseSudo)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, inventoryResp{OK: false, Error: err.Error()})
+			return
+		}
+		resp := inventoryResp{OK: true, Inventory: inv}
+		if inspectErr != nil {
+			logging.FromContext(r.Context()).Warn("inspect failed", "lab", labAbs, "err", inspectErr)
+			resp.InspectError = inspectErr.Error()
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ----- Feature snippets -----
+
+type featuresReq struct {
+	Lab        string   `json:"lab"`
+	UseSudo    bool     `json:"sudo"`
+	TimeoutSec int      `json:"timeoutSec"`
+	User       string   `json:"user"`
+	Pass       string   `json:"pass"`
+	Transport  string   `json:"transport"` // auto|eapi|ssh|docker
+	Inventory  string   `json:"inventory"` // defaults to inventory.json under basedir
+	Features   []string `json:"features"`  // e.g. ["isis", "mpls"]
+	Nodes      []string `json:"nodes"`     // device names; every routed device when empty
+	Selector   string   `json:"selector"`  // inventory selector, narrowed further by nodes
+	DryRun     bool     `json:"dryRun"`    // render only
+	Save       bool     `json:"save"`      // copy running-config startup-config afterwards
+}
+
+type featureResult struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport,omitempty"`
+	OK        bool   `json:"ok"`
+	Applied   bool   `json:"applied"`
+	Error     string `json:"error,omitempty"`
+	Config    string `json:"config,omitempty"`
+}
+
+type featuresResp struct {
+	OK       bool            `json:"ok"`
+	Error    string          `json:"error,omitempty"`
+	Features []string        `json:"features,omitempty"`
+	Results  []featureResult `json:"results,omitempty"`
+}
+
+// featuresHandler lists the feature snippets (GET) or renders the requested
+// ones for inventory devices and pushes them to the matching lab nodes
+// (POST).
+func featuresHandler(cfg serverCfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, featuresResp{OK: true, Features: renderer.Features()})
+			return
+		case http.MethodPost:
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req featuresReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, featuresResp{OK: false, Error: "bad JSON: " + err.Error()})
+			return
+		}
+		features := onlyNonEmpty(req.Features)
+		if len(features) == 0 {
+			writeJSON(w, http.StatusBadRequest, featuresResp{OK: false, Error: "no features"})
+			return
+		}
+		if _, err := nodeClient(slog.Default(), req.Transport, ContainerInfo{}, "", "", false); err != nil {
+			writeJSON(w, http.StatusBadRequest, featuresResp{OK: false, Error: err.Error()})
+			return
+		}
+		inv, err := cfg.labInventory(req.Inventory)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, featuresResp{OK: false, Error: err.Error()})
+			return
+		}
+		devs, err := inv.Select(req.Selector)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, featuresResp{OK: false, Error: err.Error()})
+			return
+		}
+
+		want := map[string]bool{}
+		for _, n := range onlyNonEmpty(req.Nodes) {
+			want[n] = true
+		}
+		var results []featureResult
+		var targets []inventory.Device
+		for _, d := range devs {
+			if len(want) > 0 && !want[d.Name] {
+				continue
+			}
+			if len(want) == 0 && d.Loopback(0) == "" {
+				continue // hosts
+			}
+			delete(want, d.Name)
+			out, err := renderer.RenderFeatures(d.Device, features...)
+			res := featureResult{Name: d.Name, Config: string(out), OK: err == nil}
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				targets = append(targets, d)
+			}
+			results = append(results, res)
+		}
+		for n := range want {
+			results = append(results, featureResult{Name: n, Error: "not in inventory"})
+		}
+		if req.DryRun || len(targets) == 0 {
+			writeJSON(w, http.StatusOK, featuresResp{OK: true, Results: results})
+			return
+		}
+
+		labAbs, err := cfg.sanitizeLabPath(req.Lab)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, featuresResp{OK: false, Error: err.Error()})
+			return
+		}
+		tout := time.Duration(req.TimeoutSec) * time.Second
+		if tout <= 0 || tout > 120*time.Second {
+			tout = 30 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), tout)
+		defer cancel()
+		out, err := runInspect(ctx, labAbs, req.UseSudo)
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("inspect failed", "lab", labAbs, "err", err)
+			writeJSON(w, http.StatusBadRequest, featuresResp{OK: false, Error: "inspect failed: " + err.Error()})
+			return
+		}
+		nodes, err := ceosNodes(out, req.Transport != "docker")
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, featuresResp{OK: false, Error: "parse inspect: " + err.Error()})
+			return
+		}
+		byHost := map[string]ContainerInfo{}
+		for _, n := range nodes {
+			byHost[clab.NodeName(n.LabName, n.Name)] = n
+		}
+
+		transport := req.Transport
+		if transport == "" {
+			transport = "auto"
+		}
+		sem := make(chan struct{}, 5)
+		var wg sync.WaitGroup
+		for i := range results {
+			res := &results[i]
+			if !res.OK {
+				continue
+			}
+			n, ok := byHost[res.Name]
+			if !ok {
+				res.OK, res.Error = false, "no running cEOS node"
+				continue
+			}
+			res.Transport = transport
+			wg.Add(1)
+			go func(n ContainerInfo) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				user, pass := nodeCreds(inv, n, req.User, req.Pass)
+				client, _ := nodeClient(nodeLogger(r.Context(), n), req.Transport, n, user, pass, req.UseSudo)
+				if err := arista.Configure(client, arista.ConfigLines(res.Config), req.Save); err != nil {
+					res.OK, res.Error = false, err.Error()
+					return
+				}
+				res.Applied = true
+			}(n)
+		}
+		wg.Wait()
+		writeJSON(w, http.StatusOK, featuresResp{OK: true, Results: results})
+	}
+}
+
+// ----- Tenant services -----
+
+type servicesReq struct {
+	Lab        string `json:"lab"`
+	UseSudo    bool   `json:"sudo"`
+	TimeoutSec int    `json:"timeoutSec"`
+	User       string `json:"user"`
+	Pass       string `json:"pass"`
+	Transport  string `json:"transport"` // auto|eapi|ssh|docker
+	Inventory  string `json:"inventory"` // defaults to inventory.json under basedir
+	Catalog    string `json:"catalog"`   // defaults to services.yml under basedir
+	State      string `json:"state"`     // last applied catalog, defaults to services.state.yml
+	Teardown   bool   `json:"teardown"`  // remove every applied service instead
+	DryRun     bool   `json:"dryRun"`    // compute the deltas only
+	Save       bool   `json:"save"`      // copy running-config startup-config afterwards
+}
+
+type serviceResult struct {
+	services.Change
+	Transport string `json:"transport,omitempty"`
+	OK        bool   `json:"ok"`
+	Applied   bool   `json:"applied"`
+	Error     string `json:"error,omitempty"`
+}
+
+type servicesResp struct {
+	OK      bool            `json:"ok"`
+	Error   string          `json:"error,omitempty"`
+	Results []serviceResult `json:"results,omitempty"`
+	// Saved is set once every leaf took its delta and the catalog was
+	// recorded as applied.
+	Saved bool `json:"saved,omitempty"`
+}
+
+// servicesHandler moves the leaves from the last applied services catalog
+// to the current one: it renders each leaf's config delta, pushes it and
+// records the catalog as applied when every leaf took it. Services dropped
+// from the catalog get their cleanup config the same way.
+func servicesHandler(cfg serverCfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req servicesReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, servicesResp{OK: false, Error: "bad JSON: " + err.Error()})
+			return
+		}
+		fail := func(err error) {
+			writeJSON(w, http.StatusBadRequest, servicesResp{OK: false, Error: err.Error()})
+		}
+		if _, err := nodeClient(slog.Default(), req.Transport, ContainerInfo{}, "", "", false); err != nil {
+			fail(err)
+			return
+		}
+		inv, err := cfg.labInventory(req.Inventory)
+		if err != nil {
+			fail(err)
+			return
+		}
+		plan, err := cfg.bgpPlan()
+		if err != nil {
+			fail(err)
+			return
+		}
+		if req.State == "" {
+			req.State = "services.state.yml"
+		}
+		if req.Catalog == "" {
+			req.Catalog = "services.yml"
+		}
+		statePath, err := cfg.labFile(req.State)
+		if err != nil {
+			fail(err)
+			return
+		}
+		applied, err := services.LoadFile(statePath)
+		if err != nil {
+			fail(err)
+			return
+		}
+		want := &services.Catalog{}
+		if !req.Teardown {
+			p, err := cfg.sanitizeLabPath(req.Catalog)
+			if err != nil {
+				fail(fmt.Errorf("services: %w", err))
+				return
+			}
+			if want, err = services.LoadFile(p); err != nil {
+				fail(err)
+				return
+			}
+		}
+		var devs []devices.Device
+		for _, d := range inv.Devices {
+			devs = append(devs, d.Device)
+		}
+		changes, err := services.Changes(devs, applied, want, plan)
+		if err != nil {
+			fail(err)
+			return
+		}
+		results := make([]serviceResult, len(changes))
+		for i, c := range changes {
+			results[i] = serviceResult{Change: c, OK: true}
+		}
+		if req.DryRun {
+			writeJSON(w, http.StatusOK, servicesResp{OK: true, Results: results})
+			return
+		}
+
+		if len(results) > 0 {
+			labAbs, err := cfg.sanitizeLabPath(req.Lab)
+			if err != nil {
+				fail(err)
+				return
+			}
+			tout := time.Duration(req.TimeoutSec) * time.Second
+			if tout <= 0 || tout > 120*time.Second {
+				tout = 30 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), tout)
+			defer cancel()
+			out, err := runInspect(ctx, labAbs, req.UseSudo)
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("inspect failed", "lab", labAbs, "err", err)
+				fail(fmt.Errorf("inspect failed: %w", err))
+				return
+			}
+			nodes, err := ceosNodes(out, req.Transport != "docker")
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, servicesResp{OK: false, Error: "parse inspect: " + err.Error()})
+				return
+			}
+			byHost := map[string]ContainerInfo{}
+			for _, n := range nodes {
+				byHost[clab.NodeName(n.LabName, n.Name)] = n
+			}
+			transport := req.Transport
+			if transport == "" {
+				transport = "auto"
+			}
+			sem := make(chan struct{}, 5)
+			var wg sync.WaitGroup
+			for i := range results {
+				res := &results[i]
+				n, ok := byHost[res.Leaf]
+				if !ok {
+					res.OK, res.Error = false, "no running cEOS node"
+					continue
+				}
+				res.Transport = transport
+				wg.Add(1)
+				go func(n ContainerInfo) {
+					defer wg.Done()
+					sem <- struct{}{}
+					defer func() { <-sem }()
+
+					user, pass := nodeCreds(inv, n, req.User, req.Pass)
+					client, _ := nodeClient(nodeLogger(r.Context(), n), req.Transport, n, user, pass, req.UseSudo)
+					if err := arista.Configure(client, res.Commands, req.Save); err != nil {
+						res.OK, res.Error = false, err.Error()
+						return
+					}
+					res.Applied = true
+				}(n)
+			}
+			wg.Wait()
+		}
+
+		resp := servicesResp{OK: true, Results: results}
+		for _, res := range results {
+			if !res.Applied {
+				// the next run pushes the same deltas again
+				writeJSON(w, http.StatusOK, resp)
+				return
+			}
+		}
+		if err := want.WriteFile(statePath); err != nil {
+			resp.Error = "record applied services: " + err.Error()
+		} else {
+			resp.Saved = true
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ----- Linux hosts -----
+
+type hostsReq struct {
+	Lab        string   `json:"lab"`
+	Hosts      []string `json:"hosts"` // all hosts of the lab when empty
+	UseSudo    bool     `json:"sudo"`
+	TimeoutSec int      `json:"timeoutSec"`
+	DryRun     bool     `json:"dryRun"` // list the commands only
+}
+
+type hostResult struct {
+	hosts.Host
+	Commands []string `json:"commands"`
+	OK       bool     `json:"ok"`
+	Applied  bool     `json:"applied"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type hostsResp struct {
+	OK      bool         `json:"ok"`
+	Error   string       `json:"error,omitempty"`
+	Results []hostResult `json:"results,omitempty"`
+}
+
+// hostsHandler gives the linux hosts of the lab the interfaces, VLANs, MTUs
+// and addresses the inventory has for them, over docker exec.
+func hostsHandler(cfg serverCfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req hostsReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, hostsResp{OK: false, Error: "bad JSON: " + err.Error()})
+			return
+		}
+		fail := func(err error) {
+			writeJSON(w, http.StatusBadRequest, hostsResp{OK: false, Error: err.Error()})
+		}
+		labAbs, err := cfg.sanitizeLabPath(req.Lab)
+		if err != nil {
+			fail(err)
+			return
+		}
+		tout := time.Duration(req.TimeoutSec) * time.Second
+		if tout <= 0 || tout > 120*time.Second {
+			tout = 30 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), tout)
+		defer cancel()
+
+		inv, _, err := deriveInventory(ctx, labAbs, false, false)
+		if err != nil {
+			fail(err)
+			return
+		}
+		hs, err := selectHosts(hosts.FromInventory(inv), req.Hosts)
+		if err != nil {
+			fail(err)
+			return
+		}
+		results := make([]hostResult, len(hs))
+		for i, h := range hs {
+			results[i] = hostResult{Host: h, OK: true}
+			for _, argv := range h.Commands() {
+				results[i].Commands = append(results[i].Commands, strings.Join(argv, " "))
+			}
+		}
+		if req.DryRun {
+			writeJSON(w, http.StatusOK, hostsResp{OK: true, Results: results})
+			return
+		}
+
+		dockerExec := func(ctx context.Context, container string, argv ...string) ([]byte, error) {
+			return clab.DockerExec(ctx, container, req.UseSudo, argv...)
+		}
+		sem := make(chan struct{}, 5)
+		var wg sync.WaitGroup
+		for i := range results {
+			res := &results[i]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				ran, err := hosts.Apply(ctx, res.Host, dockerExec)
+				res.Commands = ran
+				if err != nil {
+					logging.FromContext(r.Context()).Warn("host networking failed", "host", res.Name, "err", err)
+					res.OK, res.Error = false, err.Error()
+					return
+				}
+				res.Applied = true
+			}()
+		}
+		wg.Wait()
+		writeJSON(w, http.StatusOK, hostsResp{OK: true, Results: results})
+	}
+}
+
+// selectHosts keeps the hosts named in names, in the lab's order; no names
+// keeps them all.
+func selectHosts(all []hosts.Host, names []string) ([]hosts.Host, error) {
+	if len(names) == 0 {
+		return all, nil
+	}
+	for _, n := range names {
+		if !slices.ContainsFunc(all, func(h hosts.Host) bool { return h.Name == n }) {
+			return nil, fmt.Errorf("no host %q in the lab", n)
+		}
+	}
+	return slices.DeleteFunc(all, func(h hosts.Host) bool { return !slices.Contains(names, h.Name) }), nil
+}
+
+// bgpPlan is the BGP numbering of the fabric intent under basedir, or the
+// original lab's for labs that have none.
+func (c serverCfg) bgpPlan() (bgpplan.Plan, error) {
+	in, err := fabric.LoadFile(filepath.Join(c.BaseDir, "fabric.yml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return bgpplan.Default(), nil
+	}
+	if err != nil {
+		return bgpplan.Plan{}, err
+	}
+	return in.BGP, nil
+}
+
+// ----- Render preview -----
+
+type previewReq struct {
+	Lab        string `json:"lab"`
+	UseSudo    bool   `json:"sudo"`
+	TimeoutSec int    `json:"timeoutSec"`
+	User       string `json:"user"`
+	Pass       string `json:"pass"`
+	Transport  string `json:"transport"` // auto|eapi|ssh|docker
+	Inventory  string `json:"inventory"` // defaults to inventory.json under basedir
+	Device     string `json:"device"`    // one device name ...
+	Role       string `json:"role"`      // ... or every device with this role ...
+	Selector   string `json:"selector"`  // ... or an inventory selector
+	Template   string `json:"template"`  // "config" for the full startup config, or a feature name
+	Live       bool   `json:"live"`      // also diff against the running-config
+}
+
+type previewResult struct {
+	Name         string `json:"name"`
+	Rendered     string `json:"rendered,omitempty"`
+	Error        string `json:"error,omitempty"`
+	StartupFile  string `json:"startupFile,omitempty"`
+	StartupDiff  string `json:"startupDiff,omitempty"`
+	StartupError string `json:"startupError,omitempty"`
+	RunningDiff  string `json:"runningDiff,omitempty"`
+	RunningError string `json:"runningError,omitempty"`
+	// commands that turn the startup/running config into the rendered
+	// one, for full configs only
+	StartupRemediation []string `json:"startupRemediation,omitempty"`
+	RunningRemediation []string `json:"runningRemediation,omitempty"`
+}
+
+type previewResp struct {
+	OK        bool            `json:"ok"`
+	Error     string          `json:"error,omitempty"`
+	Templates []string        `json:"templates,omitempty"`
+	Results   []previewResult `json:"results,omitempty"`
+}
+
+// previewHandler renders a device's full config or a feature snippet and
+// diffs it against the startup-config under basedir and, with live, against
+// the running-config. Feature snippets are compared with only the sections
+// they touch. GET lists what can be previewed.
+func previewHandler(cfg serverCfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, previewResp{OK: true, Templates: append([]string{"config"}, renderer.Features()...)})
+			return
+		case http.MethodPost:
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req previewReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, previewResp{OK: false, Error: "bad JSON: " + err.Error()})
+			return
+		}
+		selector := req.Selector
+		switch {
+		case req.Device != "":
+			selector = "name=" + req.Device
+		case req.Role != "":
+			selector = "role=" + req.Role
+		case selector == "":
+			writeJSON(w, http.StatusBadRequest, previewResp{OK: false, Error: "device, role or selector required"})
+			return
+		}
+		if req.Template == "" {
+			req.Template = "config"
+		}
+		if _, err := nodeClient(slog.Default(), req.Transport, ContainerInfo{}, "", "", false); err != nil {
+			writeJSON(w, http.StatusBadRequest, previewResp{OK: false, Error: err.Error()})
+			return
+		}
+		inv, err := cfg.labInventory(req.Inventory)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, previewResp{OK: false, Error: err.Error()})
+			return
+		}
+		targets, err := inv.Select(selector)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, previewResp{OK: false, Error: err.Error()})
+			return
+		}
+		if len(targets) == 0 {
+			writeJSON(w, http.StatusBadRequest, previewResp{OK: false, Error: "no matching device in inventory"})
+			return
+		}
+
+		results := make([]previewResult, len(targets))
+		for i, d := range targets {
+			res := previewResult{Name: d.Name}
+			var out []byte
+			if req.Template == "config" {
+				out, err = renderer.RenderConfig(d.Device)
+			} else {
+				out, err = renderer.RenderFeatures(d.Device, req.Template)
+			}
+			if err != nil {
+				res.Error = err.Error()
+				results[i] = res
+				continue
+			}
+			res.Rendered = string(out)
+
+			res.StartupFile = startupFile(req.Lab, d)
+			if p, err := cfg.sanitizeLabPath(res.StartupFile); err != nil {
+				res.StartupError = err.Error()
+			} else if b, err := os.ReadFile(p); err != nil {
+				res.StartupError = err.Error()
+			} else {
+				res.StartupDiff = previewDiff(res.StartupFile, string(b), res.Rendered, req.Template != "config")
+				if req.Template == "config" {
+					res.StartupRemediation = remediation(string(b), res.Rendered)
+				}
+			}
+			results[i] = res
+		}
+
+		if req.Live {
+			livePreview(r.Context(), cfg, inv, req, results)
+		}
+		writeJSON(w, http.StatusOK, previewResp{OK: true, Results: results})
+	}
+}
+
+// startupFile is the startup config of d under basedir: the one the
+// topology names, relative to the lab file, or configs/<hostname>.cfg for
+// inventories that don't say.
+func startupFile(lab string, d inventory.Device) string {
+	switch {
+	case d.StartupConfig == "":
+		return filepath.Join("configs", d.Hostname+".cfg")
+	case filepath.IsAbs(d.StartupConfig):
+		return d.StartupConfig
+	}
+	return filepath.Join(filepath.Dir(lab), d.StartupConfig)
+}
+
+// livePreview fills in the running-config diffs of results.
+func livePreview(ctx context.Context, cfg serverCfg, inv *inventory.Inventory, req previewReq, results []previewResult) {
+	setAll := func(msg string) {
+		for i := range results {
+			if results[i].Error == "" {
+				results[i].RunningError = msg
+			}
+		}
+	}
+	labAbs, err := cfg.sanitizeLabPath(req.Lab)
+	if err != nil {
+		setAll(err.Error())
+		return
+	}
+	tout := time.Duration(req.TimeoutSec) * time.Second
+	if tout <= 0 || tout > 60*time.Second {
+		tout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, tout)
+	defer cancel()
+	out, err := runInspect(ctx, labAbs, req.UseSudo)
+	if err != nil {
+		setAll("inspect failed: " + err.Error())
+		return
+	}
+	nodes, err := ceosNodes(out, req.Transport != "docker")
+	if err != nil {
+		setAll("parse inspect: " + err.Error())
+		return
+	}
+	byHost := map[string]ContainerInfo{}
+	for _, n := range nodes {
+		byHost[clab.NodeName(n.LabName, n.Name)] = n
+	}
+
+	sem := make(chan struct{}, 5)
+	var wg sync.WaitGroup
+	for i := range results {
+		res := &results[i]
+		if res.Error != "" {
+			continue
 		}
+		n, ok := byHost[res.Name]
+		if !ok {
+			res.RunningError = "no running cEOS node"
+			continue
+		}
+		wg.Add(1)
+		go func(n ContainerInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-		var key string
-		var nodes []ContainerInfo
-		for k, v := range parsed {
-			key, nodes = k, v
-			break
+			user, pass := nodeCreds(inv, n, req.User, req.Pass)
+			client, _ := nodeClient(nodeLogger(ctx, n), req.Transport, n, user, pass, req.UseSudo)
+			running, err := arista.RunningConfig(client)
+			if err != nil {
+				res.RunningError = err.Error()
+				return
+			}
+			res.RunningDiff = previewDiff(res.Name+" running-config", running, res.Rendered, req.Template != "config")
+			if req.Template == "config" {
+				res.RunningRemediation = remediation(running, res.Rendered)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+// previewDiff diffs the current config with the rendered one. For a
+// fragment only the top-level sections it touches are compared, in the
+// fragment's order, and "!" comments are ignored either way.
+func previewDiff(name, current, rendered string, fragment bool) string {
+	current, rendered = stripComments(current), stripComments(rendered)
+	if fragment {
+		var headers []string
+		for _, line := range strings.Split(rendered, "\n") {
+			if line != "" && line != "!" && !strings.HasPrefix(line, " ") && !slices.Contains(headers, line) {
+				headers = append(headers, line)
+			}
 		}
+		current, rendered = configSections(current, headers), configSections(rendered, headers)
+	}
+	return textdiff.Unified(name, "rendered", current, rendered, 3)
+}
 
-		writeJSON(w, http.StatusOK, inspectResp{
-			OK:      true,
-			LabKey:  key,
-			Nodes:   nodes,
-			RawJSON: out,
-		})
+// remediation returns the config session commands that turn current into
+// rendered.
+func remediation(current, rendered string) []string {
+	cur, err := eosconfig.ParseString(current)
+	if err != nil {
+		return nil
+	}
+	want, err := eosconfig.ParseString(rendered)
+	if err != nil {
+		return nil
 	}
+	return eosconfig.Diff(cur, want).Flatten()
 }
 
-func writeJSON(w http.ResponseWriter, code int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	_ = json.NewEncoder(w).Encode(v)
+// stripComments drops "! ..." comment lines, like the header EOS puts on
+// show running-config, keeping bare "!" separators.
+func stripComments(cfg string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(cfg, "\n") {
+		if t := strings.TrimSpace(line); strings.HasPrefix(t, "!") && t != "!" {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n")
 }
 
-func runCmdHandler(cfg serverCfg) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		cmds := []string{"show bgp summary"}
-		url := "https://172.20.20.9/command-api"
-		client := arista.NewEosClient(url)
-		tmplPath := "templates/eapi_payload.tmpl"
-		fmt.Println("rendering template...")
-		body, err := renderer.RenderTemplate(tmplPath, arista.PayloadData{
-			Method:  "runCmds",
-			Version: 1,
-			Format:  "json",
-			Cmds:    cmds,
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-			os.Exit(1)
+// configSections returns the top-level blocks of cfg that start with one
+// of headers, in the order of headers, each followed by "!". Repeated
+// blocks are merged.
+func configSections(cfg string, headers []string) string {
+	blocks := map[string][]string{}
+	cur := ""
+	for _, line := range strings.Split(cfg, "\n") {
+		if line == "" || line == "!" {
+			continue
 		}
-		fmt.Println("running cmd...")
-		client.Run(body)
+		if !strings.HasPrefix(line, " ") {
+			cur = line
+			if _, ok := blocks[cur]; !ok {
+				blocks[cur] = nil
+			}
+			continue
+		}
+		blocks[cur] = append(blocks[cur], line)
+	}
+	var b strings.Builder
+	for _, h := range headers {
+		children, ok := blocks[h]
+		if !ok {
+			continue
+		}
+		b.WriteString(h + "\n")
+		for _, c := range children {
+			b.WriteString(c + "\n")
+		}
+		b.WriteString("!\n")
 	}
+	return b.String()
 }
 
 // ----- Health API -----
 
 type HealthReq struct {
-	Lab        string `json:"lab"`
-	UseSudo    bool   `json:"sudo"`
-	TimeoutSec int    `json:"timeoutSec"`
-	User       string `json:"user"`
-	Pass       string `json:"pass"`
+	Lab          string `json:"lab"`
+	UseSudo      bool   `json:"sudo"`
+	TimeoutSec   int    `json:"timeoutSec"`
+	User         string `json:"user"`
+	Pass         string `json:"pass"`
+	Bootstrap    bool   `json:"bootstrap"` // enable eAPI through docker exec where missing
+	BootstrapVRF string `json:"bootstrapVrf"`
+	Inventory    string `json:"inventory"` // defaults to inventory.json under basedir
+	Selector     string `json:"selector"`  // e.g. "role=leaf"; every node when empty
 }
 
 type HealthCheck struct {
@@ -241,17 +1451,20 @@ type HealthResp struct {
 }
 
 func ceosNodesFromInspect(out []byte) (nodes []ContainerInfo, err error) {
-	var parsed InspectResult
-	if err = json.Unmarshal(out, &parsed); err != nil {
+	return ceosNodes(out, true)
+}
+
+// ceosNodes lists the cEOS containers of the inspected lab, optionally only
+// those that have a management IPv4 address.
+func ceosNodes(out []byte, needIP bool) (nodes []ContainerInfo, err error) {
+	all, err := clab.ParseInspect(out)
+	if err != nil {
 		return nil, err
 	}
-	for _, list := range parsed {
-		for _, n := range list {
-			if strings.EqualFold(n.Kind, "ceos") && n.IPv4 != "" {
-				nodes = append(nodes, n)
-			}
+	for _, n := range all {
+		if n.IsCEOS() && (n.IPv4 != "" || !needIP) {
+			nodes = append(nodes, n)
 		}
-		break // first (and only) lab key
 	}
 	return
 }
@@ -275,7 +1488,7 @@ func onlyNonEmpty(ss []string) (out []string) {
 	return
 }
 
-func eapiRun(ctx context.Context, ip, user, pass string, cmds []string, format string) (status int, body []byte, err error) {
+func eapiRun(ctx context.Context, log *slog.Logger, ip, user, pass string, cmds []string, format string) (status int, body []byte, err error) {
 	if format == "" {
 		format = "json"
 	}
@@ -290,6 +1503,18 @@ func eapiRun(ctx context.Context, ip, user, pass string, cmds []string, format s
 		"id": 1,
 	}
 	b, _ := json.Marshal(payload)
+	start := time.Now()
+	defer func() {
+		log := log.With("cmds", cmds, "status", status, "duration", time.Since(start))
+		if logging.Debug() {
+			log.Debug("eapi exchange", "payload", logging.Redact(string(b)), "response", logging.Redact(string(body)))
+		}
+		if err != nil {
+			log.Warn("eapi run failed", "err", err)
+			return
+		}
+		log.Info("eapi run")
+	}()
 
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // lab only
@@ -331,6 +1556,11 @@ func healthHandler(cfg serverCfg) http.HandlerFunc {
 			writeJSON(w, http.StatusBadRequest, HealthResp{OK: false, Error: err.Error()})
 			return
 		}
+		inv, err := cfg.optionalInventory(req.Inventory)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, HealthResp{OK: false, Error: err.Error()})
+			return
+		}
 
 		tout := time.Duration(req.TimeoutSec) * time.Second
 		if tout <= 0 || tout > 60*time.Second {
@@ -341,6 +1571,7 @@ func healthHandler(cfg serverCfg) http.HandlerFunc {
 		defer cancel()
 		out, err := runInspect(ctx, labAbs, req.UseSudo)
 		if err != nil {
+			logging.FromContext(r.Context()).Warn("inspect failed", "lab", labAbs, "err", err)
 			writeJSON(w, http.StatusBadRequest, HealthResp{OK: false, Error: "inspect failed: " + err.Error()})
 			return
 		}
@@ -349,6 +1580,10 @@ func healthHandler(cfg serverCfg) http.HandlerFunc {
 			writeJSON(w, http.StatusInternalServerError, HealthResp{OK: false, Error: "parse inspect: " + err.Error()})
 			return
 		}
+		if nodes, err = selectNodes(inv, req.Selector, nodes); err != nil {
+			writeJSON(w, http.StatusBadRequest, HealthResp{OK: false, Error: err.Error()})
+			return
+		}
 		if len(nodes) == 0 {
 			writeJSON(w, http.StatusOK, HealthResp{OK: true, Nodes: nil})
 			return
@@ -377,11 +1612,22 @@ func healthHandler(cfg serverCfg) http.HandlerFunc {
 				defer func() { <-sem }()
 
 				ip := cidrIP(n.IPv4)
+				log := nodeLogger(r.Context(), n)
+				h := NodeHealth{Name: n.Name, IP: ip}
+				user, pass := nodeCreds(inv, n, req.User, req.Pass)
+				if req.Bootstrap {
+					res, err := bootstrapNode(ctx, log, n, user, pass, req.BootstrapVRF, req.UseSudo)
+					c := HealthCheck{Name: "eAPI bootstrap", Result: "PASS", Detail: bootstrapDetail(res)}
+					if err != nil {
+						c.Result, c.Detail = "FAIL", err.Error()
+					}
+					h.Checks = append(h.Checks, c)
+				}
+
 				cx, cancel := context.WithTimeout(r.Context(), tout)
 				defer cancel()
 
-				status, body, err := eapiRun(cx, ip, req.User, req.Pass, checkCmds, "json")
-				h := NodeHealth{Name: n.Name, IP: ip}
+				status, body, err := eapiRun(cx, log, ip, user, pass, checkCmds, "json")
 
 				if err != nil || status < 200 || status >= 300 {
 					h.Checks = append(h.Checks, HealthCheck{
@@ -463,7 +1709,639 @@ func healthHandler(cfg serverCfg) http.HandlerFunc {
 	}
 }
 
+// lintMain implements "lint": parse every template, execute it against its
+// sample data and report problems. It exits non-zero on any error.
+func lintMain(args []string) {
+	fl := flag.NewFlagSet("lint", flag.ExitOnError)
+	tmplDir := fl.String("templates", "", "directory whose *.tmpl files override the built-in templates")
+	asJSON := fl.Bool("json", false, "print problems as JSON")
+	fl.Parse(args)
+
+	problems, err := renderer.Lint(*tmplDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(problems)
+	} else {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+	}
+	if renderer.Failed(problems) {
+		os.Exit(1)
+	}
+}
+
+// lintConfigsMain is the "lint-configs" subcommand: it lints EOS configs
+// and exits 1 if any finding is an error.
+func lintConfigsMain(args []string) {
+	fl := flag.NewFlagSet("lint-configs", flag.ExitOnError)
+	format := fl.String("format", "text", "output format: text, json or sarif")
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "usage: laber lint-configs [-format text|json|sarif] [file or dir ...]\n\n"+
+			"Directories stand for their *.cfg files; the default is ../configs.\n")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+	paths := fl.Args()
+	if len(paths) == 0 {
+		paths = []string{filepath.Join("..", "configs")}
+	}
+
+	cfgs, err := eoslint.Load(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	findings := eoslint.Lint(cfgs)
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(findings)
+	case "sarif":
+		err = eoslint.WriteSARIF(os.Stdout, findings)
+	case "text":
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	if eoslint.Failed(findings) {
+		os.Exit(1)
+	}
+}
+
+// fabricMain is the "fabric" subcommand: it generates the topology,
+// startup configs and addressing plan from a fabric intent file.
+func fabricMain(args []string) {
+	fl := flag.NewFlagSet("fabric", flag.ExitOnError)
+	intent := fl.String("intent", filepath.Join("..", "fabric.yml"), "fabric intent file")
+	out := fl.String("out", "", "lab directory to write to (default: the intent's directory)")
+	check := fl.Bool("check", false, "only list the files that would change; exit 1 if any")
+	fl.Parse(args)
+	if *out == "" {
+		*out = filepath.Dir(*intent)
+	}
+
+	in, err := fabric.LoadFile(*intent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	files, err := fabric.Generate(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	changed, err := files.Write(*out, *check)
+	for _, p := range changed {
+		fmt.Println(filepath.Join(*out, p))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	if !*check {
+		return
+	}
+	// the files on disk may carry hand edits; they must still follow the
+	// BGP plan of the intent
+	mismatches := 0
+	if topo, err := clab.LoadTopology(filepath.Join(*out, fabric.TopologyFile)); err == nil {
+		if inv, err := inventory.Derive(topo, *out, nil); err == nil {
+			var devs []devices.Device
+			for _, d := range inv.Devices {
+				devs = append(devs, d.Device)
+			}
+			for _, m := range in.BGP.Check(devs) {
+				fmt.Printf("bgp: %s\n", m)
+				mismatches++
+			}
+		}
+	}
+	if len(changed) > 0 || mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// ipamMain is the "ipam" subcommand: it lists, allocates and releases
+// addresses in the IPAM file and checks configs against it.
+func ipamMain(args []string) {
+	fl := flag.NewFlagSet("ipam", flag.ExitOnError)
+	file := fl.String("file", filepath.Join("..", "ipam.json"), "IPAM file")
+	at := fl.Int("at", -1, "alloc: take this block index of the pool")
+	node := fl.String("node", "", "release: free everything this node uses")
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "usage: laber ipam [flags] list\n"+
+			"       laber ipam [flags] alloc POOL KEY [NODE ...]\n"+
+			"       laber ipam [flags] release POOL KEY | -node NODE release\n"+
+			"       laber ipam [flags] import|check [file or dir ...]\n\n"+
+			"import records the addresses the configs already use; check reports\n"+
+			"conflicts with the IPAM and exits 1 if there are any. The default\n"+
+			"configs are ../configs.\n")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	if fl.NArg() == 0 {
+		fl.Usage()
+		os.Exit(2)
+	}
+	db, err := ipam.Open(*file)
+	if err != nil {
+		fail(err)
+	}
+	uses := func(paths []string) []ipam.Use {
+		if len(paths) == 0 {
+			paths = []string{filepath.Join("..", "configs")}
+		}
+		cfgs, err := eoslint.Load(paths...)
+		if err != nil {
+			fail(err)
+		}
+		var out []ipam.Use
+		for _, c := range cfgs {
+			out = append(out, ipam.ConfigUses(c.Hostname(), c.File, c.Root)...)
+		}
+		return out
+	}
+	show := func(a ipam.Allocation) {
+		fmt.Printf("%-10s %-18s %-28s %s\n", a.Pool, a.Prefix, a.Key, strings.Join(a.Nodes, ","))
+	}
+
+	cmd, rest := fl.Arg(0), fl.Args()[1:]
+	switch {
+	case cmd == "list":
+		for _, a := range db.Allocations {
+			show(a)
+		}
+		return
+	case cmd == "check":
+		conflicts := db.Check(uses(rest))
+		for _, c := range conflicts {
+			fmt.Println(c)
+		}
+		if len(conflicts) > 0 {
+			os.Exit(1)
+		}
+		return
+	case cmd == "alloc" && len(rest) >= 2:
+		var a ipam.Allocation
+		if *at >= 0 {
+			a, err = db.AllocateAt(rest[0], rest[1], *at, rest[2:]...)
+		} else {
+			a, err = db.Allocate(rest[0], rest[1], rest[2:]...)
+		}
+		if err != nil {
+			fail(err)
+		}
+		show(a)
+	case cmd == "release" && *node != "":
+		for _, a := range db.ReleaseNode(*node) {
+			show(a)
+		}
+	case cmd == "release" && len(rest) == 2:
+		if !db.Release(rest[0], rest[1]) {
+			fail(fmt.Errorf("%s holds nothing in pool %s", rest[1], rest[0]))
+		}
+	case cmd == "import":
+		for _, a := range db.Import(uses(rest)) {
+			show(a)
+		}
+	default:
+		fl.Usage()
+		os.Exit(2)
+	}
+	if err := db.Save(); err != nil {
+		fail(err)
+	}
+}
+
+// addressingMain is the "addressing" subcommand: it writes the addressing
+// plan of the lab's startup configs and links, or checks a plan document
+// against them.
+func addressingMain(args []string) {
+	fl := flag.NewFlagSet("addressing", flag.ExitOnError)
+	lab := fl.String("lab", filepath.Join("..", "lab.clab.yml"), "containerlab topology file; its startup configs are read too")
+	verify := fl.String("verify", "", "check this plan document instead of writing one; exits 1 on mismatches")
+	asJSON := fl.Bool("json", false, "-verify: print mismatches as JSON")
+	fl.Parse(args)
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+
+	inv, _, err := deriveInventory(context.Background(), *lab, false, false)
+	if err != nil {
+		fail(err)
+	}
+	if *verify == "" {
+		os.Stdout.Write(addressing.Generate(inv))
+		return
+	}
+	doc, err := os.ReadFile(*verify)
+	if err != nil {
+		fail(err)
+	}
+	mismatches := addressing.Verify(*verify, string(doc), inv, filepath.Dir(*lab))
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(mismatches)
+	} else {
+		for _, m := range mismatches {
+			fmt.Println(m)
+		}
+	}
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+// devicedbMain is the "devicedb" subcommand: it manages the SQLite device
+// table auto_lab reads.
+func devicedbMain(args []string) {
+	fl := flag.NewFlagSet("devicedb", flag.ExitOnError)
+	file := fl.String("db", filepath.Join("..", "devices.db"), "SQLite database file")
+	invFile := fl.String("inventory", filepath.Join("..", "inventory.json"), "seed: inventory file")
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "usage: laber devicedb [flags] list|seed|export\n"+
+			"       laber devicedb [flags] get|delete HOSTNAME\n"+
+			"       laber devicedb [flags] import FILE\n\n"+
+			"seed writes the routed devices of the inventory; export prints the\n"+
+			"table as YAML and import reads that layout back.\n")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	if fl.NArg() == 0 {
+		fl.Usage()
+		os.Exit(2)
+	}
+	ctx := context.Background()
+	db, err := devicedb.OpenSQLite(ctx, *file)
+	if err != nil {
+		fail(err)
+	}
+	defer db.Close()
+	show := func(r devicedb.Record) {
+		fmt.Printf("%-10s %-15s %-15s %s\n", r.Hostname, r.IPAddress, r.LoopbackIP, strings.Join(r.InfrastructureInterfaces, ","))
+	}
+
+	cmd, rest := fl.Arg(0), fl.Args()[1:]
+	switch {
+	case cmd == "list":
+		recs, err := db.List(ctx)
+		if err != nil {
+			fail(err)
+		}
+		for _, r := range recs {
+			show(r)
+		}
+	case cmd == "get" && len(rest) == 1:
+		r, err := db.Get(ctx, rest[0])
+		if err != nil {
+			fail(err)
+		}
+		show(r)
+	case cmd == "delete" && len(rest) == 1:
+		if err := db.Delete(ctx, rest[0]); err != nil {
+			fail(err)
+		}
+	case cmd == "seed":
+		inv, err := inventory.LoadFile(*invFile)
+		if err != nil {
+			fail(err)
+		}
+		for _, r := range devicedb.FromInventory(inv) {
+			if err := db.Put(ctx, r); err != nil {
+				fail(err)
+			}
+			show(r)
+		}
+	case cmd == "export":
+		if err := devicedb.Export(ctx, db, os.Stdout); err != nil {
+			fail(err)
+		}
+	case cmd == "import" && len(rest) == 1:
+		f, err := os.Open(rest[0])
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		n, err := devicedb.Import(ctx, db, f)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("imported %d devices\n", n)
+	default:
+		fl.Usage()
+		os.Exit(2)
+	}
+}
+
+// inventoryMain is the "inventory" subcommand: it prints the inventory
+// derived from a topology file, for saving as inventory.json.
+func inventoryMain(args []string) {
+	fl := flag.NewFlagSet("inventory", flag.ExitOnError)
+	lab := fl.String("lab", filepath.Join("..", "lab.clab.yml"), "containerlab topology file")
+	live := fl.Bool("inspect", false, "add container state and mgmt addresses from containerlab inspect")
+	useSudo := fl.Bool("sudo", false, "run containerlab inspect with sudo -n")
+	fl.Parse(args)
+
+	inv, inspectErr, err := deriveInventory(context.Background(), *lab, *live, *useSudo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if inspectErr != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: inspect: %v\n", inspectErr)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(inv)
+}
+
+// servicesMain is the "services" subcommand: it prints the per-leaf config
+// that takes the lab from the applied services to the catalog, or that
+// removes every applied service.
+func servicesMain(args []string) {
+	fl := flag.NewFlagSet("services", flag.ExitOnError)
+	file := fl.String("file", filepath.Join("..", "services.yml"), "services catalog")
+	state := fl.String("state", filepath.Join("..", "services.state.yml"), "last applied catalog")
+	invPath := fl.String("inventory", filepath.Join("..", "inventory.json"), "inventory the leaves come from")
+	intent := fl.String("intent", filepath.Join("..", "fabric.yml"), "fabric intent with the BGP plan")
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "usage: laber services [flags] plan|teardown\n\n"+
+			"plan prints each leaf's delta from the applied catalog to the current\n"+
+			"one, teardown the cleanup of everything applied. POST /services pushes\n"+
+			"the same deltas.\n")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	if fl.NArg() != 1 || (fl.Arg(0) != "plan" && fl.Arg(0) != "teardown") {
+		fl.Usage()
+		os.Exit(2)
+	}
+
+	plan := bgpplan.Default()
+	if in, err := fabric.LoadFile(*intent); err == nil {
+		plan = in.BGP
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		fail(err)
+	}
+	inv, err := inventory.LoadFile(*invPath)
+	if err != nil {
+		fail(err)
+	}
+	applied, err := services.LoadFile(*state)
+	if err != nil {
+		fail(err)
+	}
+	want := &services.Catalog{}
+	if fl.Arg(0) == "plan" {
+		if want, err = services.LoadFile(*file); err != nil {
+			fail(err)
+		}
+	}
+	var devs []devices.Device
+	for _, d := range inv.Devices {
+		devs = append(devs, d.Device)
+	}
+	changes, err := services.Changes(devs, applied, want, plan)
+	if err != nil {
+		fail(err)
+	}
+	for _, c := range changes {
+		fmt.Printf("! %s\n%s", c.Leaf, c.Config)
+	}
+}
+
+// hostsMain is the "hosts" subcommand: it lists the linux hosts of the lab
+// with their attachments, or applies their networking over docker exec.
+func hostsMain(args []string) {
+	fl := flag.NewFlagSet("hosts", flag.ExitOnError)
+	lab := fl.String("lab", filepath.Join("..", "lab.clab.yml"), "containerlab topology file; its startup configs are read too")
+	useSudo := fl.Bool("sudo", false, "run docker with sudo -n")
+	dryRun := fl.Bool("n", false, "print the commands apply would run instead of running them")
+	asJSON := fl.Bool("json", false, "list the hosts as JSON")
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "usage: laber hosts [flags] list|apply [host ...]\n\n"+
+			"list prints each host interface with its address, VLAN, MTU and leaf\n"+
+			"port; apply sets them up in the running containers. POST /hosts does\n"+
+			"the same.\n")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	if fl.NArg() < 1 || (fl.Arg(0) != "list" && fl.Arg(0) != "apply") {
+		fl.Usage()
+		os.Exit(2)
+	}
+
+	inv, _, err := deriveInventory(context.Background(), *lab, false, false)
+	if err != nil {
+		fail(err)
+	}
+	hs, err := selectHosts(hosts.FromInventory(inv), fl.Args()[1:])
+	if err != nil {
+		fail(err)
+	}
+
+	if fl.Arg(0) == "list" {
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(hs)
+			return
+		}
+		for _, h := range hs {
+			for _, i := range h.Interfaces {
+				vlan, mtu := "-", "-"
+				if i.VLAN != 0 {
+					vlan = fmt.Sprint(i.VLAN)
+					if i.Tagged {
+						vlan += " (tagged)"
+					}
+				}
+				if i.MTU != 0 {
+					mtu = fmt.Sprint(i.MTU)
+				}
+				fmt.Printf("%-6s %-8s %-18s vlan %-12s mtu %-5s %s:%s\n", h.Name, i.Name, i.Address, vlan, mtu, i.Leaf, i.LeafPort)
+			}
+		}
+		return
+	}
+
+	dockerExec := func(ctx context.Context, container string, argv ...string) ([]byte, error) {
+		return clab.DockerExec(ctx, container, *useSudo, argv...)
+	}
+	failed := false
+	for _, h := range hs {
+		if *dryRun {
+			for _, argv := range h.Commands() {
+				fmt.Printf("%s: %s\n", h.Container, strings.Join(argv, " "))
+			}
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ran, err := hosts.Apply(ctx, h, dockerExec)
+		cancel()
+		for _, line := range ran {
+			fmt.Printf("%s: %s\n", h.Container, line)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// validateMain is the "validate" subcommand: it checks the inventory for
+// lab-breaking inconsistencies and exits 1 if it finds any.
+func validateMain(args []string) {
+	fl := flag.NewFlagSet("validate", flag.ExitOnError)
+	lab := fl.String("lab", filepath.Join("..", "lab.clab.yml"), "containerlab topology file; its startup configs are read too")
+	invFile := fl.String("inventory", "", "check this inventory file instead of deriving one from -lab")
+	ipamFile := fl.String("ipam", filepath.Join("..", "ipam.json"), "IPAM file with the data-plane pools")
+	intent := fl.String("intent", filepath.Join("..", "fabric.yml"), "fabric intent whose BGP plan the devices must follow; \"\" to skip")
+	asJSON := fl.Bool("json", false, "print findings as JSON")
+	fl.Parse(args)
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+
+	var inv *inventory.Inventory
+	var err error
+	if *invFile != "" {
+		inv, err = inventory.LoadFile(*invFile)
+	} else {
+		inv, _, err = deriveInventory(context.Background(), *lab, false, false)
+	}
+	if err != nil {
+		fail(err)
+	}
+	var opts consistency.Options
+	if opts.IPAM, err = ipam.Open(*ipamFile); err != nil {
+		fail(err)
+	}
+	if *intent != "" {
+		plan := bgpplan.Default()
+		in, err := fabric.LoadFile(*intent)
+		switch {
+		case err == nil:
+			plan = in.BGP
+		case !errors.Is(err, fs.ErrNotExist):
+			fail(err)
+		}
+		opts.Plan = &plan
+	}
+
+	findings := consistency.Check(inv, opts)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(findings)
+	} else {
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		fmt.Printf("%d devices, %d findings\n", len(inv.Devices), len(findings))
+	}
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "addressing":
+			addressingMain(os.Args[2:])
+			return
+		case "devicedb":
+			devicedbMain(os.Args[2:])
+			return
+		case "fabric":
+			fabricMain(os.Args[2:])
+			return
+		case "ipam":
+			ipamMain(os.Args[2:])
+			return
+		case "hosts":
+			hostsMain(os.Args[2:])
+			return
+		case "inventory":
+			inventoryMain(os.Args[2:])
+			return
+		case "lint":
+			lintMain(os.Args[2:])
+			return
+		case "lint-configs":
+			lintConfigsMain(os.Args[2:])
+			return
+		case "services":
+			servicesMain(os.Args[2:])
+			return
+		case "validate":
+			validateMain(os.Args[2:])
+			return
+		}
+	}
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "log format: text or json")
+	tmplDir := flag.String("templates", "", "directory whose *.tmpl files override the built-in templates")
+	tmplWatch := flag.Bool("templates-watch", false, "reload override templates when they change")
+	flag.Parse()
+	if err := logging.Init(os.Stderr, *logFormat, *logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+
+	// refuse to start with a template that would only fail at request time
+	reg, err := renderer.NewRegistry(*tmplDir)
+	if err != nil {
+		var ve *renderer.ValidationError
+		if errors.As(err, &ve) {
+			for _, p := range ve.Problems {
+				slog.Error("invalid template", "problem", p.String())
+			}
+		}
+		slog.Error("loading templates", "dir", *tmplDir, "err", err)
+		os.Exit(1)
+	}
+	renderer.SetDefault(reg)
+	if *tmplDir != "" && *tmplWatch {
+		go reg.Watch(context.Background(), 2*time.Second)
+	}
+
 	cfg := serverCfg{
 		Listen:  ":8080",
 		BaseDir: "/home/ubuntu/lab",
@@ -481,16 +2359,24 @@ func main() {
 	mux.HandleFunc("/", indexHandler(cfg, t))
 	mux.HandleFunc("/inspect", inspectHandler(cfg))
 	mux.HandleFunc("/runcmd", runCmdHandler(cfg))
+	mux.HandleFunc("/run-cmds", runCmdHandler(cfg))
 	mux.HandleFunc("/health", healthHandler(cfg))
+	mux.HandleFunc("/inventory", inventoryHandler(cfg))
+	mux.HandleFunc("/features", featuresHandler(cfg))
+	mux.HandleFunc("/preview", previewHandler(cfg))
+	mux.HandleFunc("/services", servicesHandler(cfg))
+	mux.HandleFunc("/hosts", hostsHandler(cfg))
+	mux.HandleFunc("/loglevel", logLevelHandler())
 
 	srv := &http.Server{
 		Addr:              cfg.Listen,
-		Handler:           mux,
+		Handler:           withRequestLog(mux),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	println("listening on", cfg.Listen, "basedir:", cfg.BaseDir)
+	slog.Info("listening", "addr", cfg.Listen, "basedir", cfg.BaseDir, "log_level", logging.Level.Level().String())
 	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		panic(err)
+		slog.Error("server stopped", "err", err)
+		os.Exit(1)
 	}
 }
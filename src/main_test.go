@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+)
+
+// fakeContainerlab puts a containerlab on PATH whose inspect reports no
+// nodes, so the handlers get past inspect without a lab.
+func fakeContainerlab(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho '{\"t\": []}'\n"
+	if err := os.WriteFile(filepath.Join(bin, "containerlab"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Without inventory.json under basedir the handlers act on every node
+// inspect reports; only an inventory the request names has to exist.
+func TestRunCmdsWithoutInventory(t *testing.T) {
+	fakeContainerlab(t)
+	cfg := serverCfg{BaseDir: t.TempDir()}
+	if err := os.WriteFile(filepath.Join(cfg.BaseDir, "lab.clab.yml"), []byte("name: t\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, body string
+		code       int
+		err        string
+	}{
+		{"default", `{"lab":"lab.clab.yml","cmds":["show version"]}`, http.StatusOK, ""},
+		{"named", `{"lab":"lab.clab.yml","cmds":["show version"],"inventory":"inventory.json"}`, http.StatusBadRequest, "inventory: lab file not found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			runCmdHandler(cfg)(rec, httptest.NewRequest(http.MethodPost, "/run-cmds", strings.NewReader(tc.body)))
+			var resp runCmdsResp
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tc.code || resp.Error != tc.err {
+				t.Errorf("got %d %q, want %d %q", rec.Code, resp.Error, tc.code, tc.err)
+			}
+		})
+	}
+}
+
+func TestStartupFile(t *testing.T) {
+	for _, tc := range []struct {
+		lab, startup, want string
+	}{
+		{"", "", "configs/leaf1.cfg"},
+		{"lab.clab.yml", "configs/leaf1.cfg", "configs/leaf1.cfg"},
+		{"labs/gen/lab.clab.yml", "cfg/l1.cfg", "labs/gen/cfg/l1.cfg"},
+		{"labs/gen/lab.clab.yml", "/srv/l1.cfg", "/srv/l1.cfg"},
+	} {
+		d := inventory.Device{Name: "leaf1", StartupConfig: tc.startup}
+		d.Hostname = "leaf1"
+		if got := startupFile(tc.lab, d); got != tc.want {
+			t.Errorf("startupFile(%q, %q) = %q, want %q", tc.lab, tc.startup, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package addressing renders the addressing plan of a lab as Markdown
+// tables (loopbacks and ASNs, underlay /31s, VLAN/VNI, hosts) and checks
+// an existing plan document against them, so addressing.md can't drift
+// from what the configs actually configure.
+//
+//	inv, _ := inventory.Derive(topo, dir, nil)
+//	doc := addressing.Generate(inv)
+//	for _, m := range addressing.Verify("addressing.md", old, inv, dir) { fmt.Println(m) }
+package addressing
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Title is the first line of a generated document.
+const Title = "# Addressing plan (quick reference)"
+
+// Intro is the paragraph under the title, with the lab name to fill in.
+// `laber fabric` and `laber addressing` write the same document.
+const Intro = "Generated from the startup configs and links of %s by `laber fabric` or\n`laber addressing`; `laber addressing -verify` checks it against them."
+
+// Table is one section of the plan. Rows are keyed by their first cell.
+type Table struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+	Refs   [][]Ref // of each row, per cell; where Tables took it from
+	Line   int     // of the heading, in a parsed document
+	Lines  []int   // of each row, in a parsed document
+}
+
+// Ref is the startup-config line a cell comes from: the eosconfig.Select
+// path to it in the config of Device. The zero Ref is a cell from the
+// topology, or one without a value.
+type Ref struct {
+	Device string
+	Path   []string
+}
+
+// add appends a row, with the refs of its first cells.
+func (t *Table) add(row []string, refs ...Ref) {
+	t.Rows = append(t.Rows, row)
+	t.Refs = append(t.Refs, append(refs, make([]Ref, len(row)-len(refs))...))
+}
+
+// intf is the ref of a line in the section of interface name of d.
+func intf(d inventory.Device, name, line string) Ref {
+	return Ref{Device: d.Name, Path: []string{"interface " + name, line}}
+}
+
+// Tables builds the sections of the plan of inv. The hosts section is left
+// out when the lab has none.
+func Tables(inv *inventory.Inventory) []Table {
+	devs := Table{Title: "Devices", Header: []string{"Device", "Role", "Loopback0 (router ID)", "Loopback1 (VTEP)", "ASN"}}
+	for _, d := range inv.Devices {
+		if d.BGP == nil {
+			continue
+		}
+		devs.add([]string{d.Name, d.Role, d.Loopback(0), dash(d.Loopback(1)), strconv.Itoa(d.BGP.ASN)},
+			Ref{}, Ref{}, intf(d, "Loopback0", "ip address"), intf(d, "Loopback1", "ip address"),
+			Ref{Device: d.Name, Path: []string{"router bgp"}})
+	}
+
+	links := Table{Title: "Underlay /31s (per link)", Header: []string{"Link", "Spine IP", "Leaf IP"}}
+	for _, d := range inv.Devices {
+		if d.Role != inventory.RoleLeaf {
+			continue
+		}
+		for _, l := range d.Links {
+			spine, _ := inv.Get(l.Peer)
+			if spine.Role != inventory.RoleSpine {
+				continue
+			}
+			links.add([]string{
+				fmt.Sprintf("%s:%s – %s:%s", spine.Name, shortEth(l.PeerInterface), d.Name, shortEth(l.Interface)),
+				address(spine, l.PeerInterface),
+				address(d, l.Interface),
+			}, Ref{},
+				intf(spine, netmath.EOSInterface(l.PeerInterface), "ip address"),
+				intf(d, netmath.EOSInterface(l.Interface), "ip address"))
+		}
+	}
+
+	vlans := Table{Title: "VLAN/VNI", Header: []string{"VLAN", "VNI", "Subnet", "RD", "Route target"}}
+	for _, id := range vlanIDs(inv) {
+		vni := 0
+		var rds, rts []string
+		var vniRef, rdRef, rtRef Ref
+		rdByRID, rdByASN := true, true
+		for _, d := range inv.Devices {
+			for _, v := range d.VLANs {
+				if v.ID != id {
+					continue
+				}
+				if v.VNI > vni {
+					vni = v.VNI
+					vniRef = Ref{Device: d.Name, Path: []string{"interface", fmt.Sprintf("vxlan vlan %d vni", id)}}
+				}
+				if v.RD == "" {
+					continue
+				}
+				if rdRef.Device == "" {
+					vlan := "vlan " + strconv.Itoa(id)
+					rdRef = Ref{Device: d.Name, Path: []string{"router bgp", vlan, "rd"}}
+					rtRef = Ref{Device: d.Name, Path: []string{"router bgp", vlan, "route-target import"}}
+				}
+				rds = appendNew(rds, v.RD)
+				rts = appendNew(rts, v.RouteTarget)
+				rdByRID = rdByRID && d.BGP != nil && v.RD == d.BGP.RouterID+":"+strconv.Itoa(id)
+				rdByASN = rdByASN && d.BGP != nil && v.RD == strconv.Itoa(d.BGP.ASN)+":"+strconv.Itoa(v.VNI)
+			}
+		}
+		rd := strings.Join(rds, ", ")
+		switch {
+		case len(rds) == 0:
+		case rdByRID:
+			rd = "<router-id>:" + strconv.Itoa(id)
+		case rdByASN:
+			rd = "<asn>:" + strconv.Itoa(vni)
+		}
+		vniCell := "-"
+		if vni != 0 {
+			vniCell = strconv.Itoa(vni)
+		}
+		sub, subRef := subnet(inv, id)
+		vlans.add([]string{strconv.Itoa(id), vniCell, dash(sub), dash(rd), dash(strings.Join(rts, ", "))},
+			Ref{}, vniRef, subRef, rdRef, rtRef)
+	}
+
+	hosts := Table{Title: "Hosts", Header: []string{"Host", "eth1", "VLAN", "Leaf port"}}
+	for _, d := range inv.Devices {
+		if d.Role != inventory.RoleHost || len(d.Links) == 0 || len(d.Interfaces) == 0 {
+			continue
+		}
+		l := d.Links[0]
+		leaf, _ := inv.Get(l.Peer)
+		vlan, vlanRef := "-", Ref{}
+		if v := port(leaf, l.PeerInterface).VLAN; v != 0 {
+			vlan, vlanRef = strconv.Itoa(v), intf(leaf, netmath.EOSInterface(l.PeerInterface), "switchport access vlan")
+		}
+		hosts.add([]string{d.Name, d.Interfaces[0].Address, vlan, l.Peer + ":" + netmath.EOSInterface(l.PeerInterface)},
+			Ref{}, Ref{}, vlanRef)
+	}
+
+	out := []Table{devs, links, vlans}
+	if len(hosts.Rows) > 0 {
+		out = append(out, hosts)
+	}
+	return out
+}
+
+// Generate writes the plan document of inv.
+func Generate(inv *inventory.Inventory) []byte {
+	var b strings.Builder
+	b.WriteString(Title + "\n\n")
+	fmt.Fprintf(&b, Intro+"\n\n", inv.Lab)
+	for i, t := range Tables(inv) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %s\n\n", t.Title)
+		write(&b, t.Header, t.Rows)
+	}
+	return []byte(b.String())
+}
+
+// vlanIDs lists the VLANs of the inventory in the order they first appear.
+func vlanIDs(inv *inventory.Inventory) []int {
+	var ids []int
+	for _, d := range inv.Devices {
+		for _, v := range d.VLANs {
+			if !slices.Contains(ids, v.ID) {
+				ids = append(ids, v.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// subnet is the prefix of VLAN id: that of its SVI, else that of a host in
+// it, and the ref of the SVI address.
+func subnet(inv *inventory.Inventory, id int) (string, Ref) {
+	svi := "Vlan" + strconv.Itoa(id)
+	for _, d := range inv.Devices {
+		for _, i := range d.Interfaces {
+			if i.Name != svi {
+				continue
+			}
+			for _, a := range []string{i.Address, i.Gateway} {
+				if p, err := netip.ParsePrefix(a); err == nil {
+					return p.Masked().String(), intf(d, svi, "ip address")
+				}
+			}
+		}
+	}
+	for _, d := range inv.Devices {
+		if d.Role != inventory.RoleHost || len(d.Links) == 0 || len(d.Interfaces) == 0 {
+			continue
+		}
+		leaf, _ := inv.Get(d.Links[0].Peer)
+		if port(leaf, d.Links[0].PeerInterface).VLAN != id {
+			continue
+		}
+		if p, err := netip.ParsePrefix(d.Interfaces[0].Address); err == nil {
+			return p.Masked().String(), Ref{}
+		}
+	}
+	return "", Ref{}
+}
+
+// port returns the interface of d a containerlab link end names.
+func port(d inventory.Device, intf string) devices.Interface {
+	name := netmath.EOSInterface(intf)
+	for _, i := range d.Interfaces {
+		if i.Name == name {
+			return i
+		}
+	}
+	return devices.Interface{Name: name}
+}
+
+// address returns the address of the containerlab interface intf of d.
+func address(d inventory.Device, intf string) string {
+	return port(d, intf).Address
+}
+
+// shortEth writes "eth1" as "Eth1".
+func shortEth(intf string) string {
+	return "Eth" + strings.TrimPrefix(netmath.EOSInterface(intf), "Ethernet")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func appendNew(list []string, s string) []string {
+	if s == "" || slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// write writes a markdown table with its columns padded to line up.
+func write(b *strings.Builder, header []string, rows [][]string) {
+	width := make([]int, len(header))
+	for _, r := range append([][]string{header}, rows...) {
+		for i, c := range r {
+			width[i] = max(width[i], len([]rune(c)))
+		}
+	}
+	line := func(cells []string) {
+		for i, c := range cells {
+			fmt.Fprintf(b, "| %s%s ", c, strings.Repeat(" ", width[i]-len([]rune(c))))
+		}
+		b.WriteString("|\n")
+	}
+	line(header)
+	sep := make([]string, len(header))
+	for i := range sep {
+		sep[i] = strings.Repeat("-", width[i])
+	}
+	line(sep)
+	for _, r := range rows {
+		line(r)
+	}
+}
@@ -0,0 +1,118 @@
+package addressing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+)
+
+const labDir = "../../.."
+
+func lab(t *testing.T) (*inventory.Inventory, string) {
+	t.Helper()
+	topo, err := clab.LoadTopology(filepath.Join(labDir, "lab.clab.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := inventory.Derive(topo, labDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := os.ReadFile(filepath.Join(labDir, "addressing.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv, string(doc)
+}
+
+// addressing.md is generated from the intent; the configs it was rendered
+// into must still agree with it, and give the same document.
+func TestLabMatchesConfigs(t *testing.T) {
+	inv, doc := lab(t)
+	if string(Generate(inv)) != doc {
+		t.Error("the configs give a different addressing.md")
+	}
+	for _, m := range Verify("addressing.md", doc, inv, labDir) {
+		t.Error(m)
+	}
+}
+
+func TestGenerateVerifies(t *testing.T) {
+	inv, _ := lab(t)
+	doc := string(Generate(inv))
+	if !strings.HasPrefix(doc, Title+"\n\nGenerated from the startup configs and links of evpn-rdma-fabric by") {
+		t.Errorf("document starts\n%s", doc[:80])
+	}
+	for _, want := range []string{
+		"| leaf1  | leaf  | 10.0.0.11/32          | 10.255.0.11/32   | 65101 |",
+		"| spine1:Eth1 – leaf1:Eth1 | 172.16.1.0/31 | 172.16.1.1/31 |",
+		"| 10   | 1010 | 10.10.10.0/24 | <router-id>:10 | 65000:1010   |",
+		"| gpu4 | 10.10.10.104/24 | 10   | leaf4:Ethernet3 |",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("no line %q", want)
+		}
+	}
+	if ms := Verify("gen.md", doc, inv, labDir); len(ms) > 0 {
+		t.Errorf("generated document doesn't verify: %v", ms)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	inv, doc := lab(t)
+	for _, tc := range []struct {
+		name      string
+		old, repl string
+		want      []string
+	}{
+		{"cell", "| 172.16.2.5/31 |", "| 172.16.2.9/31 |",
+			[]string{"addressing.md:26: Underlay /31s (per link): spine2:Eth3 – leaf3:Eth2 Leaf IP is 172.16.2.9/31, the configs have 172.16.2.5/31 (../../../configs/leaf3.cfg:15)"}},
+		{"asn", "| 65103 |", "| 65003 |",
+			[]string{"addressing.md:14: Devices: leaf3 ASN is 65003, the configs have 65103 (../../../configs/leaf3.cfg:33)"}},
+		{"missing row", "| gpu2 | 10.10.10.102/24 | 10   | leaf2:Ethernet3 |\n", "",
+			[]string{"addressing.md:36: Hosts: gpu2 is missing"}},
+		{"rt", "| <router-id>:10 | 65000:1010   |", "| <router-id>:10 | 65000:1011   |",
+			[]string{"addressing.md:34: VLAN/VNI: 10 Route target is 65000:1011, the configs have 65000:1010 (../../../configs/leaf1.cfg:50)"}},
+		{"vlan", "| gpu3 | 10.10.10.103/24 | 10   |", "| gpu3 | 10.10.10.103/24 | 20   |",
+			[]string{"addressing.md:42: Hosts: gpu3 VLAN is 20, the configs have 10 (../../../configs/leaf3.cfg:18)"}},
+		{"extra row", "| 10   | 1010 |", "| 20   | 1020 | - | - | - |\n| 10   | 1010 |",
+			[]string{"addressing.md:34: VLAN/VNI: 20 is not in the configs"}},
+		{"twice", "| gpu4 | 10.10.10.104/24 | 10   | leaf4:Ethernet3 |", "| gpu4 | 10.10.10.104/24 | 10   | leaf4:Ethernet3 |\n| gpu4 | x | x | x |",
+			[]string{"addressing.md:44: Hosts: gpu4 is already listed on line 43"}},
+		{"column", "| Host | eth1            | VLAN | Leaf port       |", "| Host | eth1            | Vlan | Leaf port       |",
+			[]string{`addressing.md:36: Hosts: no "VLAN" column`}},
+		{"section", "## VLAN/VNI", "## VLANs", []string{`addressing.md: no "VLAN/VNI" section`}},
+		{"notes", "## Hosts", "## Notes\n\n| Anything | goes |\n| - | - |\n| here | too |\n\n## Hosts", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if !strings.Contains(doc, tc.old) {
+				t.Fatalf("addressing.md has no %q", tc.old)
+			}
+			got := Verify("addressing.md", strings.Replace(doc, tc.old, tc.repl, 1), inv, labDir)
+			if len(got) != len(tc.want) {
+				t.Fatalf("mismatches = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i].String() != tc.want[i] {
+					t.Errorf("mismatch %d =\n%s\nwant\n%s", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tables := Parse("# T\n\n## A\n\n|  x | y  |\n|:--|--:|\n| 1 |  two  words |\n\ntext\n## B\nno table\n")
+	if len(tables) != 1 {
+		t.Fatalf("tables = %+v", tables)
+	}
+	a := tables[0]
+	if a.Title != "A" || a.Line != 3 || strings.Join(a.Header, ",") != "x,y" ||
+		len(a.Rows) != 1 || a.Rows[0][1] != "two words" || a.Lines[0] != 7 {
+		t.Errorf("A = %+v", a)
+	}
+}
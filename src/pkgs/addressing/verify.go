@@ -0,0 +1,168 @@
+package addressing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+)
+
+// Mismatch is a place where a plan document and the configs disagree.
+type Mismatch struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"` // 0 when the document lacks a section
+	Message string `json:"message"`
+	// Source is the startup-config line with the configs' value, as
+	// file:line, when the value comes from one.
+	Source string `json:"source,omitempty"`
+}
+
+func (m Mismatch) String() string {
+	s := fmt.Sprintf("%s:%d: %s", m.File, m.Line, m.Message)
+	if m.Line == 0 {
+		s = fmt.Sprintf("%s: %s", m.File, m.Message)
+	}
+	if m.Source != "" {
+		s += " (" + m.Source + ")"
+	}
+	return s
+}
+
+// Parse reads the "## " sections of a Markdown document that hold a table.
+// Cells are trimmed; the separator row is dropped.
+func Parse(doc string) []Table {
+	var out []Table
+	var cur *Table
+	for n, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "## "):
+			out = append(out, Table{Title: strings.TrimSpace(line[3:]), Line: n + 1})
+			cur = &out[len(out)-1]
+		case cur != nil && strings.HasPrefix(line, "|"):
+			cells := strings.Split(strings.Trim(line, "|"), "|")
+			for i := range cells {
+				cells[i] = strings.Join(strings.Fields(cells[i]), " ")
+			}
+			switch {
+			case cur.Header == nil:
+				cur.Header = cells
+			case strings.Trim(strings.Join(cells, ""), "-: ") == "":
+				// the separator
+			default:
+				cur.Rows = append(cur.Rows, cells)
+				cur.Lines = append(cur.Lines, n+1)
+			}
+		}
+	}
+	return slices.DeleteFunc(out, func(t Table) bool { return t.Header == nil })
+}
+
+// Verify checks the plan document doc, read from file, against the tables
+// Generate writes for inv: every section, column and row has to be there
+// with the same cells. Sections and columns the generator doesn't write are
+// left alone, so the document can carry notes of its own. A cell that
+// differs is reported with the startup-config line holding the real value;
+// the startup configs of inv are found relative to dir, the topology's
+// directory.
+func Verify(file, doc string, inv *inventory.Inventory, dir string) []Mismatch {
+	var out []Mismatch
+	add := func(line int, format string, args ...any) {
+		out = append(out, Mismatch{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	src := sources(inv, dir)
+	have := Parse(doc)
+	for _, want := range Tables(inv) {
+		i := slices.IndexFunc(have, func(t Table) bool { return t.Title == want.Title })
+		if i < 0 {
+			add(0, "no %q section", want.Title)
+			continue
+		}
+		got := have[i]
+		cols := make([]int, len(want.Header))
+		for c, h := range want.Header {
+			cols[c] = slices.Index(got.Header, h)
+			if cols[c] < 0 {
+				add(got.Line, "%s: no %q column", want.Title, h)
+			}
+		}
+		if cols[0] < 0 {
+			continue
+		}
+		wantRows := map[string]int{}
+		for n, r := range want.Rows {
+			wantRows[r[0]] = n
+		}
+		seen := map[string]int{}
+		for n, r := range got.Rows {
+			line := got.Lines[n]
+			key := cell(r, cols[0])
+			if first, dup := seen[key]; dup {
+				add(line, "%s: %s is already listed on line %d", want.Title, key, first)
+				continue
+			}
+			seen[key] = line
+			n, ok := wantRows[key]
+			if !ok {
+				add(line, "%s: %s is not in the configs", want.Title, key)
+				continue
+			}
+			w := want.Rows[n]
+			for c := 1; c < len(w); c++ {
+				if cols[c] >= 0 && cell(r, cols[c]) != w[c] {
+					add(line, "%s: %s %s is %s, the configs have %s", want.Title, key, want.Header[c], cell(r, cols[c]), w[c])
+					out[len(out)-1].Source = src(want.Refs[n][c])
+				}
+			}
+		}
+		for _, w := range want.Rows {
+			if _, ok := seen[w[0]]; !ok {
+				add(got.Line, "%s: %s is missing", want.Title, w[0])
+			}
+		}
+	}
+	return out
+}
+
+// sources returns a func giving the file:line of a ref, or "" when the
+// device has no startup config to read or the path matches no line.
+func sources(inv *inventory.Inventory, dir string) func(Ref) string {
+	roots := map[string]*eosconfig.Node{}
+	return func(r Ref) string {
+		d, ok := inv.Get(r.Device)
+		if !ok || d.StartupConfig == "" {
+			return ""
+		}
+		file := d.StartupConfig
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		root, seen := roots[file]
+		if !seen {
+			if b, err := os.ReadFile(file); err == nil {
+				root, _ = eosconfig.ParseString(string(b))
+			}
+			roots[file] = root
+		}
+		if root == nil {
+			return ""
+		}
+		lines := root.Select(r.Path...)
+		if len(lines) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%s:%d", file, lines[len(lines)-1].Line)
+	}
+}
+
+// cell returns column c of row r, "" past its end.
+func cell(r []string, c int) string {
+	if c < len(r) {
+		return r[c]
+	}
+	return ""
+}
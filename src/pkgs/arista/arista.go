@@ -36,10 +36,10 @@ type Peer struct {
 	PrefixAdvertised int     `json:"prefixAdvertised"`
 }
 
-// Version represents the version of the device. 
+// Version represents the version of the device.
 type VersionResp struct {
-	Jsonrpc string `json:"jsonrpc"`
-	ID      int    `json:"id"`
+	Jsonrpc string           `json:"jsonrpc"`
+	ID      int              `json:"id"`
 	Result  []VersionDetails `json:"result"`
 }
 
@@ -63,4 +63,4 @@ type VersionDetails struct {
 	MemTotal           int     `json:"memTotal"`
 	MemFree            int     `json:"memFree"`
 	IsIntlVersion      bool    `json:"isIntlVersion"`
-}
\ No newline at end of file
+}
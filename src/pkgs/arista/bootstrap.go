@@ -0,0 +1,169 @@
+package arista
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/logging"
+)
+
+// BootstrapOptions controls how eAPI is enabled on a node.
+type BootstrapOptions struct {
+	Protocol string        // "https" (default) or "http"
+	VRF      string        // VRF eAPI should serve; "" or "default" for the default VRF
+	User     string        // API user to create when missing
+	Password string        // its password
+	Save     bool          // copy running-config to startup-config afterwards
+	Wait     time.Duration // how long to wait for eAPI to answer; 15s by default
+}
+
+// BootstrapResult reports what Bootstrap found and changed.
+type BootstrapResult struct {
+	AlreadyEnabled bool     `json:"alreadyEnabled"`
+	UserCreated    bool     `json:"userCreated"`
+	Applied        []string `json:"applied,omitempty"` // secrets redacted
+	Reachable      bool     `json:"reachable"`
+}
+
+// eapiStatus is the part of "show management api http-commands" we need.
+type eapiStatus struct {
+	Enabled     bool `json:"enabled"`
+	HTTPSServer struct {
+		Configured bool `json:"configured"`
+	} `json:"httpsServer"`
+	HTTPServer struct {
+		Configured bool `json:"configured"`
+	} `json:"httpServer"`
+	VRFs map[string]any `json:"vrfs"`
+}
+
+// bootstrapPoll is how often Bootstrap probes eAPI while waiting for it.
+var bootstrapPoll = time.Second
+
+// Bootstrap enables eAPI on a node whose startup config lacks it. It
+// inspects the node through cli (normally a docker exec client, since
+// eAPI is not there yet), configures "management api http-commands" with
+// the requested protocol and VRF, creates the API user if needed and then
+// polls probe until eAPI answers or ctx is done. The clients should be
+// bound to ctx as well, see WithContext.
+func Bootstrap(ctx context.Context, cli, probe Client, opts BootstrapOptions) (BootstrapResult, error) {
+	var res BootstrapResult
+	if opts.Protocol == "" {
+		opts.Protocol = "https"
+	}
+	if opts.Protocol != "https" && opts.Protocol != "http" {
+		return res, fmt.Errorf("bootstrap: unsupported protocol %q", opts.Protocol)
+	}
+	if opts.VRF == "default" {
+		opts.VRF = ""
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = 15 * time.Second
+	}
+
+	var st struct {
+		Result []eapiStatus `json:"result"`
+	}
+	if err := runCmds(cli, "json", []string{"show management api http-commands"}, &st); err != nil {
+		return res, fmt.Errorf("bootstrap: read eAPI status: %w", err)
+	}
+	if len(st.Result) != 1 {
+		return res, errors.New("bootstrap: unexpected eAPI status response")
+	}
+	res.AlreadyEnabled = eapiReady(st.Result[0], opts)
+
+	var cfg []string
+	if opts.User != "" {
+		var users struct {
+			Result []struct {
+				Output string `json:"output"`
+			} `json:"result"`
+		}
+		if err := runCmds(cli, "text", []string{"show running-config section username"}, &users); err != nil {
+			return res, fmt.Errorf("bootstrap: read users: %w", err)
+		}
+		if len(users.Result) != 1 || !hasUser(users.Result[0].Output, opts.User) {
+			cfg = append(cfg, fmt.Sprintf("username %s privilege 15 secret %s", opts.User, opts.Password))
+			res.UserCreated = true
+		}
+	}
+	if !res.AlreadyEnabled {
+		cfg = append(cfg, "management api http-commands")
+		if opts.Protocol == "http" {
+			cfg = append(cfg, "no protocol https", "protocol http")
+		} else {
+			cfg = append(cfg, "protocol https")
+		}
+		cfg = append(cfg, "no shutdown")
+		if opts.VRF != "" {
+			cfg = append(cfg, "vrf "+opts.VRF, "no shutdown")
+		}
+	}
+
+	if len(cfg) > 0 {
+		cmds := append([]string{"enable", "configure"}, cfg...)
+		cmds = append(cmds, "end")
+		if opts.Save {
+			cmds = append(cmds, "copy running-config startup-config")
+		}
+		var out rpcResponse
+		if err := runCmds(cli, "json", cmds, &out); err != nil {
+			return res, fmt.Errorf("bootstrap: apply: %w", err)
+		}
+		// the result goes back to browsers: keep the password out of it
+		for _, line := range cfg {
+			res.Applied = append(res.Applied, logging.Redact(line))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Wait)
+	defer cancel()
+	for {
+		var out VersionResp
+		err := runCmds(probe, "json", []string{"show version"}, &out)
+		if err == nil && len(out.Result) == 1 {
+			res.Reachable = true
+			return res, nil
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			return res, fmt.Errorf("bootstrap: eAPI still unreachable: %w", err)
+		case <-time.After(bootstrapPoll):
+		}
+	}
+}
+
+// eapiReady reports whether eAPI already runs the way opts asks for.
+func eapiReady(st eapiStatus, opts BootstrapOptions) bool {
+	if !st.Enabled {
+		return false
+	}
+	if opts.Protocol == "https" && !st.HTTPSServer.Configured {
+		return false
+	}
+	if opts.Protocol == "http" && !st.HTTPServer.Configured {
+		return false
+	}
+	if opts.VRF != "" {
+		_, ok := st.VRFs[opts.VRF]
+		return ok
+	}
+	return true
+}
+
+// hasUser reports whether a "username" running-config section defines user.
+func hasUser(section, user string) bool {
+	for _, line := range strings.Split(section, "\n") {
+		f := strings.Fields(line)
+		if len(f) >= 2 && f[0] == "username" && f[1] == user {
+			return true
+		}
+	}
+	return false
+}
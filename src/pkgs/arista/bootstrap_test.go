@@ -0,0 +1,120 @@
+package arista
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeNode answers runCmds payloads from a map of canned results and
+// records every command it was asked to run.
+type fakeNode struct {
+	answers map[string]string
+	ran     []string
+	err     error
+}
+
+func (f *fakeNode) Run(reqBody []byte, cmdResp any) error {
+	if f.err != nil {
+		return f.err
+	}
+	req, err := decodeRequest(reqBody)
+	if err != nil {
+		return err
+	}
+	var results []json.RawMessage
+	for _, cmd := range req.Params.Cmds {
+		f.ran = append(f.ran, cmd)
+		out, ok := f.answers[cmd]
+		if !ok {
+			out = "{}"
+		}
+		results = append(results, json.RawMessage(out))
+	}
+	return encodeResponse(req.ID, results, cmdResp)
+}
+
+func TestBootstrapEnablesMissingEAPI(t *testing.T) {
+	bootstrapPoll = time.Millisecond
+	cli := &fakeNode{answers: map[string]string{
+		"show management api http-commands":    `{"enabled": false, "httpsServer": {"configured": false}, "vrfs": {}}`,
+		"show running-config section username": `{"output": "username ops privilege 15 secret sha512 $6$x\n"}`,
+	}}
+	probe := &fakeNode{answers: map[string]string{"show version": `{"modelName": "cEOSLab"}`}}
+
+	res, err := Bootstrap(context.Background(), cli, probe, BootstrapOptions{VRF: "MGMT", User: "admin", Password: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AlreadyEnabled || !res.UserCreated || !res.Reachable {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	want := []string{
+		"username admin privilege 15 secret <redacted>",
+		"management api http-commands",
+		"protocol https",
+		"no shutdown",
+		"vrf MGMT",
+		"no shutdown",
+	}
+	if strings.Join(res.Applied, "\n") != strings.Join(want, "\n") {
+		t.Errorf("applied:\n%s\nwant:\n%s", strings.Join(res.Applied, "\n"), strings.Join(want, "\n"))
+	}
+	if got := strings.Join(cli.ran, "|"); !strings.Contains(got, "configure|username admin") || !strings.HasSuffix(got, "|end") {
+		t.Errorf("unexpected command sequence %q", got)
+	}
+}
+
+func TestBootstrapLeavesConfiguredNodeAlone(t *testing.T) {
+	bootstrapPoll = time.Millisecond
+	cli := &fakeNode{answers: map[string]string{
+		"show management api http-commands":    `{"enabled": true, "httpsServer": {"configured": true}, "vrfs": {"default": {}}}`,
+		"show running-config section username": `{"output": "username admin privilege 15 secret sha512 $6$x\n"}`,
+	}}
+	probe := &fakeNode{answers: map[string]string{"show version": `{"modelName": "cEOSLab"}`}}
+
+	res, err := Bootstrap(context.Background(), cli, probe, BootstrapOptions{User: "admin", Password: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.AlreadyEnabled || res.UserCreated || len(res.Applied) != 0 || !res.Reachable {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	for _, cmd := range cli.ran {
+		if cmd == "configure" {
+			t.Fatal("configured a node that needed nothing")
+		}
+	}
+}
+
+func TestBootstrapReportsUnreachable(t *testing.T) {
+	bootstrapPoll = time.Millisecond
+	cli := &fakeNode{answers: map[string]string{
+		"show management api http-commands": `{"enabled": true, "httpsServer": {"configured": true}}`,
+	}}
+	probe := &fakeNode{err: syscall.ECONNREFUSED}
+
+	res, err := Bootstrap(context.Background(), cli, probe, BootstrapOptions{Wait: 5 * time.Millisecond})
+	if err == nil || !IsRefused(err) || res.Reachable {
+		t.Fatalf("expected unreachable error, got %v (%+v)", err, res)
+	}
+}
+
+func TestBootstrapStopsWithContext(t *testing.T) {
+	bootstrapPoll = time.Millisecond
+	cli := &fakeNode{answers: map[string]string{
+		"show management api http-commands": `{"enabled": true, "httpsServer": {"configured": true}}`,
+	}}
+	probe := &fakeNode{err: syscall.ECONNREFUSED}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := Bootstrap(ctx, cli, probe, BootstrapOptions{Wait: time.Minute})
+	if err == nil || time.Since(start) > 10*time.Second {
+		t.Fatalf("waited %s past the context, err %v", time.Since(start), err)
+	}
+}
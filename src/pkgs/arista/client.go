@@ -2,6 +2,7 @@ package arista
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -10,10 +11,21 @@ import (
 	"time"
 )
 
+// Client is implemented by every transport that can run EOS commands.
+// Run takes a rendered JSON-RPC runCmds payload and decodes the JSON-RPC
+// response into cmdResp, so callers get the same typed results no matter
+// how the node was reached.
+type Client interface {
+	Run(reqBody []byte, cmdResp any) error
+}
+
 // eosClient logically represents an EOS client.
 type eosClient struct {
 	url        string
+	username   string
+	password   string
 	httpClient *http.Client
+	ctx        context.Context
 }
 
 // NewEosClient is a factory function to stand up an EOS client.
@@ -32,21 +44,40 @@ func NewEosClient(url string) eosClient {
 	return client
 }
 
-// getCreds is a helper function to retrieve device credentials. 
+// WithCreds returns a copy of the client that authenticates as user/pass.
+func (c eosClient) WithCreds(user, pass string) eosClient {
+	c.username, c.password = user, pass
+	return c
+}
+
+// WithContext returns a copy of the client whose requests are cancelled
+// with ctx, on top of the HTTP client's timeout.
+func (c eosClient) WithContext(ctx context.Context) eosClient {
+	c.ctx = ctx
+	return c
+}
+
+// getCreds is a helper function to retrieve device credentials.
 func (c eosClient) getCreds() (string, string) {
+	if c.username != "" || c.password != "" {
+		return c.username, c.password
+	}
 	// TODO: this should be a call to a vault
 	username := "admin"
 	password := "admin"
 	return username, password
 }
 
-// Run executes the request body against the client target device. 
+// Run executes the request body against the client target device.
 func (c eosClient) Run(reqBody []byte, cmdResp any) error {
 	// Create a new POST request with a body and custom headers
-	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(reqBody))
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(reqBody))
 	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return fmt.Errorf("Error creating request:", err)
+		return fmt.Errorf("Error creating request: %w", err)
 	}
 
 	username, password := c.getCreds()
@@ -57,19 +88,17 @@ func (c eosClient) Run(reqBody []byte, cmdResp any) error {
 	// Execute the request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		fmt.Println("Error performing request:", err)
-		return fmt.Errorf("Error performing request:", err)
+		return fmt.Errorf("Error performing request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return fmt.Errorf("Error reading response body:", err)
+		return fmt.Errorf("Error reading response body: %w", err)
 	}
 
 	if err := json.Unmarshal(body, &cmdResp); err != nil {
-		return fmt.Errorf("Error unmarshalling resp body:", err)
+		return fmt.Errorf("Error unmarshalling resp body: %w", err)
 	}
 
 	return nil
@@ -1,52 +1,165 @@
 package arista
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+
 	"github.com/montybeatnik/arista-lab/laber/pkgs/renderer"
 )
 
-func (c eosClient) BGPSummary() (BGPEvpnSummaryResponse, error) {
+// BGPSummary runs "show bgp summary" over any transport.
+func BGPSummary(c Client) (BGPEvpnSummaryResponse, error) {
 	cmds := []string{"show bgp summary"}
-	tmplPath := "templates/eapi_payload.tmpl"
-	fmt.Println("rendering template...")
-	body, err := renderer.RenderTemplate(tmplPath, renderer.PayloadData{
+	slog.Debug("rendering template", "template", "eapi_payload", "cmds", cmds)
+	body, err := renderer.Render("eapi_payload", renderer.PayloadData{
 		Method:  "runCmds",
 		Version: 1,
 		Format:  "json",
 		Cmds:    cmds,
 	})
 	if err != nil {
-		fmt.Printf("failed to render template: %v\n", err)
 		return BGPEvpnSummaryResponse{}, fmt.Errorf("failed to render template: %v", err)
 	}
-	fmt.Println("running cmd...")
 	var bgpEvpnSummaryResp BGPEvpnSummaryResponse
 	if err := c.Run(body, &bgpEvpnSummaryResp); err != nil {
-		fmt.Printf("Run failed: %v\n", err)
 		return BGPEvpnSummaryResponse{}, fmt.Errorf("run failed: %v", err)
 	}
 	return bgpEvpnSummaryResp, nil
 }
 
-func (c eosClient) Version() (VersionResp, error) {
+// Version runs "show version" over any transport.
+func Version(c Client) (VersionResp, error) {
 	cmds := []string{"show version"}
-	tmplPath := "templates/eapi_payload.tmpl"
-	fmt.Println("rendering template...")
-	body, err := renderer.RenderTemplate(tmplPath, renderer.PayloadData{
+	slog.Debug("rendering template", "template", "eapi_payload", "cmds", cmds)
+	body, err := renderer.Render("eapi_payload", renderer.PayloadData{
 		Method:  "runCmds",
 		Version: 1,
 		Format:  "json",
 		Cmds:    cmds,
 	})
 	if err != nil {
-		fmt.Printf("failed to render template: %v\n", err)
 		return VersionResp{}, fmt.Errorf("failed to render template: %v", err)
 	}
-	fmt.Println("running cmd...")
 	var versionResp VersionResp
 	if err := c.Run(body, &versionResp); err != nil {
-		fmt.Printf("Run failed: %v\n", err)
 		return VersionResp{}, fmt.Errorf("run failed: %v", err)
 	}
 	return versionResp, nil
-}
\ No newline at end of file
+}
+
+// runCmds renders a runCmds payload for cmds and runs it over c.
+func runCmds(c Client, format string, cmds []string, out any) error {
+	body, err := renderer.Render("eapi_payload", renderer.PayloadData{
+		Method:  "runCmds",
+		Version: 1,
+		Format:  format,
+		Cmds:    cmds,
+		ID:      1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render template: %v", err)
+	}
+	return c.Run(body, out)
+}
+
+// ConfigLines turns a rendered config fragment into the commands to send in
+// configuration mode: indentation, blank lines, "!" comments and "end" are
+// dropped.
+func ConfigLines(cfg string) []string {
+	var cmds []string
+	for _, line := range strings.Split(cfg, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "!") || line == "end" {
+			continue
+		}
+		cmds = append(cmds, line)
+	}
+	return cmds
+}
+
+// Configure enters configuration mode, applies lines and leaves it again,
+// optionally saving the result to startup-config.
+func Configure(c Client, lines []string, save bool) error {
+	if len(lines) == 0 {
+		return errors.New("configure: no commands")
+	}
+	cmds := append([]string{"enable", "configure"}, lines...)
+	cmds = append(cmds, "end")
+	if save {
+		cmds = append(cmds, "copy running-config startup-config")
+	}
+	var out struct {
+		Result []json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := runCmds(c, "json", cmds, &out); err != nil {
+		return fmt.Errorf("configure: %w", err)
+	}
+	if out.Error != nil {
+		return fmt.Errorf("configure: %s (code %d)", out.Error.Message, out.Error.Code)
+	}
+	return nil
+}
+
+// RunningConfig fetches "show running-config" as text.
+func RunningConfig(c Client) (string, error) {
+	var out struct {
+		Result []struct {
+			Output string `json:"output"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := runCmds(c, "text", []string{"show running-config"}, &out); err != nil {
+		return "", fmt.Errorf("running-config: %w", err)
+	}
+	if out.Error != nil {
+		return "", fmt.Errorf("running-config: %s", out.Error.Message)
+	}
+	if len(out.Result) != 1 {
+		return "", errors.New("running-config: unexpected response")
+	}
+	return out.Result[0].Output, nil
+}
+
+// ConfigSession stages lines in the named config session and returns the
+// session's diff against the running-config. With commit the session is
+// committed, otherwise it is aborted, which makes it a dry run.
+func ConfigSession(c Client, name string, lines []string, commit bool) (string, error) {
+	if len(lines) == 0 {
+		return "", errors.New("config session: no commands")
+	}
+	cmds := append([]string{"enable", "configure session " + name}, lines...)
+	cmds = append(cmds, "show session-config diffs")
+	if commit {
+		cmds = append(cmds, "commit")
+	} else {
+		cmds = append(cmds, "abort")
+	}
+	var out struct {
+		Result []struct {
+			Output string `json:"output"`
+		} `json:"result"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := runCmds(c, "text", cmds, &out); err != nil {
+		return "", fmt.Errorf("config session %s: %w", name, err)
+	}
+	if out.Error != nil {
+		return "", fmt.Errorf("config session %s: %s (code %d)", name, out.Error.Message, out.Error.Code)
+	}
+	if len(out.Result) != len(cmds) {
+		return "", fmt.Errorf("config session %s: unexpected response", name)
+	}
+	return out.Result[len(cmds)-2].Output, nil
+}
@@ -0,0 +1,72 @@
+package arista
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// clientFunc adapts a function to the Client interface.
+type clientFunc func(reqBody []byte, cmdResp any) error
+
+func (f clientFunc) Run(reqBody []byte, cmdResp any) error { return f(reqBody, cmdResp) }
+
+func TestConfigure(t *testing.T) {
+	lines := ConfigLines("router isis ISIS_BASE\n   net 49.0001.0A00.000B.00\n   !\n\ninterface Ethernet1\n   isis enable ISIS_BASE\n!\nend\n")
+	want := "router isis ISIS_BASE|net 49.0001.0A00.000B.00|interface Ethernet1|isis enable ISIS_BASE"
+	if got := strings.Join(lines, "|"); got != want {
+		t.Fatalf("ConfigLines = %q, want %q", got, want)
+	}
+
+	node := &fakeNode{}
+	if err := Configure(node, lines, true); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(node.ran, "|")
+	if got != "enable|configure|"+want+"|end|copy running-config startup-config" {
+		t.Errorf("ran %q", got)
+	}
+	if err := Configure(node, nil, false); err == nil {
+		t.Error("configured nothing without an error")
+	}
+}
+
+func TestConfigureReportsEAPIError(t *testing.T) {
+	c := clientFunc(func(reqBody []byte, cmdResp any) error {
+		return json.Unmarshal([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": 1002, "message": "CLI command 3 of 4 'bogus' failed: invalid command"}}`), cmdResp)
+	})
+	err := Configure(c, []string{"bogus"}, false)
+	if err == nil || !strings.Contains(err.Error(), "invalid command") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRunningConfig(t *testing.T) {
+	node := &fakeNode{answers: map[string]string{
+		"show running-config": `{"output": "hostname leaf1\n!\nend\n"}`,
+	}}
+	cfg, err := RunningConfig(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != "hostname leaf1\n!\nend\n" {
+		t.Errorf("got %q", cfg)
+	}
+}
+
+func TestConfigSession(t *testing.T) {
+	node := &fakeNode{answers: map[string]string{
+		"show session-config diffs": `{"output": "+router-id 10.0.0.12\n"}`,
+	}}
+	diff, err := ConfigSession(node, "preview", []string{"router bgp 65101", "router-id 10.0.0.12", "exit"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "+router-id 10.0.0.12\n" {
+		t.Errorf("diff = %q", diff)
+	}
+	want := "enable|configure session preview|router bgp 65101|router-id 10.0.0.12|exit|show session-config diffs|abort"
+	if got := strings.Join(node.ran, "|"); got != want {
+		t.Errorf("ran %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+package arista
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+)
+
+// execFunc runs argv inside a container and returns its stdout.
+type execFunc func(ctx context.Context, container string, useSudo bool, argv ...string) ([]byte, error)
+
+// dockerClient runs commands with "docker exec <container> Cli -p 15 -c".
+// It needs neither a management IP nor eAPI, so it works on nodes that
+// are still being bootstrapped.
+type dockerClient struct {
+	container string
+	useSudo   bool
+	timeout   time.Duration
+	exec      execFunc
+	ctx       context.Context
+}
+
+// NewDockerClient is a factory function to stand up a docker exec client
+// for a cEOS container such as "clab-evpn-rdma-fabric-leaf1".
+func NewDockerClient(container string, useSudo bool) dockerClient {
+	return dockerClient{
+		container: container,
+		useSudo:   useSudo,
+		timeout:   30 * time.Second,
+		exec:      clab.DockerExec,
+		ctx:       context.Background(),
+	}
+}
+
+// WithContext returns a copy of the client whose commands are cancelled
+// with ctx, on top of its own timeout.
+func (c dockerClient) WithContext(ctx context.Context) dockerClient {
+	c.ctx = ctx
+	return c
+}
+
+// Run executes the commands in reqBody through the container's CLI and
+// decodes the results into cmdResp as if they came back from eAPI.
+func (c dockerClient) Run(reqBody []byte, cmdResp any) error {
+	req, err := decodeRequest(reqBody)
+	if err != nil {
+		return err
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]json.RawMessage, 0, len(req.Params.Cmds))
+	var batch []string
+	// flush runs pending non-show commands in one Cli session so that
+	// configuration modes carry over from one line to the next.
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		out, err := c.cli(ctx, strings.Join(batch, "\n"))
+		if err != nil {
+			return err
+		}
+		if errs := errorLines(out); errs != "" {
+			return fmt.Errorf("commands %q: %s", batch, errs)
+		}
+		for range batch {
+			results = append(results, json.RawMessage("{}"))
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, cmd := range req.Params.Cmds {
+		cmd = strings.TrimSpace(cmd)
+		if !isShowCmd(cmd) {
+			if strings.EqualFold(cmd, "enable") && len(batch) == 0 {
+				// Cli -p 15 is already privileged
+				results = append(results, json.RawMessage("{}"))
+				continue
+			}
+			batch = append(batch, cmd)
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		out, err := c.cli(ctx, cliCommand(cmd, req.Params.Format))
+		if err != nil {
+			return err
+		}
+		res, err := parseCLIOutput(cmd, req.Params.Format, out)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return encodeResponse(req.ID, results, cmdResp)
+}
+
+// cli runs one Cli invocation at privilege level 15.
+func (c dockerClient) cli(ctx context.Context, cmds string) (string, error) {
+	out, err := c.exec(ctx, c.container, c.useSudo, "Cli", "-p", "15", "-c", cmds)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// errorLines keeps only the "% ..." lines of a batch's output.
+func errorLines(out string) string {
+	var errs []string
+	for _, l := range strings.Split(out, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(l), "%") {
+			errs = append(errs, strings.TrimSpace(l))
+		}
+	}
+	return strings.Join(errs, "\n")
+}
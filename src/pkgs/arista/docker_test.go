@@ -0,0 +1,56 @@
+package arista
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDockerClientRun(t *testing.T) {
+	var calls [][]string
+	client := NewDockerClient("clab-evpn-rdma-fabric-leaf1", true)
+	client.exec = func(_ context.Context, container string, useSudo bool, argv ...string) ([]byte, error) {
+		if container != "clab-evpn-rdma-fabric-leaf1" || !useSudo {
+			t.Errorf("exec on %q sudo=%v", container, useSudo)
+		}
+		calls = append(calls, argv)
+		switch argv[len(argv)-1] {
+		case "show version | json":
+			return []byte(`{"modelName": "cEOSLab", "version": "4.34.2.1F"}`), nil
+		case "show bogus | json":
+			return []byte("% Invalid input\n"), nil
+		}
+		return nil, nil
+	}
+
+	var resp VersionResp
+	body := []byte(`{"jsonrpc":"2.0","method":"runCmds","params":{"version":1,"format":"json","cmds":["enable","show version"]},"id":3}`)
+	if err := client.Run(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 3 || len(resp.Result) != 2 || resp.Result[1].ModelName != "cEOSLab" {
+		t.Fatalf("unexpected result: %+v", resp)
+	}
+	if len(calls) != 1 || strings.Join(calls[0], " ") != "Cli -p 15 -c show version | json" {
+		t.Fatalf("unexpected exec calls: %q", calls)
+	}
+
+	// consecutive config commands share one Cli session
+	calls = nil
+	var raw rpcResponse
+	body = []byte(`{"jsonrpc":"2.0","method":"runCmds","params":{"version":1,"format":"json","cmds":["enable","configure","management api http-commands","no shutdown","end","show version"]},"id":1}`)
+	if err := client.Run(body, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if len(raw.Result) != 6 {
+		t.Fatalf("got %d results, want 6", len(raw.Result))
+	}
+	if len(calls) != 2 || calls[0][4] != "configure\nmanagement api http-commands\nno shutdown\nend" {
+		t.Fatalf("unexpected exec calls: %q", calls)
+	}
+
+	body = []byte(`{"jsonrpc":"2.0","method":"runCmds","params":{"version":1,"format":"json","cmds":["show bogus"]},"id":1}`)
+	if err := client.Run(body, &raw); err == nil {
+		t.Fatal("expected error for invalid command")
+	}
+}
@@ -0,0 +1,61 @@
+package arista
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"syscall"
+)
+
+// fallbackClient tries the primary transport first and switches to the
+// secondary one when the node refuses the connection, which is what cEOS
+// does when eAPI was never enabled.
+type fallbackClient struct {
+	primary   Client
+	secondary Client
+	log       *slog.Logger
+}
+
+// NewFallbackClient is a factory function for a client that runs through
+// primary and retries through secondary when primary is refused.
+func NewFallbackClient(primary, secondary Client) fallbackClient {
+	return fallbackClient{primary: primary, secondary: secondary, log: slog.Default()}
+}
+
+// WithLogger returns a copy of the client that reports fallbacks to log.
+func (c fallbackClient) WithLogger(log *slog.Logger) fallbackClient {
+	c.log = log
+	return c
+}
+
+// NewClient returns the usual eAPI-with-SSH-fallback client for a node
+// reachable at ip.
+func NewClient(ip, user, pass string) fallbackClient {
+	eapi := NewEosClient("https://"+ip+"/command-api").WithCreds(user, pass)
+	cli := NewSSHClient(ip, SSHConfig{User: user, Password: pass})
+	return NewFallbackClient(eapi, cli)
+}
+
+// Run executes reqBody via the primary transport, falling back when it is
+// refused.
+func (c fallbackClient) Run(reqBody []byte, cmdResp any) error {
+	err := c.primary.Run(reqBody, cmdResp)
+	if err == nil || !IsRefused(err) {
+		return err
+	}
+	c.log.Warn("eAPI refused, falling back", "err", err)
+	if ferr := c.secondary.Run(reqBody, cmdResp); ferr != nil {
+		return errors.Join(err, ferr)
+	}
+	return nil
+}
+
+// IsRefused reports whether err means nothing is listening on the other
+// end, as opposed to a timeout or a failing command.
+func IsRefused(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial" && !op.Timeout()
+}
@@ -0,0 +1,54 @@
+package arista
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/logging"
+)
+
+// loggedClient records every Run with its command list and duration. At
+// debug level it also dumps the payload and the decoded response, with
+// passwords and secrets redacted.
+type loggedClient struct {
+	Client
+	log *slog.Logger
+}
+
+// WithLogging wraps c so that each Run is logged to log. Callers usually
+// pass a logger already tagged with the device name, mgmt IP and request ID.
+func WithLogging(c Client, log *slog.Logger) Client {
+	if log == nil {
+		log = slog.Default()
+	}
+	if fc, ok := c.(fallbackClient); ok {
+		c = fc.WithLogger(log)
+	}
+	return loggedClient{Client: c, log: log}
+}
+
+// Run executes reqBody through the wrapped client and logs the outcome.
+func (c loggedClient) Run(reqBody []byte, cmdResp any) error {
+	var cmds []string
+	if req, err := decodeRequest(reqBody); err == nil {
+		for _, cmd := range req.Params.Cmds {
+			cmds = append(cmds, logging.Redact(cmd))
+		}
+	}
+	start := time.Now()
+	err := c.Client.Run(reqBody, cmdResp)
+	log := c.log.With("cmds", cmds, "duration", time.Since(start))
+	if logging.Debug() {
+		resp, _ := json.Marshal(cmdResp)
+		log.Debug("eapi exchange",
+			"payload", logging.Redact(string(reqBody)),
+			"response", logging.Redact(string(resp)))
+	}
+	if err != nil {
+		log.Warn("run failed", "err", err)
+		return err
+	}
+	log.Info("run ok")
+	return nil
+}
@@ -0,0 +1,265 @@
+package arista
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHConfig holds what the SSH transport needs to log in to a node.
+type SSHConfig struct {
+	User           string
+	Password       string
+	EnablePassword string
+	Timeout        time.Duration
+}
+
+// sshClient runs commands through the EOS CLI over SSH. It is the fallback
+// for nodes whose startup config lacks "management api http-commands".
+type sshClient struct {
+	addr string
+	cfg  SSHConfig
+}
+
+// NewSSHClient is a factory function to stand up an SSH CLI client. addr
+// is host or host:port; port 22 is assumed when none is given.
+func NewSSHClient(addr string, cfg SSHConfig) sshClient {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	if cfg.User == "" && cfg.Password == "" {
+		cfg.User, cfg.Password = eosClient{}.getCreds()
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return sshClient{addr: addr, cfg: cfg}
+}
+
+// Run executes the commands in reqBody over an interactive CLI session and
+// decodes the results into cmdResp as if they came back from eAPI.
+func (c sshClient) Run(reqBody []byte, cmdResp any) error {
+	req, err := decodeRequest(reqBody)
+	if err != nil {
+		return err
+	}
+	sh, err := c.open()
+	if err != nil {
+		return err
+	}
+	defer sh.close()
+
+	results := make([]json.RawMessage, 0, len(req.Params.Cmds))
+	for _, cmd := range req.Params.Cmds {
+		if strings.EqualFold(strings.TrimSpace(cmd), "enable") {
+			// already privileged; eAPI answers enable with an empty object
+			results = append(results, json.RawMessage("{}"))
+			continue
+		}
+		out, err := sh.send(cliCommand(cmd, req.Params.Format))
+		if err != nil {
+			return fmt.Errorf("ssh %s: %w", c.addr, err)
+		}
+		res, err := parseCLIOutput(cmd, req.Params.Format, out)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+	}
+	return encodeResponse(req.ID, results, cmdResp)
+}
+
+// open dials the node, starts a shell, enters privileged mode and disables
+// pagination.
+func (c sshClient) open() (*cliShell, error) {
+	conf := &ssh.ClientConfig{
+		User: c.cfg.User,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(c.cfg.Password),
+			ssh.KeyboardInteractive(func(_, _ string, qs []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(qs))
+				for i := range qs {
+					answers[i] = c.cfg.Password
+				}
+				return answers, nil
+			}),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // lab only
+		Timeout:         c.cfg.Timeout,
+	}
+	conn, err := ssh.Dial("tcp", c.addr, conf)
+	if err != nil {
+		return nil, fmt.Errorf("ssh dial %s: %w", c.addr, err)
+	}
+	sess, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh session: %w", err)
+	}
+	sh := &cliShell{conn: conn, sess: sess, timeout: c.cfg.Timeout, chunks: make(chan []byte, 64), done: make(chan struct{})}
+	if err := sh.start(); err != nil {
+		sh.close()
+		return nil, err
+	}
+	if err := sh.enable(c.cfg.EnablePassword); err != nil {
+		sh.close()
+		return nil, err
+	}
+	if _, err := sh.send("terminal length 0"); err != nil {
+		sh.close()
+		return nil, err
+	}
+	return sh, nil
+}
+
+// promptRe matches an EOS prompt such as "leaf1>", "leaf1#" or
+// "leaf1(config-if-Et1)#" at the end of the buffered output.
+var promptRe = regexp.MustCompile(`(?m)^[\w.\-]+(\([\w.\-/]+\))?[>#] ?$`)
+
+// passwordRe matches the enable password prompt.
+var passwordRe = regexp.MustCompile(`(?i)password: ?$`)
+
+// cliShell is one interactive EOS CLI session.
+type cliShell struct {
+	conn    *ssh.Client
+	sess    *ssh.Session
+	stdin   io.WriteCloser
+	timeout time.Duration
+	chunks  chan []byte
+	done    chan struct{}
+	buf     bytes.Buffer
+	prompt  string
+}
+
+func (s *cliShell) start() error {
+	modes := ssh.TerminalModes{ssh.ECHO: 1, ssh.TTY_OP_ISPEED: 38400, ssh.TTY_OP_OSPEED: 38400}
+	// wide terminal so long lines don't wrap inside JSON output
+	if err := s.sess.RequestPty("vt100", 0, 511, modes); err != nil {
+		return fmt.Errorf("ssh pty: %w", err)
+	}
+	stdin, err := s.sess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := s.sess.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	s.stdin = stdin
+	go func() {
+		defer close(s.chunks)
+		for {
+			b := make([]byte, 4096)
+			n, err := stdout.Read(b)
+			if n > 0 {
+				select {
+				case s.chunks <- b[:n]:
+				case <-s.done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	if err := s.sess.Shell(); err != nil {
+		return fmt.Errorf("ssh shell: %w", err)
+	}
+	_, _, err = s.readUntil(promptRe)
+	return err
+}
+
+// enable moves the session to privileged mode when it logged in at ">".
+func (s *cliShell) enable(secret string) error {
+	if strings.HasSuffix(s.prompt, "#") {
+		return nil
+	}
+	if _, err := io.WriteString(s.stdin, "enable\n"); err != nil {
+		return err
+	}
+	_, matched, err := s.readUntil(promptRe, passwordRe)
+	if err != nil {
+		return err
+	}
+	if matched == passwordRe {
+		if _, err := io.WriteString(s.stdin, secret+"\n"); err != nil {
+			return err
+		}
+		if _, _, err := s.readUntil(promptRe); err != nil {
+			return err
+		}
+	}
+	if !strings.HasSuffix(s.prompt, "#") {
+		return errors.New("ssh: enable failed")
+	}
+	return nil
+}
+
+// send types one line and returns the output between the echoed command
+// and the next prompt.
+func (s *cliShell) send(line string) (string, error) {
+	if _, err := io.WriteString(s.stdin, line+"\n"); err != nil {
+		return "", err
+	}
+	out, _, err := s.readUntil(promptRe)
+	if err != nil {
+		return "", err
+	}
+	// drop the echoed command
+	if i := strings.IndexByte(out, '\n'); i >= 0 && strings.TrimSpace(out[:i]) == line {
+		out = out[i+1:]
+	}
+	return out, nil
+}
+
+// readUntil consumes output until one of the patterns matches the tail of
+// the buffer. It returns what was read before the match and which pattern
+// matched, leaving the buffer empty.
+func (s *cliShell) readUntil(patterns ...*regexp.Regexp) (string, *regexp.Regexp, error) {
+	deadline := time.After(s.timeout)
+	for {
+		text := strings.ReplaceAll(s.buf.String(), "\r", "")
+		for _, re := range patterns {
+			locs := re.FindAllStringIndex(text, -1)
+			if len(locs) == 0 {
+				continue
+			}
+			loc := locs[len(locs)-1]
+			if strings.TrimSpace(text[loc[1]:]) != "" {
+				continue
+			}
+			if re == promptRe {
+				s.prompt = strings.TrimSpace(text[loc[0]:loc[1]])
+			}
+			s.buf.Reset()
+			return text[:loc[0]], re, nil
+		}
+		select {
+		case b, ok := <-s.chunks:
+			if !ok {
+				return text, nil, errors.New("ssh: session closed")
+			}
+			s.buf.Write(b)
+		case <-deadline:
+			return text, nil, errors.New("ssh: timed out waiting for prompt")
+		}
+	}
+}
+
+func (s *cliShell) close() {
+	if s.stdin != nil {
+		_, _ = io.WriteString(s.stdin, "exit\n")
+	}
+	close(s.done)
+	s.sess.Close()
+	s.conn.Close()
+}
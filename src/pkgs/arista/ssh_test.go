@@ -0,0 +1,204 @@
+package arista
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeEOS is a local SSH server standing in for a cEOS CLI. It logs in at
+// the unprivileged prompt, echoes input like a PTY and answers a handful of
+// commands.
+type fakeEOS struct {
+	t        *testing.T
+	ln       net.Listener
+	hostname string
+	answers  map[string]string
+	typed    chan string
+}
+
+func newFakeEOS(t *testing.T, answers map[string]string) *fakeEOS {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "admin" && string(pass) == "admin" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("bad credentials for %s", c.User())
+		},
+	}
+	conf.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeEOS{t: t, ln: ln, hostname: "leaf1", answers: answers, typed: make(chan string, 64)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(nc, conf)
+		}
+	}()
+	return f
+}
+
+func (f *fakeEOS) serve(nc net.Conn, conf *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, conf)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		if nch.ChannelType() != "session" {
+			nch.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range chReqs {
+				req.Reply(req.Type == "pty-req" || req.Type == "shell", nil)
+				if req.Type == "shell" {
+					go f.cli(ch)
+				}
+			}
+		}()
+	}
+}
+
+func (f *fakeEOS) cli(ch ssh.Channel) {
+	defer ch.Close()
+	prompt := f.hostname + ">"
+	paging := true
+	io.WriteString(ch, "Last login: never\r\n"+prompt)
+	sc := bufio.NewScanner(ch)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		io.WriteString(ch, line+"\r\n") // echo
+		f.typed <- line
+		switch {
+		case line == "exit":
+			return
+		case line == "enable":
+			prompt = f.hostname + "#"
+		case line == "terminal length 0":
+			paging = false
+			io.WriteString(ch, "Pagination disabled.\r\n")
+		case strings.HasSuffix(prompt, ">") && strings.HasPrefix(line, "show"):
+			io.WriteString(ch, "% Invalid input (privileged mode required)\r\n")
+		default:
+			out, ok := f.answers[line]
+			if !ok {
+				out = "% Invalid input"
+			}
+			if paging {
+				out = " --More-- "
+			}
+			io.WriteString(ch, strings.ReplaceAll(out, "\n", "\r\n")+"\r\n")
+		}
+		io.WriteString(ch, prompt)
+	}
+}
+
+func TestSSHClientRun(t *testing.T) {
+	srv := newFakeEOS(t, map[string]string{
+		"show version | json": `{
+  "modelName": "cEOSLab",
+  "version": "4.34.2.1F"
+}`,
+		"show clock": "Mon Oct 19 10:00:00 2026",
+	})
+	client := NewSSHClient(srv.ln.Addr().String(), SSHConfig{User: "admin", Password: "admin"})
+
+	var resp VersionResp
+	body := []byte(`{"jsonrpc":"2.0","method":"runCmds","params":{"version":1,"format":"json","cmds":["enable","show version"]},"id":7}`)
+	if err := client.Run(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 7 || len(resp.Result) != 2 {
+		t.Fatalf("unexpected envelope: %+v", resp)
+	}
+	if got := resp.Result[1].ModelName; got != "cEOSLab" {
+		t.Errorf("modelName = %q, want cEOSLab", got)
+	}
+
+	var typed []string
+	for len(srv.typed) > 0 {
+		typed = append(typed, <-srv.typed)
+	}
+	want := []string{"enable", "terminal length 0", "show version | json"}
+	if strings.Join(typed[:3], ",") != strings.Join(want, ",") {
+		t.Errorf("typed %q, want prefix %q", typed, want)
+	}
+}
+
+func TestSSHClientTextAndErrors(t *testing.T) {
+	srv := newFakeEOS(t, map[string]string{"show clock": "Mon Oct 19 10:00:00 2026"})
+	client := NewSSHClient(srv.ln.Addr().String(), SSHConfig{})
+
+	var resp struct {
+		Result []struct {
+			Output string `json:"output"`
+		} `json:"result"`
+	}
+	body := []byte(`{"jsonrpc":"2.0","method":"runCmds","params":{"version":1,"format":"text","cmds":["show clock"]},"id":1}`)
+	if err := client.Run(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Result) != 1 || !strings.Contains(resp.Result[0].Output, "2026") {
+		t.Fatalf("unexpected text result: %+v", resp)
+	}
+
+	body = []byte(`{"jsonrpc":"2.0","method":"runCmds","params":{"version":1,"format":"json","cmds":["show bogus"]},"id":1}`)
+	if err := client.Run(body, &resp); err == nil || !strings.Contains(err.Error(), "Invalid input") {
+		t.Fatalf("expected CLI error, got %v", err)
+	}
+}
+
+func TestFallbackOnRefusedEAPI(t *testing.T) {
+	srv := newFakeEOS(t, map[string]string{
+		"show version | json": `{"modelName": "cEOSLab"}`,
+	})
+
+	// grab a port and close it so eAPI is refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	ln.Close()
+
+	eapi := NewEosClient("https://" + closed + "/command-api")
+	client := NewFallbackClient(eapi, NewSSHClient(srv.ln.Addr().String(), SSHConfig{}))
+
+	var resp VersionResp
+	body := []byte(`{"jsonrpc":"2.0","method":"runCmds","params":{"version":1,"format":"json","cmds":["show version"]},"id":1}`)
+	if err := client.Run(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Result) != 1 || resp.Result[0].ModelName != "cEOSLab" {
+		t.Fatalf("unexpected result: %+v", resp)
+	}
+}
@@ -0,0 +1,93 @@
+package arista
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// rpcRequest is the subset of a runCmds payload the CLI transports need.
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  struct {
+		Version int      `json:"version"`
+		Format  string   `json:"format"`
+		Cmds    []string `json:"cmds"`
+	} `json:"params"`
+	ID json.RawMessage `json:"id"`
+}
+
+// rpcResponse mirrors the JSON-RPC envelope eAPI answers with.
+type rpcResponse struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Result  []json.RawMessage `json:"result"`
+}
+
+// decodeRequest pulls the commands and output format out of a rendered payload.
+func decodeRequest(reqBody []byte) (rpcRequest, error) {
+	var req rpcRequest
+	if err := json.Unmarshal(reqBody, &req); err != nil {
+		return req, fmt.Errorf("decode payload: %w", err)
+	}
+	if req.Method != "" && req.Method != "runCmds" {
+		return req, fmt.Errorf("unsupported method %q", req.Method)
+	}
+	if req.Params.Format == "" {
+		req.Params.Format = "json"
+	}
+	if len(req.ID) == 0 {
+		req.ID = json.RawMessage("1")
+	}
+	return req, nil
+}
+
+// encodeResponse wraps per-command results the way eAPI would and decodes
+// them into cmdResp.
+func encodeResponse(id json.RawMessage, results []json.RawMessage, cmdResp any) error {
+	b, err := json.Marshal(rpcResponse{JSONRPC: "2.0", ID: id, Result: results})
+	if err != nil {
+		return fmt.Errorf("encode response: %w", err)
+	}
+	if err := json.Unmarshal(b, &cmdResp); err != nil {
+		return fmt.Errorf("Error unmarshalling resp body: %w", err)
+	}
+	return nil
+}
+
+// isShowCmd reports whether cmd produces structured output with "| json".
+func isShowCmd(cmd string) bool {
+	f := strings.Fields(strings.ToLower(cmd))
+	return len(f) > 0 && strings.HasPrefix("show", f[0]) && len(f[0]) >= 2
+}
+
+// cliCommand is the line typed at the EOS CLI for one eAPI command.
+func cliCommand(cmd, format string) string {
+	cmd = strings.TrimSpace(cmd)
+	if format == "json" && isShowCmd(cmd) && !strings.Contains(cmd, "|") {
+		return cmd + " | json"
+	}
+	return cmd
+}
+
+// parseCLIOutput turns raw CLI output into the result eAPI would return for
+// the same command: the JSON document for show commands, {"output": ...}
+// for text format and {} for everything else.
+func parseCLIOutput(cmd, format, out string) (json.RawMessage, error) {
+	out = strings.TrimSpace(strings.ReplaceAll(out, "\r", ""))
+	if strings.HasPrefix(out, "%") {
+		return nil, fmt.Errorf("command %q: %s", cmd, out)
+	}
+	if format == "text" {
+		b, err := json.Marshal(map[string]string{"output": out + "\n"})
+		return b, err
+	}
+	if !isShowCmd(cmd) || out == "" {
+		return json.RawMessage("{}"), nil
+	}
+	if !json.Valid([]byte(out)) {
+		return nil, fmt.Errorf("command %q: output is not JSON", cmd)
+	}
+	return json.RawMessage(out), nil
+}
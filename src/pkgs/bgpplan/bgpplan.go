@@ -0,0 +1,286 @@
+// Package bgpplan holds the fabric's BGP numbering policies: which ASN
+// each spine and leaf runs (the ASN model) and how VLAN route
+// distinguishers and route targets are written (the RD/RT schemes). The
+// fabric generator builds devices from a Plan and the validators check
+// devices against one, so moving the fabric from eBGP to iBGP EVPN means
+// changing Plan.Model and regenerating.
+//
+// Both kinds of policy are pluggable: RegisterModel and RegisterScheme add
+// new ones next to the built-in models ebgp-per-leaf, ebgp-per-rack,
+// shared-spine and ibgp-rr and the schemes router-id, asn and auto.
+package bgpplan
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Names of the built-in models and schemes.
+const (
+	EBGPPerLeaf = "ebgp-per-leaf" // spines share an ASN, every leaf has its own
+	EBGPPerRack = "ebgp-per-rack" // the leaves of a rack share an ASN
+	SharedSpine = "shared-spine"  // one ASN for the spines, one for all leaves
+	IBGPRR      = "ibgp-rr"       // one ASN, the spines reflect routes
+
+	SchemeRouterID = "router-id" // <router-id>:<vlan>
+	SchemeASN      = "asn"       // <asn>:<vni>
+	SchemeAuto     = "auto"      // left to EOS
+)
+
+// Plan is a fabric's BGP numbering.
+type Plan struct {
+	Model         string `yaml:"model" json:"model"`
+	Spine         int    `yaml:"spine" json:"spine"`                 // ASN of the spines, and of everything under iBGP
+	Leaf          int    `yaml:"leaf" json:"leaf"`                   // ASN of leaf1 (or rack1), or of all leaves
+	LeavesPerRack int    `yaml:"leavesPerRack" json:"leavesPerRack"` // for ebgp-per-rack
+	RDScheme      string `yaml:"rd" json:"rd"`
+	RTScheme      string `yaml:"rt" json:"rt"`
+}
+
+// Default is the numbering of the original lab.
+func Default() Plan {
+	return Plan{Model: EBGPPerLeaf, Spine: 65000, Leaf: 65101, LeavesPerRack: 2, RDScheme: SchemeRouterID, RTScheme: SchemeASN}
+}
+
+// SetDefaults fills the zero fields of p from Default.
+func (p *Plan) SetDefaults() {
+	d := Default()
+	if p.Model == "" {
+		p.Model = d.Model
+	}
+	if p.Spine == 0 {
+		p.Spine = d.Spine
+	}
+	if p.Leaf == 0 {
+		p.Leaf = d.Leaf
+	}
+	if p.LeavesPerRack == 0 {
+		p.LeavesPerRack = d.LeavesPerRack
+	}
+	if p.RDScheme == "" {
+		p.RDScheme = d.RDScheme
+	}
+	if p.RTScheme == "" {
+		p.RTScheme = d.RTScheme
+	}
+}
+
+// Node is a BGP speaker as the models see it.
+type Node struct {
+	Role  string // "spine" or "leaf"
+	Index int    // 1-based among the nodes of its role
+	Rack  int    // 1-based; 0 derives it from Index and LeavesPerRack
+}
+
+// Model assigns ASNs and decides the session options that go with them.
+type Model interface {
+	ASN(p Plan, n Node) int
+	// IBGP reports whether spines and leaves share an ASN. The spines then
+	// reflect routes and the overlay sessions are single hop.
+	IBGP() bool
+	// AllowASIn is how often a leaf must accept its own ASN in a path:
+	// non-zero when leaves share ASNs across spines.
+	AllowASIn() int
+}
+
+// Scheme formats an RD or RT. asn is the device's ASN for RDs and the
+// fabric ASN (Plan.Spine) for RTs.
+type Scheme func(routerID string, asn, vlan, vni int) (string, error)
+
+var (
+	models  = map[string]Model{}
+	schemes = map[string]Scheme{}
+)
+
+// RegisterModel makes an ASN model available under name.
+func RegisterModel(name string, m Model) { models[name] = m }
+
+// RegisterScheme makes an RD/RT scheme available under name.
+func RegisterScheme(name string, s Scheme) { schemes[name] = s }
+
+// Models and Schemes list the registered names.
+func Models() []string  { return sortedKeys(models) }
+func Schemes() []string { return sortedKeys(schemes) }
+
+func sortedKeys[V any](m map[string]V) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	slices.Sort(out)
+	return out
+}
+
+type perLeaf struct{}
+
+func (perLeaf) ASN(p Plan, n Node) int {
+	if n.Role == "spine" {
+		return p.Spine
+	}
+	return p.Leaf + n.Index - 1
+}
+func (perLeaf) IBGP() bool     { return false }
+func (perLeaf) AllowASIn() int { return 0 }
+
+type perRack struct{}
+
+func (perRack) ASN(p Plan, n Node) int {
+	if n.Role == "spine" {
+		return p.Spine
+	}
+	return p.Leaf + p.rack(n) - 1
+}
+func (perRack) IBGP() bool     { return false }
+func (perRack) AllowASIn() int { return 1 }
+
+type sharedSpine struct{}
+
+func (sharedSpine) ASN(p Plan, n Node) int {
+	if n.Role == "spine" {
+		return p.Spine
+	}
+	return p.Leaf
+}
+func (sharedSpine) IBGP() bool     { return false }
+func (sharedSpine) AllowASIn() int { return 1 }
+
+type ibgpRR struct{}
+
+func (ibgpRR) ASN(p Plan, _ Node) int { return p.Spine }
+func (ibgpRR) IBGP() bool             { return true }
+func (ibgpRR) AllowASIn() int         { return 0 }
+
+func init() {
+	RegisterModel(EBGPPerLeaf, perLeaf{})
+	RegisterModel(EBGPPerRack, perRack{})
+	RegisterModel(SharedSpine, sharedSpine{})
+	RegisterModel(IBGPRR, ibgpRR{})
+
+	RegisterScheme(SchemeRouterID, func(routerID string, _, vlan, _ int) (string, error) {
+		return netmath.RD(routerID, vlan)
+	})
+	RegisterScheme(SchemeASN, func(_ string, asn, _, vni int) (string, error) {
+		return netmath.RouteTarget(asn, vni)
+	})
+	RegisterScheme(SchemeAuto, func(string, int, int, int) (string, error) {
+		return "auto", nil
+	})
+}
+
+func (p Plan) rack(n Node) int {
+	if n.Rack > 0 {
+		return n.Rack
+	}
+	return (n.Index-1)/max(p.LeavesPerRack, 1) + 1
+}
+
+func (p Plan) model() Model { return models[p.Model] }
+
+// Validate checks that the model and schemes exist and fit together: RDs
+// from the ASN need a unique ASN per leaf, and route targets must come out
+// the same on every leaf.
+func (p Plan) Validate() error {
+	m, ok := models[p.Model]
+	if !ok {
+		return fmt.Errorf("unknown ASN model %q (have %s)", p.Model, strings.Join(Models(), ", "))
+	}
+	for _, s := range []string{p.RDScheme, p.RTScheme} {
+		if _, ok := schemes[s]; !ok {
+			return fmt.Errorf("unknown RD/RT scheme %q (have %s)", s, strings.Join(Schemes(), ", "))
+		}
+	}
+	if p.Spine <= 0 || p.Leaf <= 0 {
+		return fmt.Errorf("ASNs must be positive, have spine %d and leaf %d", p.Spine, p.Leaf)
+	}
+	leafASNsUnique := p.Model == EBGPPerLeaf || p.Model == EBGPPerRack && p.LeavesPerRack == 1
+	switch {
+	case p.RTScheme == SchemeRouterID:
+		return fmt.Errorf("route targets from the router ID differ per leaf and never import")
+	case p.RDScheme == SchemeASN && !leafASNsUnique:
+		return fmt.Errorf("RDs from the ASN repeat under %s, where leaves share ASNs", p.Model)
+	case p.RTScheme == SchemeAuto && !m.IBGP() && p.Model != SharedSpine:
+		// EOS derives auto RTs from the local ASN
+		return fmt.Errorf("auto route targets differ between leaves under %s", p.Model)
+	}
+	return nil
+}
+
+// ASN is the AS number of n.
+func (p Plan) ASN(n Node) int { return p.model().ASN(p, n) }
+
+// IBGP reports whether the fabric runs iBGP.
+func (p Plan) IBGP() bool { return p.model().IBGP() }
+
+// AllowASIn is the allowas-in count the leaves need.
+func (p Plan) AllowASIn() int { return p.model().AllowASIn() }
+
+// RD is the route distinguisher of vlan on a device with routerID and asn.
+func (p Plan) RD(routerID string, asn, vlan, vni int) (string, error) {
+	return schemes[p.RDScheme](routerID, asn, vlan, vni)
+}
+
+// RouteTarget is the route target of vni, the same on every leaf.
+func (p Plan) RouteTarget(vlan, vni int) (string, error) {
+	return schemes[p.RTScheme]("", p.Spine, vlan, vni)
+}
+
+// Mismatch is a device setting the plan disagrees with.
+type Mismatch struct {
+	Device  string `json:"device"`
+	Message string `json:"message"`
+}
+
+func (m Mismatch) String() string { return m.Device + ": " + m.Message }
+
+var indexRe = regexp.MustCompile(`(\d+)$`)
+
+// Check compares the BGP settings of spines and leaves with the plan: the
+// ASN for their role and position (the number ending the hostname), the
+// session options of the model, and the RD and RTs of every VLAN.
+func (p Plan) Check(devs []devices.Device) []Mismatch {
+	var out []Mismatch
+	add := func(d devices.Device, format string, args ...any) {
+		out = append(out, Mismatch{Device: d.Hostname, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, d := range devs {
+		if d.BGP == nil || (d.Role != "spine" && d.Role != "leaf") {
+			continue
+		}
+		m := indexRe.FindStringSubmatch(d.Hostname)
+		if m == nil {
+			add(d, "no number at the end of the hostname to place it in the plan")
+			continue
+		}
+		idx, _ := strconv.Atoi(m[1])
+		if want := p.ASN(Node{Role: d.Role, Index: idx}); d.BGP.ASN != want {
+			add(d, "ASN %d, the %s plan gives %s%d ASN %d", d.BGP.ASN, p.Model, d.Role, idx, want)
+		}
+		if rr := d.Role == "spine" && p.IBGP(); d.BGP.RouteReflector != rr {
+			add(d, "route reflection is %v, %s wants %v", d.BGP.RouteReflector, p.Model, rr)
+		}
+		if want := p.AllowASIn(); d.Role == "leaf" && d.BGP.AllowASIn < want {
+			add(d, "allowas-in %d, %s needs %d", d.BGP.AllowASIn, p.Model, want)
+		}
+		if p.IBGP() && d.BGP.Overlay != nil && d.BGP.Overlay.EBGPMultihop > 0 {
+			add(d, "ebgp-multihop on %s under iBGP", d.BGP.Overlay.Name)
+		}
+		for _, v := range d.VLANs {
+			if v.VNI == 0 {
+				continue
+			}
+			if rd, err := p.RD(d.BGP.RouterID, d.BGP.ASN, v.ID, v.VNI); err == nil && v.RD != rd {
+				add(d, "vlan %d RD %s, the %s scheme gives %s", v.ID, v.RD, p.RDScheme, rd)
+			}
+			if rt, err := p.RouteTarget(v.ID, v.VNI); err == nil && v.RouteTarget != rt {
+				add(d, "vlan %d route target %s, the %s scheme gives %s", v.ID, v.RouteTarget, p.RTScheme, rt)
+			}
+		}
+	}
+	return out
+}
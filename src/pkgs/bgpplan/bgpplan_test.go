@@ -0,0 +1,169 @@
+package bgpplan
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+)
+
+func plan(model string) Plan {
+	p := Plan{Model: model}
+	if model == IBGPRR || model == SharedSpine {
+		p.RTScheme = SchemeAuto
+	}
+	p.SetDefaults()
+	return p
+}
+
+func TestModels(t *testing.T) {
+	leaves := func(p Plan) []int {
+		var asns []int
+		for i := 1; i <= 4; i++ {
+			asns = append(asns, p.ASN(Node{Role: "leaf", Index: i}))
+		}
+		return asns
+	}
+	for _, tc := range []struct {
+		model  string
+		spine  int
+		leaves [4]int
+		ibgp   bool
+		allow  int
+	}{
+		{EBGPPerLeaf, 65000, [4]int{65101, 65102, 65103, 65104}, false, 0},
+		{EBGPPerRack, 65000, [4]int{65101, 65101, 65102, 65102}, false, 1},
+		{SharedSpine, 65000, [4]int{65101, 65101, 65101, 65101}, false, 1},
+		{IBGPRR, 65000, [4]int{65000, 65000, 65000, 65000}, true, 0},
+	} {
+		p := plan(tc.model)
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: %v", tc.model, err)
+		}
+		got := leaves(p)
+		if s := p.ASN(Node{Role: "spine", Index: 2}); s != tc.spine || [4]int(got) != tc.leaves {
+			t.Errorf("%s: spine %d, leaves %v", tc.model, s, got)
+		}
+		if p.IBGP() != tc.ibgp || p.AllowASIn() != tc.allow {
+			t.Errorf("%s: ibgp %v, allowas-in %d", tc.model, p.IBGP(), p.AllowASIn())
+		}
+	}
+
+	p := plan(EBGPPerRack)
+	if asn := p.ASN(Node{Role: "leaf", Index: 1, Rack: 3}); asn != 65103 {
+		t.Errorf("explicit rack 3 = %d", asn)
+	}
+}
+
+func TestSchemes(t *testing.T) {
+	p := Default()
+	rd, _ := p.RD("10.0.0.11", 65101, 10, 10010)
+	rt, _ := p.RouteTarget(10, 10010)
+	if rd != "10.0.0.11:10" || rt != "65000:10010" {
+		t.Errorf("default rd %s rt %s", rd, rt)
+	}
+	p.RDScheme = SchemeASN
+	if rd, _ := p.RD("10.0.0.11", 65101, 10, 10010); rd != "65101:10010" {
+		t.Errorf("asn rd %s", rd)
+	}
+	p.RDScheme = SchemeAuto
+	if rd, _ := p.RD("10.0.0.11", 65101, 10, 10010); rd != "auto" {
+		t.Errorf("auto rd %s", rd)
+	}
+	if _, err := Default().RD("10.0.0", 65101, 10, 10010); err == nil {
+		t.Error("bad router ID accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		p    Plan
+		want string
+	}{
+		{Plan{Model: "ospf"}, "unknown ASN model"},
+		{Plan{RDScheme: "nope"}, "unknown RD/RT scheme"},
+		{Plan{Leaf: -1}, "must be positive"},
+		{Plan{RTScheme: SchemeRouterID}, "never import"},
+		{Plan{Model: SharedSpine, RDScheme: SchemeASN, RTScheme: SchemeAuto}, "RDs from the ASN repeat"},
+		{Plan{Model: EBGPPerLeaf, RTScheme: SchemeAuto}, "auto route targets differ"},
+	} {
+		tc.p.SetDefaults()
+		if err := tc.p.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: err = %v, want %q", tc.p, err, tc.want)
+		}
+	}
+	ok := Plan{Model: EBGPPerRack, LeavesPerRack: 1, RDScheme: SchemeASN}
+	ok.SetDefaults()
+	if err := ok.Validate(); err != nil {
+		t.Errorf("one leaf per rack: %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	leaf := func(name string, asn int) devices.Device {
+		return devices.Device{
+			Hostname: name,
+			Role:     "leaf",
+			BGP: &devices.BGP{
+				ASN:      asn,
+				RouterID: "10.0.0.11",
+				Overlay:  &devices.PeerGroup{Name: "SPINES-EVPN", EBGPMultihop: 3},
+			},
+			VLANs: []devices.VLAN{{ID: 10, VNI: 10010, RD: "10.0.0.11:10", RouteTarget: "65000:10010"}},
+		}
+	}
+	spine := devices.Device{Hostname: "spine1", Role: "spine", BGP: &devices.BGP{ASN: 65000}}
+	devs := []devices.Device{spine, leaf("leaf1", 65101), leaf("leaf2", 65101), leaf("leafx", 65101), {Hostname: "gpu1", Role: "host"}}
+
+	var got []string
+	for _, m := range Default().Check(devs) {
+		got = append(got, m.String())
+	}
+	want := []string{
+		"leaf2: ASN 65101, the ebgp-per-leaf plan gives leaf2 ASN 65102",
+		"leafx: no number at the end of the hostname to place it in the plan",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ebgp-per-leaf:\n%s", strings.Join(got, "\n"))
+	}
+
+	got = nil
+	for _, m := range plan(IBGPRR).Check(devs[:2]) {
+		got = append(got, m.String())
+	}
+	want = []string{
+		"spine1: route reflection is false, ibgp-rr wants true",
+		"leaf1: ASN 65101, the ibgp-rr plan gives leaf1 ASN 65000",
+		"leaf1: ebgp-multihop on SPINES-EVPN under iBGP",
+		"leaf1: vlan 10 route target 65000:10010, the auto scheme gives auto",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ibgp-rr:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+type private struct{}
+
+func (private) ASN(_ Plan, n Node) int { return 4200000000 + n.Index }
+func (private) IBGP() bool             { return false }
+func (private) AllowASIn() int         { return 0 }
+
+func TestRegisterModel(t *testing.T) {
+	RegisterModel("private-4byte", private{})
+	RegisterScheme("fixed", func(_ string, _, vlan, _ int) (string, error) { return fmt.Sprintf("1:%d", vlan), nil })
+	p := Plan{Model: "private-4byte", RDScheme: "fixed"}
+	p.SetDefaults()
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if asn := p.ASN(Node{Role: "spine", Index: 2}); asn != 4200000002 {
+		t.Errorf("asn = %d", asn)
+	}
+	if rd, _ := p.RD("", 0, 12, 0); rd != "1:12" {
+		t.Errorf("rd = %s", rd)
+	}
+	if !strings.Contains(strings.Join(Models(), ","), "private-4byte") {
+		t.Errorf("models = %v", Models())
+	}
+}
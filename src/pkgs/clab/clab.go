@@ -0,0 +1,47 @@
+package clab
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ContainerName returns the docker container name containerlab gives a
+// node, e.g. ContainerName("evpn-rdma-fabric", "leaf1") is
+// "clab-evpn-rdma-fabric-leaf1". Names that already carry the prefix are
+// returned unchanged.
+func ContainerName(lab, node string) string {
+	prefix := "clab-" + lab + "-"
+	if strings.HasPrefix(node, prefix) {
+		return node
+	}
+	return prefix + node
+}
+
+// NodeName strips the containerlab prefix from a container name.
+func NodeName(lab, container string) string {
+	return strings.TrimPrefix(container, "clab-"+lab+"-")
+}
+
+// DockerExec runs argv inside container with "docker exec", optionally via
+// "sudo -n" the same way containerlab inspect is run. stderr is folded into
+// the returned error.
+func DockerExec(ctx context.Context, container string, useSudo bool, argv ...string) ([]byte, error) {
+	args := append([]string{"docker", "exec", container}, argv...)
+	if useSudo {
+		args = append([]string{"sudo", "-n"}, args...)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("docker exec %s: %w: %s", container, err, msg)
+		}
+		return out, fmt.Errorf("docker exec %s: %w", container, err)
+	}
+	return out, nil
+}
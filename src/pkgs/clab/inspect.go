@@ -0,0 +1,54 @@
+package clab
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Container is one node as "containerlab inspect --format json" reports it.
+type Container struct {
+	LabName     string `json:"lab_name"`
+	LabPath     string `json:"labPath"`
+	AbsLabPath  string `json:"absLabPath"`
+	Name        string `json:"name"` // "clab-evpn-rdma-fabric-leaf1"
+	ContainerID string `json:"container_id"`
+	Image       string `json:"image"`
+	Kind        string `json:"kind"`
+	State       string `json:"state"`
+	Status      string `json:"status"`
+	IPv4        string `json:"ipv4_address"` // "172.20.20.7/24"
+	IPv6        string `json:"ipv6_address"`
+	Owner       string `json:"owner"`
+}
+
+// Node returns the topology node name of c, "leaf1".
+func (c Container) Node() string {
+	return NodeName(c.LabName, c.Name)
+}
+
+// ParseInspect decodes inspect output, which is keyed by lab name, and
+// returns the containers of the first lab. Older containerlab versions
+// print {"containers": [...]} instead, which is accepted too.
+func ParseInspect(out []byte) ([]Container, error) {
+	var byLab map[string][]Container
+	if err := json.Unmarshal(out, &byLab); err != nil {
+		return nil, err
+	}
+	for key, list := range byLab {
+		if key == "containers" {
+			return list, nil
+		}
+		for i := range list {
+			if list[i].LabName == "" {
+				list[i].LabName = key
+			}
+		}
+		return list, nil // first (and only) lab key
+	}
+	return nil, nil
+}
+
+// IsCEOS reports whether c is an Arista cEOS node.
+func (c Container) IsCEOS() bool {
+	return strings.EqualFold(c.Kind, "ceos")
+}
@@ -0,0 +1,162 @@
+package clab
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Topology is a containerlab topology file, as much of it as the tooling
+// reads.
+type Topology struct {
+	Name     string `yaml:"name"`
+	Topology struct {
+		Defaults Node            `yaml:"defaults"`
+		Kinds    map[string]Node `yaml:"kinds"`
+		Nodes    Nodes           `yaml:"nodes"`
+		Links    []Link          `yaml:"links"`
+	} `yaml:"topology"`
+}
+
+// Node is one node definition; kinds and defaults use the same fields.
+type Node struct {
+	Name          string            `yaml:"-"`
+	Kind          string            `yaml:"kind"`
+	Image         string            `yaml:"image"`
+	StartupConfig string            `yaml:"startup-config"`
+	Cmd           string            `yaml:"cmd"`
+	Exec          []string          `yaml:"exec"`
+	MgmtIPv4      string            `yaml:"mgmt-ipv4"`
+	MgmtIPv6      string            `yaml:"mgmt-ipv6"`
+	Group         string            `yaml:"group"`
+	Labels        map[string]string `yaml:"labels"`
+}
+
+// Nodes keeps the nodes in the order the file lists them.
+type Nodes []Node
+
+// UnmarshalYAML reads the nodes mapping in file order.
+func (ns *Nodes) UnmarshalYAML(v *yaml.Node) error {
+	if v.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: nodes is not a mapping", v.Line)
+	}
+	for i := 0; i+1 < len(v.Content); i += 2 {
+		var n Node
+		if err := v.Content[i+1].Decode(&n); err != nil {
+			return err
+		}
+		n.Name = v.Content[i].Value
+		*ns = append(*ns, n)
+	}
+	return nil
+}
+
+// Link is a point-to-point link between two node interfaces.
+type Link struct {
+	Endpoints []string `yaml:"endpoints"` // ["leaf1:eth1", "spine1:eth1"]
+}
+
+// Endpoint is one end of a link.
+type Endpoint struct {
+	Node, Interface string
+}
+
+func (e Endpoint) String() string { return e.Node + ":" + e.Interface }
+
+// ParseEndpoint splits "leaf1:eth1".
+func ParseEndpoint(s string) (Endpoint, error) {
+	node, intf, ok := strings.Cut(s, ":")
+	if !ok || node == "" || intf == "" {
+		return Endpoint{}, fmt.Errorf("bad link endpoint %q", s)
+	}
+	return Endpoint{node, intf}, nil
+}
+
+// Ends returns both endpoints of l.
+func (l Link) Ends() (a, b Endpoint, err error) {
+	if len(l.Endpoints) != 2 {
+		return a, b, fmt.Errorf("link %v does not have two endpoints", l.Endpoints)
+	}
+	if a, err = ParseEndpoint(l.Endpoints[0]); err != nil {
+		return
+	}
+	b, err = ParseEndpoint(l.Endpoints[1])
+	return
+}
+
+// ParseTopology decodes a topology file. Kind, image, startup-config and
+// cmd a node leaves out are taken from its kind's section, then from the
+// defaults, the way containerlab does.
+func ParseTopology(b []byte) (*Topology, error) {
+	var t Topology
+	if err := yaml.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("parse topology: %w", err)
+	}
+	for i := range t.Topology.Nodes {
+		n := &t.Topology.Nodes[i]
+		if n.Kind == "" {
+			n.Kind = t.Topology.Defaults.Kind
+		}
+		k := t.Topology.Kinds[n.Kind]
+		for _, fill := range []struct {
+			dst  *string
+			from []string
+		}{
+			{&n.Image, []string{k.Image, t.Topology.Defaults.Image}},
+			{&n.StartupConfig, []string{k.StartupConfig, t.Topology.Defaults.StartupConfig}},
+			{&n.Cmd, []string{k.Cmd, t.Topology.Defaults.Cmd}},
+		} {
+			for _, v := range fill.from {
+				if *fill.dst == "" {
+					*fill.dst = v
+				}
+			}
+		}
+	}
+	for _, l := range t.Topology.Links {
+		if _, _, err := l.Ends(); err != nil {
+			return nil, fmt.Errorf("parse topology: %w", err)
+		}
+	}
+	return &t, nil
+}
+
+// LoadTopology reads the topology file at path.
+func LoadTopology(path string) (*Topology, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseTopology(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Node returns the node called name.
+func (t *Topology) Node(name string) (Node, bool) {
+	for _, n := range t.Topology.Nodes {
+		if n.Name == name {
+			return n, true
+		}
+	}
+	return Node{}, false
+}
+
+// Links returns the links of node, each oriented with node's end first.
+func (t *Topology) Links(node string) [][2]Endpoint {
+	var out [][2]Endpoint
+	for _, l := range t.Topology.Links {
+		a, b, _ := l.Ends()
+		switch node {
+		case a.Node:
+			out = append(out, [2]Endpoint{a, b})
+		case b.Node:
+			out = append(out, [2]Endpoint{b, a})
+		}
+	}
+	return out
+}
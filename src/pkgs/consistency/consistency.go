@@ -0,0 +1,345 @@
+// Package consistency checks an inventory, as derived from the topology
+// and the startup configs, for mistakes that break the lab once deployed
+// but that no single config shows: two devices with one router ID or
+// VTEP, /31 links whose ends don't pair up, BGP neighbors configured with
+// the wrong remote AS, hosts on VLANs that EVPN doesn't carry, and
+// management addresses inside the data-plane pools.
+//
+//	inv, _ := inventory.LoadFile("inventory.json")
+//	for _, f := range consistency.Check(inv, consistency.Options{}) { fmt.Println(f) }
+package consistency
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/ipam"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Finding is one problem, reported on the device that has to change.
+type Finding struct {
+	Check   string `json:"check"`
+	Device  string `json:"device"`
+	File    string `json:"file,omitempty"` // the device's startup config
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	where := f.Device
+	if f.File != "" {
+		where = fmt.Sprintf("%s (%s)", f.Device, f.File)
+	}
+	return fmt.Sprintf("%s: %s [%s]", where, f.Message, f.Check)
+}
+
+// Options are what the checks compare the inventory with.
+type Options struct {
+	// IPAM holds the data-plane pools and the reserved management
+	// network; nil means ipam.New().
+	IPAM *ipam.IPAM
+	// Plan, when set, is the BGP numbering the devices must follow.
+	Plan *bgpplan.Plan
+}
+
+// check is the state shared by one run of the checks.
+type check struct {
+	inv  *inventory.Inventory
+	opts Options
+	name string // of the check running
+	out  []Finding
+}
+
+func (c *check) add(d inventory.Device, format string, args ...any) {
+	c.out = append(c.out, Finding{Check: c.name, Device: d.Name, File: d.StartupConfig, Message: fmt.Sprintf(format, args...)})
+}
+
+// checks are the checks Check runs, in the order their findings are
+// listed for the same device.
+var checks = []struct {
+	name string
+	run  func(c *check)
+}{
+	{"duplicate-router-id", duplicateRouterIDs},
+	{"duplicate-vtep", duplicateVTEPs},
+	{"p2p-pair", p2pPairs},
+	{"asn-mismatch", asnMismatches},
+	{"bgp-plan", bgpPlan},
+	{"vlan-without-vni", vlansWithoutVNI},
+	{"mgmt-overlap", mgmtOverlaps},
+}
+
+// Check runs every check over inv and returns the findings ordered by
+// device, in inventory order.
+func Check(inv *inventory.Inventory, opts Options) []Finding {
+	if opts.IPAM == nil {
+		opts.IPAM = ipam.New()
+	}
+	c := &check{inv: inv, opts: opts}
+	for _, ch := range checks {
+		c.name = ch.name
+		ch.run(c)
+	}
+	order := map[string]int{}
+	for i, d := range inv.Devices {
+		order[d.Name] = i
+	}
+	sort.SliceStable(c.out, func(i, j int) bool { return order[c.out[i].Device] < order[c.out[j].Device] })
+	return c.out
+}
+
+// routerID is the BGP router ID of d, or its Loopback0 when it runs no BGP.
+func routerID(d inventory.Device) string {
+	if d.BGP != nil && d.BGP.RouterID != "" {
+		return d.BGP.RouterID
+	}
+	return addr(d.Loopback(0))
+}
+
+// vtep is the address d sources VXLAN from, "" if it is no VTEP.
+func vtep(d inventory.Device) string {
+	if d.VXLAN == nil {
+		return ""
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(d.VXLAN.SourceInterface, "Loopback"))
+	if err != nil {
+		return ""
+	}
+	return addr(d.Loopback(id))
+}
+
+func duplicateRouterIDs(c *check) { c.unique("router ID", routerID) }
+
+func duplicateVTEPs(c *check) { c.unique("VTEP address", vtep) }
+
+// unique reports every device whose key was already taken by an earlier
+// one.
+func (c *check) unique(what string, key func(inventory.Device) string) {
+	first := map[string]string{}
+	for _, d := range c.inv.Devices {
+		k := key(d)
+		if k == "" {
+			continue
+		}
+		if o, dup := first[k]; dup {
+			c.add(d, "%s %s is also used by %s", what, k, o)
+			continue
+		}
+		first[k] = d.Name
+	}
+}
+
+// p2pPairs checks every link between two routed interfaces once, from the
+// end that comes first in the inventory.
+func p2pPairs(c *check) {
+	index := map[string]int{}
+	for i, d := range c.inv.Devices {
+		index[d.Name] = i
+	}
+	for i, d := range c.inv.Devices {
+		if d.Role == inventory.RoleHost {
+			continue
+		}
+		for _, l := range d.Links {
+			j, ok := index[l.Peer]
+			if !ok || j < i || (j == i && l.Interface > l.PeerInterface) {
+				continue
+			}
+			peer := c.inv.Devices[j]
+			if peer.Role == inventory.RoleHost {
+				continue
+			}
+			a, b := intf(d, l.Interface), intf(peer, l.PeerInterface)
+			link := fmt.Sprintf("%s %s - %s %s", d.Name, a.Name, peer.Name, b.Name)
+			switch {
+			case a.Address == "" && b.Address == "":
+				continue
+			case a.Address == "" || b.Address == "":
+				c.add(d, "%s: only one end is addressed", link)
+				continue
+			}
+			pa, errA := netip.ParsePrefix(a.Address)
+			pb, errB := netip.ParsePrefix(b.Address)
+			switch {
+			case errA != nil || errB != nil:
+				c.add(d, "%s: %s and %s are not both prefixes", link, a.Address, b.Address)
+			case pa.Bits() != 31 || pb.Bits() != 31:
+				c.add(d, "%s: %s and %s are not both /31s", link, a.Address, b.Address)
+			case pa.Masked() != pb.Masked():
+				c.add(d, "%s: %s and %s are in different /31s", link, a.Address, b.Address)
+			case pa.Addr() == pb.Addr():
+				c.add(d, "%s: both ends are %s", link, pa.Addr())
+			}
+		}
+	}
+}
+
+func asnMismatches(c *check) {
+	owner := map[string]inventory.Device{}
+	for _, d := range c.inv.Devices {
+		for _, a := range addresses(d) {
+			if _, dup := owner[a]; !dup {
+				owner[a] = d
+			}
+		}
+	}
+	for _, d := range c.inv.Devices {
+		if d.BGP == nil {
+			continue
+		}
+		neighbors := d.BGP.Underlay
+		if d.BGP.Overlay != nil {
+			neighbors = append(neighbors[:len(neighbors):len(neighbors)], d.BGP.Overlay.Neighbors...)
+		}
+		for _, n := range neighbors {
+			peer, ok := owner[n.Address]
+			switch {
+			case !ok:
+				c.add(d, "neighbor %s is no device's address", n.Address)
+			case peer.ASN() == 0:
+				c.add(d, "neighbor %s is %s, which runs no BGP", n.Address, peer.Name)
+			case peer.ASN() != n.RemoteAS:
+				c.add(d, "neighbor %s has remote-as %d, but %s is AS %d", n.Address, n.RemoteAS, peer.Name, peer.ASN())
+			}
+		}
+	}
+}
+
+// bgpPlan holds the devices to the ASN, session and RD/RT policy of the
+// plan, when there is one.
+func bgpPlan(c *check) {
+	if c.opts.Plan == nil {
+		return
+	}
+	byHost := map[string]inventory.Device{}
+	var devs []devices.Device
+	for _, d := range c.inv.Devices {
+		byHost[d.Hostname] = d
+		devs = append(devs, d.Device)
+	}
+	for _, m := range c.opts.Plan.Check(devs) {
+		c.add(byHost[m.Device], "%s", m.Message)
+	}
+}
+
+// vlansWithoutVNI checks the access ports facing hosts. Without links in
+// the inventory every access port is taken to face one.
+func vlansWithoutVNI(c *check) {
+	for _, d := range c.inv.Devices {
+		if d.Role == inventory.RoleHost {
+			continue
+		}
+		for _, i := range d.Interfaces {
+			if i.VLAN == 0 {
+				continue
+			}
+			host := ""
+			for _, l := range d.Links {
+				if intf(d, l.Interface).Name == i.Name {
+					host = l.Peer
+				}
+			}
+			if host != "" {
+				if p, ok := c.inv.Get(host); !ok || p.Role != inventory.RoleHost {
+					continue
+				}
+				host = " (" + host + ")"
+			}
+			vni, defined := 0, false
+			for _, v := range d.VLANs {
+				if v.ID == i.VLAN {
+					vni, defined = v.VNI, true
+				}
+			}
+			switch {
+			case !defined:
+				c.add(d, "%s%s is in VLAN %d, which is not defined", i.Name, host, i.VLAN)
+			case vni == 0:
+				c.add(d, "%s%s is in VLAN %d, which is not mapped to a VNI", i.Name, host, i.VLAN)
+			}
+		}
+	}
+}
+
+func mgmtOverlaps(c *check) {
+	var reserved []netip.Prefix
+	for _, r := range c.opts.IPAM.Reserved {
+		if p, err := netip.ParsePrefix(r); err == nil {
+			reserved = append(reserved, p.Masked())
+		}
+	}
+	for _, d := range c.inv.Devices {
+		mgmt := d.MGMTAddress
+		if mgmt == "" && d.Container != nil {
+			mgmt = d.Container.IPv4
+		}
+		if m, err := netip.ParsePrefix(mgmt); err == nil {
+			for _, p := range c.opts.IPAM.Pools {
+				if pp, err := netip.ParsePrefix(p.Prefix); err == nil && pp.Contains(m.Addr()) {
+					c.add(d, "management address %s is in pool %s (%s)", mgmt, p.Name, p.Prefix)
+				}
+			}
+			reserved = append(reserved, m.Masked())
+		}
+	}
+	for _, d := range c.inv.Devices {
+		var used []devices.Interface
+		for _, l := range d.Loopbacks {
+			used = append(used, devices.Interface{Name: "Loopback" + strconv.Itoa(l.ID), Address: l.Address})
+		}
+		for _, i := range append(used, d.Interfaces...) {
+			p, err := netip.ParsePrefix(i.Address)
+			if err != nil {
+				continue
+			}
+			for _, r := range reserved {
+				if r.Overlaps(p) {
+					c.add(d, "%s %s overlaps the management network %s", i.Name, i.Address, r)
+					break
+				}
+			}
+		}
+	}
+}
+
+// addr strips the prefix length off an address; "" stays "".
+func addr(s string) string {
+	a, err := netmath.Addr(s)
+	if err != nil {
+		return ""
+	}
+	return a
+}
+
+// addresses lists every address configured on d, loopbacks first.
+func addresses(d inventory.Device) []string {
+	var out []string
+	for _, l := range d.Loopbacks {
+		out = append(out, addr(l.Address))
+	}
+	for _, i := range d.Interfaces {
+		out = append(out, addr(i.Address), addr(i.Gateway))
+	}
+	return slices.DeleteFunc(out, func(a string) bool { return a == "" })
+}
+
+// intf returns the interface of d a link end names, "eth1" being
+// Ethernet1 on EOS. A port without config comes back with just its name.
+func intf(d inventory.Device, name string) devices.Interface {
+	if d.Kind != "linux" {
+		name = netmath.EOSInterface(name)
+	}
+	for _, i := range d.Interfaces {
+		if i.Name == name {
+			return i
+		}
+	}
+	return devices.Interface{Name: name}
+}
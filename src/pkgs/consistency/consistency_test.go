@@ -0,0 +1,144 @@
+package consistency
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/fabric"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+)
+
+const labDir = "../../.."
+
+// planned is the inventory fabric.yml lays out, which is consistent by
+// construction.
+func planned(t *testing.T) (*inventory.Inventory, *bgpplan.Plan) {
+	t.Helper()
+	in, err := fabric.LoadFile(filepath.Join(labDir, "fabric.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := fabric.Plan(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv, &in.BGP
+}
+
+func device(t *testing.T, inv *inventory.Inventory, name string) *inventory.Device {
+	t.Helper()
+	for i := range inv.Devices {
+		if inv.Devices[i].Name == name {
+			return &inv.Devices[i]
+		}
+	}
+	t.Fatalf("no device %s", name)
+	return nil
+}
+
+func TestPlannedIsClean(t *testing.T) {
+	inv, plan := planned(t)
+	for _, f := range Check(inv, Options{Plan: plan}) {
+		t.Error(f)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(t *testing.T, inv *inventory.Inventory)
+		want   []string // "check device: message part"
+	}{
+		{"router id", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "spine2").BGP.RouterID = "10.0.0.1"
+		}, []string{"duplicate-router-id spine2: 10.0.0.1 is also used by spine1"}},
+		{"vtep", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "leaf2").Loopbacks[1].Address = "10.255.0.11/32"
+		}, []string{"duplicate-vtep leaf2: 10.255.0.11 is also used by leaf1"}},
+		{"/31 pair", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "leaf1").Interfaces[0].Address = "172.16.1.201/31"
+		}, []string{
+			"p2p-pair spine1: spine1 Ethernet1 - leaf1 Ethernet1: 172.16.1.0/31 and 172.16.1.201/31 are in different /31s",
+			// the spine's session now points nowhere
+			"asn-mismatch spine1: neighbor 172.16.1.1 is no device's address",
+		}},
+		{"one end", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "leaf2").Interfaces[1].Address = ""
+		}, []string{
+			"p2p-pair spine2: spine2 Ethernet2 - leaf2 Ethernet2: only one end",
+			"asn-mismatch spine2: neighbor 172.16.2.3 is no device's address",
+		}},
+		{"remote as", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "spine1").BGP.Underlay[2].RemoteAS = 65109
+		}, []string{"asn-mismatch spine1: neighbor 172.16.1.5 has remote-as 65109, but leaf3 is AS 65103"}},
+		{"plan", func(t *testing.T, inv *inventory.Inventory) {
+			// renumbered consistently, but off the plan
+			device(t, inv, "leaf4").BGP.ASN = 65109
+			for _, spine := range []string{"spine1", "spine2"} {
+				bgp := device(t, inv, spine).BGP
+				bgp.Underlay[3].RemoteAS = 65109
+				bgp.Overlay.Neighbors[3].RemoteAS = 65109
+			}
+		}, []string{"bgp-plan leaf4: ASN 65109, the ebgp-per-leaf plan gives leaf4 ASN 65104"}},
+		{"vni", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "leaf3").VLANs[0].VNI = 0
+		}, []string{"vlan-without-vni leaf3: Ethernet3 (gpu3) is in VLAN 10, which is not mapped to a VNI"}},
+		{"undefined vlan", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "leaf4").Interfaces[2].VLAN = 20
+		}, []string{"vlan-without-vni leaf4: Ethernet3 (gpu4) is in VLAN 20, which is not defined"}},
+		{"mgmt", func(t *testing.T, inv *inventory.Inventory) {
+			device(t, inv, "leaf1").MGMTAddress = "10.10.10.5/24"
+			device(t, inv, "gpu2").Interfaces[0].Address = "172.20.20.102/24"
+		}, []string{
+			"mgmt-overlap leaf1: management address 10.10.10.5/24 is in pool hosts",
+			// leaf1's management network is now the hosts' subnet
+			"mgmt-overlap gpu1: eth1 10.10.10.101/24 overlaps the management network 10.10.10.0/24",
+			"mgmt-overlap gpu2: eth1 172.20.20.102/24 overlaps the management network 172.20.20.0/24",
+			"mgmt-overlap gpu3: eth1 10.10.10.103/24 overlaps",
+			"mgmt-overlap gpu4: eth1 10.10.10.104/24 overlaps",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inv, plan := planned(t)
+			tc.mutate(t, inv)
+			got := Check(inv, Options{Plan: plan})
+			if len(got) != len(tc.want) {
+				t.Errorf("%d findings, want %d:\n%s", len(got), len(tc.want), list(got))
+				return
+			}
+			for i, f := range got {
+				where, msg, _ := strings.Cut(tc.want[i], ": ")
+				if s := f.Check + " " + f.Device; s != where || !strings.Contains(f.Message, msg) {
+					t.Errorf("finding %d = %s: %s, want %s", i, s, f.Message, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+// The checked-in lab is generated from fabric.yml and has nothing to find.
+func TestLab(t *testing.T) {
+	topo, err := clab.LoadTopology(filepath.Join(labDir, "lab.clab.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := inventory.Derive(topo, labDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Check(inv, Options{})
+	if len(got) > 0 {
+		t.Errorf("findings:\n%s", list(got))
+	}
+}
+
+func list(fs []Finding) string {
+	var b strings.Builder
+	for _, f := range fs {
+		b.WriteString(f.String() + "\n")
+	}
+	return b.String()
+}
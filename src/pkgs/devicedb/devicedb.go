@@ -0,0 +1,144 @@
+// Package devicedb stores the device inventory in the layout auto_lab
+// reads: a devices table of hostname, ip_address, loopback_ip, username,
+// password and infrastructure_interfaces. The Postgres database auto_lab
+// was written against is replaced by a local SQLite file, and the rows can
+// be moved in and out as YAML, so the Go and Python tools share one
+// inventory without a database server.
+package devicedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Record is one row of the devices table. The field names follow the
+// auto_lab Device dataclass.
+type Record struct {
+	Hostname                 string   `yaml:"hostname" json:"hostname"`
+	IPAddress                string   `yaml:"ip_address" json:"ip_address"`   // management address
+	LoopbackIP               string   `yaml:"loopback_ip" json:"loopback_ip"` // Loopback0, no prefix length
+	Username                 string   `yaml:"username,omitempty" json:"username,omitempty"`
+	Password                 string   `yaml:"password,omitempty" json:"password,omitempty"`
+	InfrastructureInterfaces []string `yaml:"infrastructure_interfaces" json:"infrastructure_interfaces"`
+}
+
+// Errors returned by repositories.
+var (
+	ErrNotFound = errors.New("device not found")
+	ErrExists   = errors.New("device already exists")
+)
+
+// Repository is a store of device records keyed by hostname.
+type Repository interface {
+	// List returns every record, sorted by hostname.
+	List(ctx context.Context) ([]Record, error)
+	Get(ctx context.Context, hostname string) (Record, error)
+	// Create adds r and fails with ErrExists if its hostname is taken.
+	Create(ctx context.Context, r Record) error
+	// Update replaces the record of r.Hostname, or fails with ErrNotFound.
+	Update(ctx context.Context, r Record) error
+	// Put creates or replaces r.
+	Put(ctx context.Context, r Record) error
+	Delete(ctx context.Context, hostname string) error
+}
+
+// Validate checks the fields auto_lab relies on: a hostname and, when set,
+// addresses without prefix lengths.
+func (r Record) Validate() error {
+	if r.Hostname == "" || strings.ContainsAny(r.Hostname, " \t") {
+		return fmt.Errorf("bad hostname %q", r.Hostname)
+	}
+	for _, a := range []struct{ name, value string }{{"ip_address", r.IPAddress}, {"loopback_ip", r.LoopbackIP}} {
+		if a.value == "" {
+			continue
+		}
+		if addr, err := netmath.Addr(a.value); err != nil || addr != a.value {
+			return fmt.Errorf("%s: %s %q is not a plain IP address", r.Hostname, a.name, a.value)
+		}
+	}
+	return nil
+}
+
+// document is the YAML layout of Export and Import.
+type document struct {
+	Devices []Record `yaml:"devices"`
+}
+
+// Export writes every record of repo as YAML.
+func Export(ctx context.Context, repo Repository, w io.Writer) error {
+	recs, err := repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(document{Devices: recs}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Import reads records in the layout of Export and puts them into repo.
+// The file is checked as a whole first, so a bad record leaves repo
+// untouched. It returns the number of records written.
+func Import(ctx context.Context, repo Repository, r io.Reader) (int, error) {
+	var doc document
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("decode devices: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, rec := range doc.Devices {
+		if err := rec.Validate(); err != nil {
+			return 0, err
+		}
+		if seen[rec.Hostname] {
+			return 0, fmt.Errorf("%s is listed twice", rec.Hostname)
+		}
+		seen[rec.Hostname] = true
+	}
+	for i, rec := range doc.Devices {
+		if err := repo.Put(ctx, rec); err != nil {
+			return i, err
+		}
+	}
+	return len(doc.Devices), nil
+}
+
+// FromInventory turns the routed devices of inv (those with a Loopback0)
+// into records. The management address comes from the device or its
+// container, and the credentials from the device's credential set.
+func FromInventory(inv *inventory.Inventory) []Record {
+	var out []Record
+	for _, d := range inv.Devices {
+		lo, err := netmath.Addr(d.Loopback(0))
+		if err != nil {
+			continue
+		}
+		r := Record{Hostname: d.Name, LoopbackIP: lo, InfrastructureInterfaces: d.InfraInterfaces()}
+		mgmt := d.MGMTAddress
+		if mgmt == "" && d.Container != nil {
+			mgmt = d.Container.IPv4
+		}
+		r.IPAddress, _ = netmath.Addr(mgmt)
+		if c, err := inv.Credential(d); err == nil {
+			r.Username, r.Password = c.Username, c.Password
+		}
+		if r.InfrastructureInterfaces == nil {
+			r.InfrastructureInterfaces = []string{}
+		}
+		out = append(out, r)
+	}
+	slices.SortFunc(out, func(a, b Record) int { return strings.Compare(a.Hostname, b.Hostname) })
+	return out
+}
@@ -0,0 +1,190 @@
+package devicedb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+)
+
+func open(t *testing.T) *SQLite {
+	t.Helper()
+	db, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "devices.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "devices.db")
+	db, err := OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := db.Version(ctx); err != nil || v != SchemaVersion {
+		t.Fatalf("version = %d, %v; want %d", v, err, SchemaVersion)
+	}
+	if err := db.Put(ctx, Record{Hostname: "leaf1"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// reopening runs nothing and keeps the rows
+	db, err = OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Get(ctx, "leaf1"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := db.db.Exec("PRAGMA user_version = 99"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(ctx); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("newer schema: err = %v", err)
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+	leaf1 := Record{
+		Hostname: "leaf1", IPAddress: "172.20.20.5", LoopbackIP: "10.0.0.11",
+		Username: "admin", Password: "admin",
+		InfrastructureInterfaces: []string{"Ethernet1", "Ethernet2"},
+	}
+	if err := db.Create(ctx, leaf1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(ctx, leaf1); !errors.Is(err, ErrExists) {
+		t.Errorf("second create: err = %v", err)
+	}
+	got, err := db.Get(ctx, "leaf1")
+	if err != nil || !equal(got, leaf1) {
+		t.Errorf("get = %+v, %v", got, err)
+	}
+
+	leaf1.InfrastructureInterfaces = nil
+	if err := db.Update(ctx, leaf1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.Get(ctx, "leaf1"); got.InfrastructureInterfaces == nil || len(got.InfrastructureInterfaces) != 0 {
+		t.Errorf("interfaces = %#v, want empty", got.InfrastructureInterfaces)
+	}
+	if err := db.Update(ctx, Record{Hostname: "leaf9"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update missing: err = %v", err)
+	}
+
+	if err := db.Put(ctx, Record{Hostname: "leaf2", LoopbackIP: "10.0.0.11"}); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Errorf("shared loopback: err = %v", err)
+	}
+	if err := db.Put(ctx, Record{Hostname: "leaf2", LoopbackIP: "10.0.0.12/32"}); err == nil {
+		t.Error("loopback with a prefix length accepted")
+	}
+	if err := db.Put(ctx, Record{Hostname: "leaf0"}); err != nil {
+		t.Fatal(err)
+	}
+	recs, _ := db.List(ctx)
+	if len(recs) != 2 || recs[0].Hostname != "leaf0" {
+		t.Errorf("list = %+v", recs)
+	}
+
+	if err := db.Delete(ctx, "leaf0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(ctx, "leaf0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: err = %v", err)
+	}
+	if _, err := db.Get(ctx, "leaf0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get deleted: err = %v", err)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+	doc := `devices:
+  - hostname: spine1
+    ip_address: 172.20.20.2
+    loopback_ip: 10.0.0.1
+    username: admin
+    password: admin
+    infrastructure_interfaces: [Ethernet1, Ethernet2]
+  - hostname: leaf1
+    ip_address: 172.20.20.5
+    loopback_ip: 10.0.0.11
+    infrastructure_interfaces: []
+`
+	n, err := Import(ctx, db, strings.NewReader(doc))
+	if err != nil || n != 2 {
+		t.Fatalf("import = %d, %v", n, err)
+	}
+	var out bytes.Buffer
+	if err := Export(ctx, db, &out); err != nil {
+		t.Fatal(err)
+	}
+	other := open(t)
+	if _, err := Import(ctx, other, &out); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := db.List(ctx)
+	b, _ := other.List(ctx)
+	if !slices.EqualFunc(a, b, equal) {
+		t.Errorf("round trip:\n%+v\n%+v", a, b)
+	}
+
+	for name, bad := range map[string]string{
+		"twice":   "devices:\n  - hostname: leaf2\n  - hostname: leaf2\n",
+		"address": "devices:\n  - hostname: leaf2\n  - hostname: leaf3\n    loopback_ip: nope\n",
+		"field":   "devices:\n  - hostname: leaf2\n    loopback: 10.0.0.12\n",
+	} {
+		if _, err := Import(ctx, db, strings.NewReader(bad)); err == nil {
+			t.Errorf("%s: imported", name)
+		}
+	}
+	if _, err := db.Get(ctx, "leaf2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("a rejected file was partly imported: %v", err)
+	}
+}
+
+func TestFromInventory(t *testing.T) {
+	inv, err := inventory.LoadFile(filepath.Join("..", "..", "..", "inventory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := FromInventory(inv)
+	var names []string
+	for _, r := range recs {
+		names = append(names, r.Hostname)
+	}
+	if want := []string{"leaf1", "leaf2", "leaf3", "leaf4", "spine1", "spine2"}; !slices.Equal(names, want) {
+		t.Fatalf("hosts = %v, want %v", names, want)
+	}
+	leaf1 := recs[0]
+	if leaf1.LoopbackIP != "10.0.0.11" || leaf1.Username != "admin" || len(leaf1.InfrastructureInterfaces) != 2 {
+		t.Errorf("leaf1 = %+v", leaf1)
+	}
+
+	db := open(t)
+	for _, r := range recs {
+		if err := db.Put(context.Background(), r); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func equal(a, b Record) bool {
+	return a.Hostname == b.Hostname && a.IPAddress == b.IPAddress && a.LoopbackIP == b.LoopbackIP &&
+		a.Username == b.Username && a.Password == b.Password &&
+		slices.Equal(a.InfrastructureInterfaces, b.InfrastructureInterfaces)
+}
@@ -0,0 +1,225 @@
+package devicedb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// migrations are applied in order; the index of the last one applied is
+// kept in PRAGMA user_version. Append, never edit: files in the field have
+// already run the earlier steps.
+var migrations = []string{
+	// the auto_lab devices table; infrastructure_interfaces is a JSON
+	// array where Postgres has text[]
+	`CREATE TABLE devices (
+		hostname                  TEXT PRIMARY KEY,
+		ip_address                TEXT NOT NULL DEFAULT '',
+		loopback_ip               TEXT NOT NULL DEFAULT '',
+		username                  TEXT NOT NULL DEFAULT '',
+		password                  TEXT NOT NULL DEFAULT '',
+		infrastructure_interfaces TEXT NOT NULL DEFAULT '[]'
+	)`,
+	`CREATE UNIQUE INDEX devices_loopback_ip ON devices (loopback_ip) WHERE loopback_ip != ''`,
+}
+
+// SchemaVersion is the version Migrate brings a database to.
+var SchemaVersion = len(migrations)
+
+// SQLite is a Repository in a SQLite database file.
+type SQLite struct {
+	db *sql.DB
+}
+
+var _ Repository = (*SQLite)(nil)
+
+// OpenSQLite opens (creating it if needed) the database at path and
+// migrates it to SchemaVersion.
+func OpenSQLite(ctx context.Context, path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// one writer at a time is all SQLite does anyway
+	db.SetMaxOpenConns(1)
+	s := &SQLite{db: db}
+	if err := s.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *SQLite) Close() error { return s.db.Close() }
+
+// Version returns the schema version of the database.
+func (s *SQLite) Version(ctx context.Context) (int, error) {
+	var v int
+	err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&v)
+	return v, err
+}
+
+// Migrate applies the migrations the database hasn't seen yet, each in its
+// own transaction. A database newer than this binary is an error.
+func (s *SQLite) Migrate(ctx context.Context) error {
+	v, err := s.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if v > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than this build knows (%d)", v, len(migrations))
+	}
+	for i := v; i < len(migrations); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA takes no placeholders
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+const columns = "hostname, ip_address, loopback_ip, username, password, infrastructure_interfaces"
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scan(row scanner) (Record, error) {
+	var r Record
+	var intfs string
+	if err := row.Scan(&r.Hostname, &r.IPAddress, &r.LoopbackIP, &r.Username, &r.Password, &intfs); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal([]byte(intfs), &r.InfrastructureInterfaces); err != nil {
+		return r, fmt.Errorf("%s: infrastructure_interfaces: %w", r.Hostname, err)
+	}
+	if r.InfrastructureInterfaces == nil {
+		r.InfrastructureInterfaces = []string{}
+	}
+	return r, nil
+}
+
+// args returns the column values of r in the order of columns.
+func args(r Record) ([]any, error) {
+	intfs := r.InfrastructureInterfaces
+	if intfs == nil {
+		intfs = []string{}
+	}
+	b, err := json.Marshal(intfs)
+	if err != nil {
+		return nil, err
+	}
+	return []any{r.Hostname, r.IPAddress, r.LoopbackIP, r.Username, r.Password, string(b)}, nil
+}
+
+func (s *SQLite) List(ctx context.Context) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+columns+" FROM devices ORDER BY hostname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Record
+	for rows.Next() {
+		r, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLite) Get(ctx context.Context, hostname string) (Record, error) {
+	r, err := scan(s.db.QueryRowContext(ctx, "SELECT "+columns+" FROM devices WHERE hostname = ?", hostname))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, fmt.Errorf("%s: %w", hostname, ErrNotFound)
+	}
+	return r, err
+}
+
+func (s *SQLite) Create(ctx context.Context, r Record) error {
+	// the hostname is checked first so a repeated Create reports ErrExists
+	// rather than its own loopback
+	if _, err := s.Get(ctx, r.Hostname); err == nil {
+		return fmt.Errorf("%s: %w", r.Hostname, ErrExists)
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.write(ctx, "INSERT INTO devices ("+columns+") VALUES (?, ?, ?, ?, ?, ?)", r)
+}
+
+func (s *SQLite) Update(ctx context.Context, r Record) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	a, err := args(r)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE devices SET ip_address = ?, loopback_ip = ?, username = ?,
+		password = ?, infrastructure_interfaces = ? WHERE hostname = ?`, append(a[1:], a[0])...)
+	if err != nil {
+		return conflict(r, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s: %w", r.Hostname, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *SQLite) Put(ctx context.Context, r Record) error {
+	// not INSERT OR REPLACE, which would also drop the device holding the
+	// loopback
+	return s.write(ctx, "INSERT INTO devices ("+columns+") VALUES (?, ?, ?, ?, ?, ?)"+
+		` ON CONFLICT (hostname) DO UPDATE SET ip_address = excluded.ip_address,
+		loopback_ip = excluded.loopback_ip, username = excluded.username, password = excluded.password,
+		infrastructure_interfaces = excluded.infrastructure_interfaces`, r)
+}
+
+func (s *SQLite) Delete(ctx context.Context, hostname string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM devices WHERE hostname = ?", hostname)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s: %w", hostname, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *SQLite) write(ctx context.Context, query string, r Record) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	a, err := args(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, query, a...)
+	return conflict(r, err)
+}
+
+// conflict explains a violated loopback index; other errors pass through.
+func conflict(r Record, err error) error {
+	var se *sqlite.Error
+	if errors.As(err, &se) && se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return fmt.Errorf("%s: loopback_ip %s is already used by another device", r.Hostname, r.LoopbackIP)
+	}
+	return err
+}
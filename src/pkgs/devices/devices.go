@@ -1,12 +1,153 @@
 package devices
 
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Device is one node of the lab as the renderers and clients see it.
 type Device struct {
-	MGMTAddress string
-	Interfaces  []Interface
+	Hostname    string      `json:"hostname"`
+	Role        string      `json:"role"` // spine, leaf or host
+	MGMTAddress string      `json:"mgmtAddress,omitempty"`
+	Users       []User      `json:"users,omitempty"`
+	Loopbacks   []Loopback  `json:"loopbacks,omitempty"`
+	Interfaces  []Interface `json:"interfaces,omitempty"`
+	VLANs       []VLAN      `json:"vlans,omitempty"`
+	VRFs        []VRF       `json:"vrfs,omitempty"`
+	VXLAN       *VXLAN      `json:"vxlan,omitempty"`
+	BGP         *BGP        `json:"bgp,omitempty"`
+	Multicast   bool        `json:"multicast,omitempty"` // router multicast with kernel software forwarding
+	EAPI        bool        `json:"eapi,omitempty"`      // management api http-commands over https
+	// VirtualRouterMAC is the MAC every leaf answers with for its anycast
+	// gateways.
+	VirtualRouterMAC string `json:"virtualRouterMac,omitempty"`
+}
+
+// User is a local account.
+type User struct {
+	Name      string `json:"name"`
+	Privilege int    `json:"privilege"`
+	Secret    string `json:"secret"`
 }
 
+// Loopback is a LoopbackN interface; ID 0 carries the router ID and the
+// overlay peering, ID 1 the VTEP address.
+type Loopback struct {
+	ID      int    `json:"id"`
+	Address string `json:"address"` // "10.0.0.11/32"
+}
+
+// Interface is a front-panel port. It is routed when Address is set and an
+// access port when VLAN is set. An SVI ("Vlan20") with Gateway set is an
+// anycast gateway, the same address on every leaf. On a linux host it is a
+// Linux interface ("eth1", "eth1.20") and VLAN the segment it is in.
 type Interface struct {
-	Address string
-	VRF     string
-	VLAN    int
+	Name     string `json:"name"` // "Ethernet1"
+	Address  string `json:"address,omitempty"`
+	Gateway  string `json:"gateway,omitempty"` // ip address virtual
+	VRF      string `json:"vrf,omitempty"`
+	VLAN     int    `json:"vlan,omitempty"`
+	PortFast bool   `json:"portFast,omitempty"`
+	MTU      int    `json:"mtu,omitempty"`
+}
+
+// VLAN is a layer-2 segment, stretched over EVPN when VNI is set.
+type VLAN struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name,omitempty"`
+	VNI         int    `json:"vni,omitempty"`
+	RD          string `json:"rd,omitempty"`
+	RouteTarget string `json:"routeTarget,omitempty"` // imported and exported
+}
+
+// VRF is a tenant routing table, carried between leaves as an L3VNI for
+// symmetric IRB.
+type VRF struct {
+	Name        string `json:"name"`
+	VNI         int    `json:"vni"`
+	RD          string `json:"rd,omitempty"`
+	RouteTarget string `json:"routeTarget,omitempty"` // imported and exported
+}
+
+// VXLAN is the Vxlan1 interface; VLAN to VNI mappings come from VLANs,
+// VRF to L3VNI ones from VRFs.
+type VXLAN struct {
+	SourceInterface string `json:"sourceInterface"` // "Loopback1"
+}
+
+// BGP is the router bgp instance: plain underlay sessions plus one EVPN
+// overlay peer group.
+type BGP struct {
+	ASN      int        `json:"asn"`
+	RouterID string     `json:"routerId"`
+	Underlay []Neighbor `json:"underlay,omitempty"`
+	Overlay  *PeerGroup `json:"overlay,omitempty"`
+
+	// RouteReflector makes the underlay neighbors and the overlay group
+	// route reflector clients, as spines are under iBGP.
+	RouteReflector bool `json:"routeReflector,omitempty"`
+	// AllowASIn lets paths carrying the local ASN this many times in, for
+	// leaves that share an ASN.
+	AllowASIn int `json:"allowAsIn,omitempty"`
+}
+
+// PeerGroup is a BGP peer group and its members.
+type PeerGroup struct {
+	Name         string     `json:"name"`
+	UpdateSource string     `json:"updateSource,omitempty"`
+	EBGPMultihop int        `json:"ebgpMultihop,omitempty"`
+	Neighbors    []Neighbor `json:"neighbors,omitempty"`
+}
+
+// Neighbor is one BGP peer.
+type Neighbor struct {
+	Address  string `json:"address"`
+	RemoteAS int    `json:"remoteAs"`
+}
+
+// Load reads an inventory document of the form {"devices": [...]}.
+func Load(r io.Reader) ([]Device, error) {
+	var doc struct {
+		Devices []Device `json:"devices"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode inventory: %w", err)
+	}
+	return doc.Devices, nil
+}
+
+// LoadFile reads the inventory at path.
+func LoadFile(path string) ([]Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Loopback returns the address of LoopbackN, or "" if there is none.
+func (d Device) Loopback(id int) string {
+	for _, l := range d.Loopbacks {
+		if l.ID == id {
+			return l.Address
+		}
+	}
+	return ""
+}
+
+// InfraInterfaces names the routed fabric links: interfaces with an
+// address in the default VRF.
+func (d Device) InfraInterfaces() []string {
+	var names []string
+	for _, i := range d.Interfaces {
+		if i.Address != "" && (i.VRF == "" || strings.EqualFold(i.VRF, "default")) {
+			names = append(names, i.Name)
+		}
+	}
+	return names
 }
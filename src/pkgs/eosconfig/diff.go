@@ -0,0 +1,264 @@
+package eosconfig
+
+import (
+	"slices"
+	"strings"
+)
+
+// implicitDefaults are lines EOS doesn't print in the running-config
+// because they are the default for the section starting with the given
+// header prefix; "" is the top level. Intended configs often spell them
+// out, so they are ignored on both sides.
+var implicitDefaults = []struct{ section, line string }{
+	{"interface Ethernet", "switchport"},
+	{"interface Ethernet", "no shutdown"},
+	{"interface Loopback", "no shutdown"},
+	{"interface Management", "no shutdown"},
+	{"interface Vxlan", "no shutdown"},
+	{"", "spanning-tree mode mstp"},
+}
+
+// singleValued are commands that take one value per section, so setting a
+// new one replaces the old one without a "no" first. The number is how
+// many words identify the setting.
+var singleValued = map[string]int{
+	"hostname":               1,
+	"router-id":              1,
+	"description":            1,
+	"mtu":                    1,
+	"rd":                     1,
+	"net":                    1,
+	"ip address":             2,
+	"switchport mode":        2,
+	"switchport access vlan": 3,
+	"vxlan source-interface": 2,
+	"vxlan udp-port":         2,
+	"vxlan vlan":             3, // vxlan vlan 10 vni 1010
+	"isis enable":            2,
+	"node-segment ipv4":      3,
+	"router-id interface":    2,
+	"maximum-paths":          1,
+}
+
+// sectionSingleValued are like singleValued, but only in sections whose
+// header starts with section: an interface is in one VRF, while the top
+// level holds a "vrf instance" stanza per VRF.
+var sectionSingleValued = []struct {
+	section, prefix string
+	words           int
+}{
+	{"interface ", "vrf", 1}, // vrf GPU, and the older vrf forwarding GPU
+}
+
+// Diff compares two configs as trees and returns the commands that turn
+// running into intended, as a tree of modes: String shows it the way EOS
+// prints configs, Flatten gives the command sequence for a config session.
+//
+// Sections are matched by their header and lines by their text, so order
+// doesn't matter, and sections repeated in a file (e.g. two "interface
+// Ethernet3" blocks) are merged. Lines dropped from intended are negated
+// ("no X", or "default X" for "no X"), unless intended sets the same
+// single-valued setting or flips its negation, which overrides them. An
+// interface that changes VRF gets its addresses again, since EOS clears
+// them on the move.
+func Diff(running, intended *Node) *Node {
+	out := &Node{Indent: -1}
+	diffSection(running, intended, "", out)
+	readdress(out, intended)
+	return out
+}
+
+// readdress adds the intended addresses of the interfaces whose VRF out
+// changes.
+func readdress(out, intended *Node) {
+	_, wantBy := index(intended)
+	for _, intf := range out.Commands() {
+		moved := slices.ContainsFunc(intf.Commands(), func(c *Node) bool {
+			return SettingKey(intf.Text, c.Text) == "vrf"
+		})
+		want, ok := wantBy[intf.Text]
+		if !strings.HasPrefix(intf.Text, "interface ") || !moved || !ok {
+			continue
+		}
+		for _, c := range want.Commands() {
+			t := normalize(c.Text)
+			if !strings.HasPrefix(t, "ip address") && !strings.HasPrefix(t, "ipv6 address") {
+				continue
+			}
+			if !slices.ContainsFunc(intf.Commands(), func(d *Node) bool { return d.Text == t }) {
+				intf.AddChild(t)
+			}
+		}
+	}
+}
+
+// Flatten lists the commands below n in order, leaving each mode with
+// "exit" so the sequence can be fed to "configure session" line by line.
+func (n *Node) Flatten() []string {
+	var cmds []string
+	for _, c := range n.Commands() {
+		cmds = append(cmds, c.Text)
+		if len(c.Commands()) > 0 {
+			cmds = append(cmds, c.Flatten()...)
+			cmds = append(cmds, "exit")
+		}
+	}
+	return cmds
+}
+
+func diffSection(run, want *Node, header string, out *Node) {
+	runOrder, runBy := index(run)
+	wantOrder, wantBy := index(want)
+	overridden, present := map[string]bool{}, map[string]bool{}
+	for _, t := range wantOrder {
+		if len(wantBy[t].Commands()) == 0 {
+			overridden[SettingKey(header, t)] = true
+		}
+	}
+	for _, t := range runOrder {
+		if len(runBy[t].Commands()) == 0 {
+			present[SettingKey(header, t)] = true
+		}
+	}
+
+	for _, t := range runOrder {
+		if _, ok := wantBy[t]; ok || isDefault(header, t) || t == "exit" {
+			continue
+		}
+		if len(runBy[t].Commands()) > 0 {
+			out.AddChild(removeSection(header, t))
+			continue
+		}
+		if overridden[SettingKey(header, t)] {
+			continue
+		}
+		if cmd := negate(t); cmd != "" {
+			out.AddChild(cmd)
+		}
+	}
+
+	for _, t := range wantOrder {
+		// a default only needs saying when running overrides it
+		if t == "exit" || isDefault(header, t) && !present[SettingKey(header, t)] {
+			continue
+		}
+		wn := wantBy[t]
+		rn, ok := runBy[t]
+		if !ok {
+			copyCommands(wn, out.AddChild(t))
+			continue
+		}
+		if len(wn.Commands()) == 0 && len(rn.Commands()) == 0 {
+			continue
+		}
+		sub := &Node{Text: t}
+		diffSection(rn, wn, t, sub)
+		if len(sub.Children) > 0 {
+			c := out.AddChild(t)
+			for _, s := range sub.Children {
+				s.Parent = c
+			}
+			c.Children = sub.Children
+		}
+	}
+}
+
+// index lists the commands of n by normalised text, in first-seen order.
+// Repeated sections are merged into one synthetic node.
+func index(n *Node) ([]string, map[string]*Node) {
+	var order []string
+	by := map[string]*Node{}
+	for _, c := range n.Commands() {
+		t := normalize(c.Text)
+		prev, ok := by[t]
+		if !ok {
+			order = append(order, t)
+			by[t] = c
+			continue
+		}
+		merged := &Node{Text: t, Children: append(append([]*Node(nil), prev.Children...), c.Children...)}
+		by[t] = merged
+	}
+	return order, by
+}
+
+// copyCommands copies the commands below src to dst.
+func copyCommands(src, dst *Node) {
+	for _, c := range src.Commands() {
+		copyCommands(c, dst.AddChild(normalize(c.Text)))
+	}
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func isDefault(header, line string) bool {
+	for _, d := range implicitDefaults {
+		if d.line != line {
+			continue
+		}
+		if (d.section == "" && header == "") || (d.section != "" && strings.HasPrefix(header, d.section)) {
+			return true
+		}
+	}
+	return false
+}
+
+// negate returns the command that undoes line.
+func negate(line string) string {
+	switch {
+	case strings.HasPrefix(line, "no "):
+		return "default " + strings.TrimPrefix(line, "no ")
+	case strings.HasPrefix(line, "default "):
+		return ""
+	}
+	return "no " + line
+}
+
+// removeSection returns the command that removes a whole section.
+// Physical interfaces can't be deleted, only reset.
+func removeSection(parent, header string) string {
+	if parent == "" && (strings.HasPrefix(header, "interface Ethernet") || strings.HasPrefix(header, "interface Management")) {
+		return "default " + header
+	}
+	return "no " + header
+}
+
+// SettingKey identifies what a line of the section with the given header
+// ("" for the top level) sets, so that "router-id 10.0.0.1" and
+// "router-id 10.0.0.2", or "shutdown" and "no shutdown", are recognised as
+// the same setting.
+func SettingKey(section, line string) string {
+	base := strings.TrimPrefix(strings.TrimPrefix(line, "no "), "default ")
+	words := strings.Fields(base)
+	if len(words) > 0 && words[len(words)-1] == "secondary" {
+		// an interface has any number of secondaries, besides its primary
+		return base
+	}
+	if len(words) >= 3 && words[0] == "neighbor" {
+		// neighbor <peer> <attribute> ...; "peer group" is two words
+		if words[2] == "peer" && len(words) >= 4 && words[3] == "group" {
+			return strings.Join(words[:4], " ")
+		}
+		return strings.Join(words[:3], " ")
+	}
+	// the longest matching prefix wins: "router-id interface" over "router-id"
+	best, bestLen := "", 0
+	for prefix, n := range singleValued {
+		pw := strings.Fields(prefix)
+		if len(pw) > bestLen && len(words) > len(pw) && strings.Join(words[:len(pw)], " ") == prefix {
+			best, bestLen = strings.Join(words[:min(n, len(words))], " "), len(pw)
+		}
+	}
+	for _, sv := range sectionSingleValued {
+		pw := strings.Fields(sv.prefix)
+		if strings.HasPrefix(section, sv.section) && len(pw) > bestLen && len(words) > len(pw) && strings.Join(words[:len(pw)], " ") == sv.prefix {
+			best, bestLen = strings.Join(words[:min(sv.words, len(words))], " "), len(pw)
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return base
+}
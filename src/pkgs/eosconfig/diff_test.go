@@ -0,0 +1,174 @@
+package eosconfig
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, cfg string) *Node {
+	t.Helper()
+	n, err := ParseString(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDiffIgnoresOrderAndRepeats(t *testing.T) {
+	root, src := parseFile(t, leaf1)
+	// same config, sections in another order and Ethernet3 in one block
+	reordered := mustParse(t, `hostname leaf1
+username admin privilege 15 secret admin
+interface Ethernet3
+   switchport access vlan 10
+   switchport
+   spanning-tree portfast
+   no shutdown
+`)
+	for _, c := range root.Commands() {
+		if !strings.HasPrefix(c.Text, "hostname") && !strings.HasPrefix(c.Text, "username") && !strings.HasPrefix(c.Text, "interface Ethernet3") {
+			reordered.Children = append([]*Node{c}, reordered.Children...)
+		}
+	}
+	if d := Diff(root, reordered); len(d.Children) != 0 {
+		t.Errorf("expected no changes, got\n%s", d)
+	}
+	if d := Diff(root, mustParse(t, src)); len(d.Children) != 0 {
+		t.Errorf("config differs from itself:\n%s", d)
+	}
+}
+
+func TestDiffRemediation(t *testing.T) {
+	running := mustParse(t, `hostname leaf1
+!
+vlan 10
+!
+interface Ethernet1
+   no switchport
+   ip address 172.16.1.1/31
+!
+interface Ethernet2
+   shutdown
+   no switchport
+   ip address 172.16.2.1/31
+!
+interface Ethernet4
+   description old
+!
+interface Loopback9
+   ip address 10.9.9.9/32
+!
+router bgp 65101
+   router-id 10.0.0.11
+   neighbor 172.16.1.0 remote-as 65000
+   neighbor 172.16.9.0 remote-as 65000
+   no bgp default ipv4-unicast
+   address-family evpn
+      neighbor SPINES-EVPN activate
+!
+end
+`)
+	intended := mustParse(t, `hostname leaf1
+!
+vlan 10
+!
+vlan 20
+   name gpu
+!
+interface Ethernet1
+   no switchport
+   mtu 9214
+   ip address 172.16.1.1/31
+!
+interface Ethernet2
+   no shutdown
+   no switchport
+   ip address 172.16.2.3/31
+!
+router bgp 65101
+   router-id 10.0.0.12
+   neighbor 172.16.1.0 remote-as 65000
+   address-family evpn
+      neighbor SPINES-EVPN activate
+      neighbor SPINES-EVPN send-community extended
+!
+end
+`)
+	d := Diff(running, intended)
+	want := []string{
+		"default interface Ethernet4",
+		"no interface Loopback9",
+		"vlan 20",
+		"name gpu",
+		"exit",
+		"interface Ethernet1",
+		"mtu 9214",
+		"exit",
+		"interface Ethernet2",
+		"no shutdown",
+		"ip address 172.16.2.3/31",
+		"exit",
+		"router bgp 65101",
+		"no neighbor 172.16.9.0 remote-as 65000",
+		"default bgp default ipv4-unicast",
+		"router-id 10.0.0.12",
+		"address-family evpn",
+		"neighbor SPINES-EVPN send-community extended",
+		"exit",
+		"exit",
+	}
+	if got := d.Flatten(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\n\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	// the indented form reads like a config
+	if !strings.Contains(d.String(), "interface Ethernet2\n   no shutdown\n   ip address 172.16.2.3/31\n") {
+		t.Errorf("unexpected tree:\n%s", d)
+	}
+}
+
+func TestDiffNegationFlip(t *testing.T) {
+	running := mustParse(t, "interface Vlan10\n   shutdown\n")
+	intended := mustParse(t, "interface Vlan10\n   no shutdown\n")
+	if got := strings.Join(Diff(running, intended).Flatten(), "|"); got != "interface Vlan10|no shutdown|exit" {
+		t.Errorf("got %q", got)
+	}
+	if got := strings.Join(Diff(intended, running).Flatten(), "|"); got != "interface Vlan10|shutdown|exit" {
+		t.Errorf("reverse got %q", got)
+	}
+	// dropping an explicit shutdown brings back the default
+	running = mustParse(t, "interface Ethernet5\n   shutdown\n   mtu 9214\n")
+	intended = mustParse(t, "interface Ethernet5\n   mtu 9214\n")
+	if got := strings.Join(Diff(running, intended).Flatten(), "|"); got != "interface Ethernet5|no shutdown|exit" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// An interface is in one VRF, but each "vrf instance" is a stanza of its
+// own: swapping VRFs removes the old instance.
+func TestDiffVRFSwap(t *testing.T) {
+	running := mustParse(t, "vrf instance GPU\n!\ninterface Vlan10\n   vrf GPU\n   ip address virtual 10.10.10.1/24\n")
+	intended := mustParse(t, "vrf instance STORAGE\n!\ninterface Vlan10\n   vrf STORAGE\n   ip address virtual 10.10.10.1/24\n")
+	// EOS clears the SVI's address with the VRF move, so it is sent again
+	want := "no vrf instance GPU|vrf instance STORAGE|interface Vlan10|vrf STORAGE|ip address virtual 10.10.10.1/24|exit"
+	if got := strings.Join(Diff(running, intended).Flatten(), "|"); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if k := SettingKey("interface Vlan10", "vrf forwarding GPU"); k != "vrf" {
+		t.Errorf("interface key %q", k)
+	}
+	if k := SettingKey("", "vrf instance GPU"); k != "vrf instance GPU" {
+		t.Errorf("top-level key %q", k)
+	}
+}
+
+// Secondary addresses are settings of their own, not the primary's.
+func TestDiffSecondaryAddress(t *testing.T) {
+	running := mustParse(t, "interface Ethernet1\n   ip address 10.0.0.1/31\n   ip address 10.1.0.1/31 secondary\n")
+	intended := mustParse(t, "interface Ethernet1\n   ip address 10.0.0.1/31\n")
+	if got := strings.Join(Diff(running, intended).Flatten(), "|"); got != "interface Ethernet1|no ip address 10.1.0.1/31 secondary|exit" {
+		t.Errorf("got %q", got)
+	}
+	if got := strings.Join(Diff(intended, running).Flatten(), "|"); got != "interface Ethernet1|ip address 10.1.0.1/31 secondary|exit" {
+		t.Errorf("reverse got %q", got)
+	}
+}
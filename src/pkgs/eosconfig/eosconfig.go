@@ -0,0 +1,247 @@
+// Package eosconfig parses EOS running and startup configs into a tree of
+// sections, following the indentation EOS uses for modes and sub-modes.
+//
+//	root, _ := eosconfig.ParseString(cfg)
+//	for _, n := range root.Select("router bgp", "neighbor") { ... }
+//
+// The tree keeps every source line, so String gives back the original text.
+package eosconfig
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Kind tells commands apart from the lines that only shape the file.
+type Kind int
+
+const (
+	Command Kind = iota // a configuration command
+	Comment             // "!" separators and "! ..." comments
+	Blank               // empty lines
+	End                 // the final "end"
+)
+
+// Node is one line of a config and, for commands that open a mode, the
+// lines indented below it. The root node has no text and holds the
+// top-level lines.
+type Node struct {
+	Kind     Kind
+	Text     string // trimmed line, e.g. "router bgp 65101"
+	Indent   int    // leading spaces in the source
+	Line     int    // 1-based source line, 0 for added nodes
+	Raw      string // source line as read; "" for added nodes
+	Parent   *Node
+	Children []*Node
+}
+
+// ParseString parses a config held in a string.
+func ParseString(cfg string) (*Node, error) {
+	return Parse(strings.NewReader(cfg))
+}
+
+// Parse reads a config and returns its root. A line belongs to the
+// closest preceding command that is indented less; comments and blank
+// lines are kept where they appear but never open a section.
+func Parse(r io.Reader) (*Node, error) {
+	root := &Node{Indent: -1}
+	stack := []*Node{root}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		raw := strings.TrimRight(sc.Text(), "\r")
+		text := strings.TrimSpace(raw)
+		indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+		n := &Node{Text: text, Indent: indent, Line: lineNo, Raw: raw}
+		switch {
+		case text == "":
+			n.Kind = Blank
+			// a blank line doesn't tell which section it is in
+			stack[len(stack)-1].Children = append(stack[len(stack)-1].Children, n)
+			n.Parent = stack[len(stack)-1]
+			continue
+		case strings.HasPrefix(text, "!"):
+			n.Kind = Comment
+		case text == "end" && indent == 0:
+			n.Kind = End
+		}
+		for len(stack) > 1 && stack[len(stack)-1].Indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		n.Parent = parent
+		parent.Children = append(parent.Children, n)
+		if n.Kind == Command {
+			stack = append(stack, n)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// String renders n and everything below it back to text. Parsed lines are
+// written as they were read; added lines are indented three spaces per
+// level, the way EOS prints them.
+func (n *Node) String() string {
+	var b strings.Builder
+	n.write(&b)
+	return b.String()
+}
+
+func (n *Node) write(b *strings.Builder) {
+	if n.Parent != nil {
+		if n.Raw != "" || n.Kind == Blank {
+			b.WriteString(n.Raw)
+		} else {
+			b.WriteString(strings.Repeat("   ", n.Depth()-1))
+			b.WriteString(n.Text)
+		}
+		b.WriteByte('\n')
+	}
+	for _, c := range n.Children {
+		c.write(b)
+	}
+}
+
+// Depth is 1 for top-level lines, 2 for the lines in their mode and so on;
+// the root is 0.
+func (n *Node) Depth() int {
+	d := 0
+	for p := n; p.Parent != nil; p = p.Parent {
+		d++
+	}
+	return d
+}
+
+// Words splits the line into its words.
+func (n *Node) Words() []string {
+	return strings.Fields(n.Text)
+}
+
+// Commands returns the command children of n, skipping comments, blank
+// lines and "end".
+func (n *Node) Commands() []*Node {
+	var out []*Node
+	for _, c := range n.Children {
+		if c.Kind == Command {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Match reports whether the line starts with the words of pattern, where
+// "*" stands for any one word: "router bgp" matches "router bgp 65101",
+// "neighbor * remote-as" matches "neighbor 10.0.0.1 remote-as 65000".
+func (n *Node) Match(pattern string) bool {
+	if n.Kind != Command {
+		return false
+	}
+	words := n.Words()
+	pw := strings.Fields(pattern)
+	if len(pw) > len(words) {
+		return false
+	}
+	for i, p := range pw {
+		if p != "*" && p != words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Select follows path from n: the children matching path[0], their children
+// matching path[1] and so on. Select("router bgp", "neighbor") lists every
+// neighbor line directly under router bgp.
+func (n *Node) Select(path ...string) []*Node {
+	cur := []*Node{n}
+	for _, pattern := range path {
+		var next []*Node
+		for _, c := range cur {
+			for _, child := range c.Children {
+				if child.Match(pattern) {
+					next = append(next, child)
+				}
+			}
+		}
+		cur = next
+	}
+	return cur
+}
+
+// Find returns every command below n, at any depth, matching pattern.
+func (n *Node) Find(pattern string) []*Node {
+	var out []*Node
+	n.Walk(func(c *Node) bool {
+		if c != n && c.Match(pattern) {
+			out = append(out, c)
+		}
+		return true
+	})
+	return out
+}
+
+// Child returns the first child matching pattern, or nil.
+func (n *Node) Child(pattern string) *Node {
+	for _, c := range n.Children {
+		if c.Match(pattern) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Has reports whether a child matches pattern, e.g. interface nodes with
+// Has("no switchport") are routed ports.
+func (n *Node) Has(pattern string) bool {
+	return n.Child(pattern) != nil
+}
+
+// Walk calls fn for n and every node below it in file order. Returning
+// false skips the children of that node.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Path returns the lines from the top-level section down to n, e.g.
+// ["router bgp 65101", "address-family evpn", "neighbor X activate"].
+func (n *Node) Path() []string {
+	var path []string
+	for p := n; p.Parent != nil; p = p.Parent {
+		path = append([]string{p.Text}, path...)
+	}
+	return path
+}
+
+// AddChild appends a command below n and returns it.
+func (n *Node) AddChild(text string) *Node {
+	c := &Node{Text: strings.TrimSpace(text), Parent: n}
+	if n.Parent != nil {
+		c.Indent = n.Indent + 3
+	}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// Remove detaches n, and everything below it, from its parent.
+func (n *Node) Remove() {
+	if n.Parent == nil {
+		return
+	}
+	siblings := n.Parent.Children
+	for i, c := range siblings {
+		if c == n {
+			n.Parent.Children = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	n.Parent = nil
+}
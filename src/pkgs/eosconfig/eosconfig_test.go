@@ -0,0 +1,128 @@
+package eosconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func parseFile(t *testing.T, name string) (*Node, string) {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ParseString(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, string(b)
+}
+
+// leaf1 is a hand-edited leaf1.cfg from before the configs were generated:
+// two-space indents, sections given twice and lines after a sub-mode "!".
+var leaf1 = filepath.Join("testdata", "leaf1.cfg")
+
+func TestRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "configs", "*.cfg"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no configs: %v", err)
+	}
+	for _, f := range append(files, leaf1) {
+		root, src := parseFile(t, f)
+		if got := root.String(); got != strings.TrimSuffix(src, "\n")+"\n" {
+			t.Errorf("%s does not round-trip", f)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	root, _ := parseFile(t, leaf1)
+
+	bgp := root.Child("router bgp")
+	if bgp == nil || bgp.Words()[2] != "65101" || bgp.Line != 30 {
+		t.Fatalf("router bgp = %+v", bgp)
+	}
+	evpn := bgp.Child("address-family evpn")
+	if evpn == nil || !evpn.Has("neighbor SPINES-EVPN activate") {
+		t.Fatal("address-family evpn not nested under router bgp")
+	}
+	// "   !" closes the address family; what follows is back in router bgp
+	if n := bgp.Child("neighbor 10.0.0.1 peer group SPINES-EVPN remote-as"); n == nil || n.Parent != bgp {
+		t.Error("line after sub-mode separator attached to the wrong section")
+	}
+	vlan := bgp.Child("vlan 10")
+	if vlan == nil || len(vlan.Commands()) != 4 {
+		t.Fatalf("vlan 10 under router bgp = %v", vlan)
+	}
+	if got := strings.Join(vlan.Commands()[1].Path(), " > "); got != "router bgp 65101 > vlan 10 > route-target import 65000:1010" {
+		t.Errorf("path = %q", got)
+	}
+
+	// two-space indentation is as good as three
+	mgmt := root.Child("management api http-commands")
+	if mgmt == nil || !mgmt.Has("protocol https") || !mgmt.Has("no shutdown") {
+		t.Error("management api children not found")
+	}
+
+	var last *Node
+	for _, c := range root.Children {
+		if c.Kind != Blank {
+			last = c
+		}
+	}
+	if last.Kind != End {
+		t.Errorf("last top-level node is %q, not end", last.Text)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	root, _ := parseFile(t, leaf1)
+
+	var neighbors []string
+	for _, n := range root.Select("router bgp", "neighbor") {
+		neighbors = append(neighbors, n.Words()[1])
+	}
+	if len(neighbors) != 12 || neighbors[0] != "172.16.1.0" {
+		t.Errorf("bgp neighbor lines = %v", neighbors)
+	}
+	if n := root.Select("router bgp", "neighbor * remote-as"); len(n) != 4 {
+		t.Errorf("neighbor * remote-as matched %d lines", len(n))
+	}
+
+	var routed []string
+	for _, intf := range root.Select("interface") {
+		if intf.Has("no switchport") {
+			routed = append(routed, intf.Words()[1])
+		}
+	}
+	if strings.Join(routed, ",") != "Ethernet1,Ethernet2" {
+		t.Errorf("routed interfaces = %v", routed)
+	}
+
+	// Ethernet3 is configured twice in this file
+	if n := root.Select("interface Ethernet3"); len(n) != 2 {
+		t.Errorf("interface Ethernet3 sections = %d", len(n))
+	}
+	if n := root.Find("redistribute"); len(n) != 2 {
+		t.Errorf("redistribute anywhere = %d", len(n))
+	}
+}
+
+func TestEditing(t *testing.T) {
+	root, err := ParseString("hostname leaf1\n!\ninterface Ethernet1\n   no switchport\n!\nend\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	intf := root.Child("interface Ethernet1")
+	intf.AddChild("mtu 9214")
+	vlan := root.AddChild("vlan 20")
+	vlan.AddChild("name gpu")
+	root.Child("hostname").Remove()
+
+	want := "!\ninterface Ethernet1\n   no switchport\n   mtu 9214\n!\nend\nvlan 20\n   name gpu\n"
+	if got := root.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+package eoslint
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+// bgpSections returns every "router bgp" stanza of cfg.
+func bgpSections(cfg Config) []*eosconfig.Node {
+	return cfg.Root.Select("router bgp")
+}
+
+// selectAll runs Select(path...) on each of nodes.
+func selectAll(nodes []*eosconfig.Node, path ...string) []*eosconfig.Node {
+	var out []*eosconfig.Node
+	for _, n := range nodes {
+		out = append(out, n.Select(path...)...)
+	}
+	return out
+}
+
+func peerGroupsNotActivated(cfg Config) []Finding {
+	r := rule("peer-group-not-activated")
+	bgp := bgpSections(cfg)
+	var out []Finding
+	for _, def := range selectAll(bgp, "neighbor * peer group") {
+		if len(def.Words()) != 4 {
+			continue // "neighbor <peer> peer group <name>" assigns a member
+		}
+		group := def.Words()[1]
+		if len(selectAll(bgp, "address-family *", "neighbor "+group+" activate")) == 0 {
+			out = append(out, r.finding(cfg, def,
+				"peer group %s is not activated in any address family", group))
+		}
+	}
+	return out
+}
+
+func evpnSendCommunity(cfg Config) []Finding {
+	r := rule("evpn-send-community")
+	bgp := bgpSections(cfg)
+	sends := func(peer string, scopes []*eosconfig.Node) bool {
+		for _, n := range selectAll(scopes, "neighbor "+peer+" send-community") {
+			// a bare "send-community" sends every kind
+			if w := n.Words(); len(w) == 3 || slices.Contains(w[3:], "extended") {
+				return true
+			}
+		}
+		return false
+	}
+	var out []Finding
+	for _, af := range selectAll(bgp, "address-family evpn") {
+		scopes := append([]*eosconfig.Node{af}, bgp...)
+		for _, act := range af.Select("neighbor * activate") {
+			peer := act.Words()[1]
+			peers := []string{peer}
+			for _, m := range selectAll(bgp, "neighbor "+peer+" peer group") {
+				if len(m.Words()) >= 5 {
+					peers = append(peers, m.Words()[4])
+				}
+			}
+			if !slices.ContainsFunc(peers, func(p string) bool { return sends(p, scopes) }) {
+				out = append(out, r.finding(cfg, act,
+					"%s is activated for EVPN without send-community extended; its routes carry no route targets", peer))
+			}
+		}
+	}
+	return out
+}
+
+// macVRF is the EVPN config of one VLAN on one leaf.
+type macVRF struct {
+	cfg      Config
+	vlan     *eosconfig.Node
+	vni      string
+	rd       *eosconfig.Node
+	imports  string
+	exports  string
+	hostname string
+}
+
+func vniRDRT(cfgs []Config) []Finding {
+	r := rule("vni-rd-rt")
+	var (
+		out   []Finding
+		byVNI = map[string][]macVRF{}
+		vnis  []string
+	)
+	for _, cfg := range cfgs {
+		vniOf := map[string]string{}
+		for _, m := range cfg.Root.Find("vxlan vlan * vni") {
+			if w := m.Words(); len(w) >= 5 {
+				vniOf[w[2]] = w[4]
+			}
+		}
+		for _, vlan := range selectAll(bgpSections(cfg), "vlan") {
+			if len(vlan.Commands()) == 0 || len(vlan.Words()) != 2 {
+				continue
+			}
+			id := vlan.Words()[1]
+			vni, ok := vniOf[id]
+			if !ok {
+				out = append(out, r.finding(cfg, vlan,
+					"vlan %s is advertised in EVPN but not mapped to a VNI on the Vxlan interface", id))
+				continue
+			}
+			m := macVRF{cfg: cfg, vlan: vlan, vni: vni, rd: vlan.Child("rd"), hostname: cfg.Hostname()}
+			m.imports = routeTargets(vlan, "import")
+			m.exports = routeTargets(vlan, "export")
+			if _, ok := byVNI[vni]; !ok {
+				vnis = append(vnis, vni)
+			}
+			byVNI[vni] = append(byVNI[vni], m)
+		}
+	}
+
+	rdUsers := map[string]macVRF{}
+	for _, vni := range vnis {
+		leaves := byVNI[vni]
+		for _, dir := range []string{"import", "export"} {
+			get := func(m macVRF) string {
+				if dir == "import" {
+					return m.imports
+				}
+				return m.exports
+			}
+			common := majority(leaves, get)
+			for _, m := range leaves {
+				if got := get(m); got != common {
+					var others []string
+					for _, o := range leaves {
+						if get(o) == common {
+							others = append(others, o.hostname)
+						}
+					}
+					out = append(out, r.finding(m.cfg, m.vlan,
+						"VNI %s %ss route targets [%s] here but [%s] on %s",
+						vni, dir, got, common, strings.Join(others, ", ")))
+				}
+			}
+		}
+		for _, m := range leaves {
+			if m.rd == nil {
+				out = append(out, r.finding(m.cfg, m.vlan, "VNI %s has no rd", vni))
+				continue
+			}
+			rd := m.rd.Words()[1]
+			if rd == "auto" {
+				// EOS derives it from the router ID, unique per leaf
+				continue
+			}
+			if prev, ok := rdUsers[rd]; ok {
+				out = append(out, r.finding(m.cfg, m.rd,
+					"rd %s is also used on %s for VNI %s", rd, prev.hostname, prev.vni))
+				continue
+			}
+			rdUsers[rd] = m
+		}
+	}
+	return out
+}
+
+// routeTargets lists the route targets of a mac-vrf in direction dir,
+// counting "route-target both" for either.
+func routeTargets(vlan *eosconfig.Node, dir string) string {
+	var rts []string
+	for _, n := range vlan.Select("route-target") {
+		if w := n.Words(); len(w) >= 3 && (w[1] == dir || w[1] == "both") {
+			rts = append(rts, w[2:]...)
+		}
+	}
+	slices.Sort(rts)
+	return strings.Join(slices.Compact(rts), " ")
+}
+
+// majority returns the most common value of get over leaves; ties go to
+// the value seen first.
+func majority(leaves []macVRF, get func(macVRF) string) string {
+	count := map[string]int{}
+	best := ""
+	for i, m := range leaves {
+		v := get(m)
+		count[v]++
+		if i == 0 || count[v] > count[best] {
+			best = v
+		}
+	}
+	return best
+}
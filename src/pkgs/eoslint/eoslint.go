@@ -0,0 +1,169 @@
+// Package eoslint checks EOS configs for mistakes that EOS itself accepts:
+// stanzas configured twice or contradicting each other, BGP peer groups
+// that never carry routes, EVPN sessions without extended communities,
+// leaves that disagree on the RD/RT of a VNI, and switches this tool can't
+// reach because eAPI is off.
+//
+//	cfgs, _ := eoslint.Load("configs/leaf1.cfg", "configs/leaf2.cfg")
+//	for _, f := range eoslint.Lint(cfgs) { fmt.Println(f) }
+package eoslint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+// Level is how serious a finding is. The values are SARIF levels.
+type Level string
+
+const (
+	Error   Level = "error"
+	Warning Level = "warning"
+	Note    Level = "note"
+)
+
+// Finding is one problem in one config.
+type Finding struct {
+	Rule    string `json:"rule"`
+	Level   Level  `json:"level"`
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"` // 0 when the problem is something missing
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	where := f.File
+	if f.Line > 0 {
+		where = fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", where, f.Level, f.Message, f.Rule)
+}
+
+// Config is a parsed config and the file it came from.
+type Config struct {
+	File string
+	Root *eosconfig.Node
+}
+
+// Hostname returns the configured hostname, or the file name without its
+// extension.
+func (c Config) Hostname() string {
+	if h := c.Root.Child("hostname"); h != nil && len(h.Words()) > 1 {
+		return h.Words()[1]
+	}
+	base := filepath.Base(c.File)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Load parses config files. A directory stands for the *.cfg files in it.
+func Load(paths ...string) ([]Config, error) {
+	var cfgs []Config
+	for _, p := range paths {
+		files := []string{p}
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			files, _ = filepath.Glob(filepath.Join(p, "*.cfg"))
+		}
+		for _, f := range files {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			root, err := eosconfig.ParseString(string(b))
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", f, err)
+			}
+			cfgs = append(cfgs, Config{File: f, Root: root})
+		}
+	}
+	return cfgs, nil
+}
+
+// Rule is one check. Per-config rules set Check; rules that compare
+// configs across the fabric set Fabric.
+type Rule struct {
+	ID          string
+	Level       Level
+	Description string
+	Check       func(cfg Config) []Finding
+	Fabric      func(cfgs []Config) []Finding
+}
+
+// finding builds a finding of rule r at node n of cfg.
+func (r Rule) finding(cfg Config, n *eosconfig.Node, format string, args ...any) Finding {
+	f := Finding{Rule: r.ID, Level: r.Level, File: cfg.File, Message: fmt.Sprintf(format, args...)}
+	if n != nil {
+		f.Line = n.Line
+	}
+	return f
+}
+
+// Rules are the checks Lint runs, in the order their findings are listed
+// for the same line.
+var Rules []Rule
+
+func init() {
+	Rules = []Rule{
+		{ID: "duplicate-section", Level: Warning, Check: duplicateSections,
+			Description: "A section is configured more than once; later stanzas merge into the first."},
+		{ID: "duplicate-line", Level: Warning, Check: duplicateLines,
+			Description: "A line repeats something its section already configures."},
+		{ID: "contradiction", Level: Error, Check: contradictions,
+			Description: "A line overrides or resets configuration made earlier in the same file."},
+		{ID: "peer-group-not-activated", Level: Error, Check: peerGroupsNotActivated,
+			Description: "A BGP peer group is not activated in any address family."},
+		{ID: "evpn-send-community", Level: Error, Check: evpnSendCommunity,
+			Description: "An EVPN peer doesn't send extended communities, so its routes carry no route targets."},
+		{ID: "vni-rd-rt", Level: Error, Fabric: vniRDRT,
+			Description: "Leaves disagree on the route targets of a VNI, or reuse a route distinguisher."},
+		{ID: "eapi-disabled", Level: Error, Check: eapiDisabled,
+			Description: "eAPI (management api http-commands) is not enabled."},
+	}
+}
+
+// rule returns the rule with the given ID.
+func rule(id string) Rule {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r
+		}
+	}
+	panic("eoslint: unknown rule " + id)
+}
+
+// Lint runs every rule over cfgs and returns the findings ordered by file
+// and line.
+func Lint(cfgs []Config) []Finding {
+	var out []Finding
+	for _, r := range Rules {
+		if r.Check != nil {
+			for _, c := range cfgs {
+				out = append(out, r.Check(c)...)
+			}
+		}
+		if r.Fabric != nil {
+			out = append(out, r.Fabric(cfgs)...)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].File != out[j].File {
+			return out[i].File < out[j].File
+		}
+		return out[i].Line < out[j].Line
+	})
+	return out
+}
+
+// Failed reports whether any finding is an error.
+func Failed(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Level == Error {
+			return true
+		}
+	}
+	return false
+}
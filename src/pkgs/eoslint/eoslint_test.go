@@ -0,0 +1,251 @@
+package eoslint
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+func config(t *testing.T, file, text string) Config {
+	t.Helper()
+	root, err := eosconfig.ParseString(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{File: file, Root: root}
+}
+
+// summary renders findings as "file:line rule" for comparison.
+func summary(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, strings.TrimSuffix(filepath.Base(f.File), ".cfg")+":"+strconv.Itoa(f.Line)+" "+f.Rule)
+	}
+	return out
+}
+
+const eapi = "management api http-commands\n   no shutdown\n"
+
+func TestStanzaRules(t *testing.T) {
+	cfg := config(t, "sw.cfg", `hostname sw
+interface Ethernet1
+   no switchport
+   mtu 9214
+interface Ethernet1
+   mtu 1500
+   no switchport
+   switchport
+router bgp 65001
+   neighbor 10.0.0.1 peer group SPINES
+   neighbor 10.0.0.1 remote-as 65000
+   neighbor 10.0.0.1  peer group SPINES remote-as 65000
+   neighbor 10.0.0.1 remote-as 65001
+default interface Ethernet1
+interface Ethernet1
+   no switchport
+   ip address 10.0.0.1/31
+   ip address 10.1.0.1/31 secondary
+`+eapi)
+	got := strings.Join(summary(Lint([]Config{cfg})), "\n")
+	// lines 17-18, a primary and a secondary address, are fine
+	want := strings.Join([]string{
+		"sw:5 duplicate-section",
+		"sw:6 contradiction",   // mtu 1500 over mtu 9214
+		"sw:7 duplicate-line",  // no switchport again
+		"sw:8 contradiction",   // switchport over no switchport
+		"sw:12 duplicate-line", // the one-line form of lines 10 and 11
+		"sw:13 contradiction",  // remote-as 65001 over 65000
+		"sw:14 contradiction",  // default interface throws away lines 2-8
+		"sw:15 duplicate-section",
+	}, "\n")
+	if got != want {
+		t.Errorf("findings:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Each VRF has its own vrf instance stanza; only an interface's VRF is a
+// single setting.
+func TestStanzaRulesVRFs(t *testing.T) {
+	cfg := config(t, "sw.cfg", `vrf instance A
+vrf instance B
+interface Vlan10
+   vrf A
+   vrf B
+`+eapi)
+	if got := strings.Join(summary(Lint([]Config{cfg})), "\n"); got != "sw:5 contradiction" {
+		t.Errorf("findings:\n%s", got)
+	}
+}
+
+func TestBGPRules(t *testing.T) {
+	cfg := config(t, "leaf.cfg", `router bgp 65101
+   neighbor UNUSED peer group
+   neighbor EVPN peer group
+   neighbor 10.0.0.1 peer group EVPN
+   neighbor 10.0.0.9 remote-as 65000
+   address-family evpn
+      neighbor EVPN activate
+      neighbor 10.0.0.9 activate
+!
+`+eapi)
+	got := strings.Join(summary(Lint([]Config{cfg})), "\n")
+	want := "leaf:2 peer-group-not-activated\nleaf:7 evpn-send-community\nleaf:8 evpn-send-community"
+	if got != want {
+		t.Errorf("findings:\n%s\nwant:\n%s", got, want)
+	}
+
+	// send-community on the peer group covers its members; a bare
+	// send-community sends extended communities too
+	cfg = config(t, "leaf.cfg", `router bgp 65101
+   neighbor EVPN peer group
+   neighbor EVPN send-community extended
+   neighbor 10.0.0.1 peer group EVPN
+   neighbor 10.0.0.9 send-community
+   address-family evpn
+      neighbor 10.0.0.1 activate
+      neighbor 10.0.0.9 activate
+      neighbor EVPN activate
+`+eapi)
+	if f := Lint([]Config{cfg}); len(f) != 0 {
+		t.Errorf("unexpected findings: %v", f)
+	}
+}
+
+func TestVNIRule(t *testing.T) {
+	leaf := func(name, rd, rt string) Config {
+		return config(t, name+".cfg", "hostname "+name+`
+interface Vxlan1
+   vxlan vlan 10 vni 1010
+router bgp 65101
+   vlan 10
+      rd `+rd+`
+      route-target both `+rt+`
+   vlan 20
+      rd `+rd+`0
+`+eapi)
+	}
+	cfgs := []Config{
+		leaf("leaf1", "10.0.0.11:10", "65000:1010"),
+		leaf("leaf2", "10.0.0.12:10", "65000:1010"),
+		leaf("leaf3", "10.0.0.11:10", "65000:1011"),
+		leaf("leaf4", "auto", "65000:1010"),
+		leaf("leaf5", "auto", "65000:1010"),
+	}
+	var msgs []string
+	for _, f := range Lint(cfgs) {
+		msgs = append(msgs, filepath.Base(f.File)+":"+strconv.Itoa(f.Line)+" "+f.Message)
+	}
+	want := []string{
+		"leaf1.cfg:8 vlan 20 is advertised in EVPN but not mapped to a VNI on the Vxlan interface",
+		"leaf2.cfg:8 vlan 20 is advertised in EVPN but not mapped to a VNI on the Vxlan interface",
+		"leaf3.cfg:5 VNI 1010 imports route targets [65000:1011] here but [65000:1010] on leaf1, leaf2, leaf4, leaf5",
+		"leaf3.cfg:5 VNI 1010 exports route targets [65000:1011] here but [65000:1010] on leaf1, leaf2, leaf4, leaf5",
+		"leaf3.cfg:6 rd 10.0.0.11:10 is also used on leaf1 for VNI 1010",
+		"leaf3.cfg:8 vlan 20 is advertised in EVPN but not mapped to a VNI on the Vxlan interface",
+		"leaf4.cfg:8 vlan 20 is advertised in EVPN but not mapped to a VNI on the Vxlan interface",
+		"leaf5.cfg:8 vlan 20 is advertised in EVPN but not mapped to a VNI on the Vxlan interface",
+	}
+	if got := strings.Join(msgs, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("findings:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestEAPIRule(t *testing.T) {
+	for text, want := range map[string]string{
+		"hostname a\n": "a:0 eapi-disabled",
+		"management api http-commands\n   protocol https\n":              "a:1 eapi-disabled",
+		"management api http-commands\n   protocol https\n   shutdown\n": "a:1 eapi-disabled",
+		"management api http-commands\n  no shutdown\n":                  "",
+	} {
+		got := strings.Join(summary(Lint([]Config{config(t, "a.cfg", text)})), "")
+		if got != want {
+			t.Errorf("%q: got %q, want %q", text, got, want)
+		}
+	}
+}
+
+// TestLabConfigs pins what the linter says about the lab's own configs,
+// which are generated and clean, and about the hand-edited leaf1 they
+// replaced.
+func TestLabConfigs(t *testing.T) {
+	cfgs, err := Load(filepath.Join("..", "..", "..", "configs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(Lint(cfgs)); len(got) > 0 {
+		t.Errorf("lab findings:\n%s", strings.Join(got, "\n"))
+	}
+
+	cfgs, err = Load(filepath.Join("..", "eosconfig", "testdata", "leaf1.cfg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaf1 []string
+	for _, s := range summary(Lint(cfgs)) {
+		if strings.HasPrefix(s, "leaf1:") {
+			leaf1 = append(leaf1, s)
+		}
+	}
+	want := []string{
+		"leaf1:46 duplicate-line",
+		"leaf1:47 duplicate-line",
+		"leaf1:57 contradiction",
+		"leaf1:58 duplicate-line",
+		"leaf1:64 duplicate-section",
+	}
+	if strings.Join(leaf1, "\n") != strings.Join(want, "\n") {
+		t.Errorf("leaf1 findings:\n%s\nwant:\n%s", strings.Join(leaf1, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	findings := []Finding{
+		{Rule: "contradiction", Level: Error, File: "configs/leaf1.cfg", Line: 57, Message: "m"},
+		{Rule: "eapi-disabled", Level: Error, File: "configs/leafa1.conf", Message: "off"},
+	}
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, findings); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           *struct{ StartLine int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(Rules) || len(run.Results) != 2 {
+		t.Fatalf("run = %+v", run)
+	}
+	loc := run.Results[0].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "configs/leaf1.cfg" || loc.Region == nil || loc.Region.StartLine != 57 {
+		t.Errorf("location = %+v", loc)
+	}
+	if run.Results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Error("finding without a line has a region")
+	}
+}
@@ -0,0 +1,20 @@
+package eoslint
+
+import (
+	"slices"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+func eapiDisabled(cfg Config) []Finding {
+	r := rule("eapi-disabled")
+	api := cfg.Root.Select("management api http-commands")
+	if len(api) == 0 {
+		return []Finding{r.finding(cfg, nil, "management api http-commands is not configured; eAPI is off")}
+	}
+	last := api[len(api)-1]
+	if !slices.ContainsFunc(api, func(n *eosconfig.Node) bool { return n.Has("no shutdown") }) {
+		return []Finding{r.finding(cfg, last, "management api http-commands is missing \"no shutdown\"; eAPI is off")}
+	}
+	return nil
+}
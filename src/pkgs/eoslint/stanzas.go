@@ -0,0 +1,121 @@
+package eoslint
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+func duplicateSections(cfg Config) []Finding { return only(scanStanzas(cfg), "duplicate-section") }
+func duplicateLines(cfg Config) []Finding    { return only(scanStanzas(cfg), "duplicate-line") }
+func contradictions(cfg Config) []Finding    { return only(scanStanzas(cfg), "contradiction") }
+
+func only(findings []Finding, id string) []Finding {
+	var out []Finding
+	for _, f := range findings {
+		if f.Rule == id {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// setting is the statement that last set a setting, and its line.
+type setting struct {
+	stmt string
+	node *eosconfig.Node
+}
+
+// scanStanzas walks cfg the way EOS applies it: repeated sections merge,
+// and "default <section>" throws away what the section had so far.
+func scanStanzas(cfg Config) []Finding {
+	var out []Finding
+	scanSection(cfg, []*eosconfig.Node{cfg.Root}, &out)
+	return out
+}
+
+// scanSection checks the merged children of parts, the stanzas of one
+// section, and recurses into their sub-sections.
+func scanSection(cfg Config, parts []*eosconfig.Node, out *[]Finding) {
+	var (
+		order    []string
+		first    = map[string]*eosconfig.Node{}   // section header -> first stanza
+		merged   = map[string][]*eosconfig.Node{} // section header -> stanzas since the last reset
+		seen     = map[string]*eosconfig.Node{}   // statement -> line that made it
+		settings = map[string]setting{}
+	)
+	for _, part := range parts {
+		for _, c := range part.Commands() {
+			t := normalize(c.Text)
+			if len(c.Commands()) > 0 {
+				if f, ok := first[t]; ok {
+					*out = append(*out, rule("duplicate-section").finding(cfg, c,
+						"%s is already configured at line %d", t, f.Line))
+				} else {
+					first[t] = c
+					order = append(order, t)
+				}
+				merged[t] = append(merged[t], c)
+				continue
+			}
+			if target, ok := strings.CutPrefix(t, "default "); ok {
+				if f, ok := first[target]; ok && len(merged[target]) > 0 {
+					*out = append(*out, rule("contradiction").finding(cfg, c,
+						"%s resets the %s configured at line %d", t, target, f.Line))
+					// what came before the reset is checked on its own
+					scanSection(cfg, merged[target], out)
+					merged[target] = nil
+				}
+				continue
+			}
+
+			var dups []string
+			stmts := expand(t)
+			for _, s := range stmts {
+				if prev, ok := seen[s]; ok {
+					dups = append(dups, strconv.Itoa(prev.Line))
+					continue
+				}
+				key := eosconfig.SettingKey(normalize(parts[0].Text), s)
+				if prev, ok := settings[key]; ok {
+					*out = append(*out, rule("contradiction").finding(cfg, c,
+						"%q overrides %q at line %d", s, prev.stmt, prev.node.Line))
+					delete(seen, prev.stmt)
+				}
+				seen[s] = c
+				settings[key] = setting{s, c}
+			}
+			if len(dups) == len(stmts) {
+				lines := "line"
+				if len(dups) > 1 {
+					lines = "lines"
+				}
+				*out = append(*out, rule("duplicate-line").finding(cfg, c,
+					"%q repeats %s %s", t, lines, strings.Join(dups, ", ")))
+			}
+		}
+	}
+	for _, t := range order {
+		if len(merged[t]) > 0 {
+			scanSection(cfg, merged[t], out)
+		}
+	}
+}
+
+// expand splits the legacy one-line form "neighbor A peer group G
+// remote-as N" into the two statements EOS stores it as.
+func expand(line string) []string {
+	w := strings.Fields(line)
+	if len(w) == 7 && w[0] == "neighbor" && w[2] == "peer" && w[3] == "group" && w[5] == "remote-as" {
+		return []string{
+			strings.Join(w[:5], " "),
+			strings.Join([]string{w[0], w[1], w[5], w[6]}, " "),
+		}
+	}
+	return []string{line}
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,227 @@
+package fabric
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eoslint"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+)
+
+const labDir = "../../.."
+
+func loadIntent(t *testing.T, doc string) *Intent {
+	t.Helper()
+	in, err := Load(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
+
+func generate(t *testing.T, in *Intent) (Files, string) {
+	t.Helper()
+	files, err := Generate(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if _, err := files.Write(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	return files, dir
+}
+
+// The checked-in lab is what fabric.yml generates.
+func TestLabIsGenerated(t *testing.T) {
+	in, err := LoadFile(filepath.Join(labDir, "fabric.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range files.Paths() {
+		want, err := os.ReadFile(filepath.Join(labDir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(files[p]) != string(want) {
+			t.Errorf("%s is out of date with fabric.yml; regenerate it with `go run . fabric`", p)
+		}
+	}
+	want := []string{"addressing.md", "configs/leaf1.cfg", "configs/leaf2.cfg", "configs/leaf3.cfg", "configs/leaf4.cfg",
+		"configs/spine1.cfg", "configs/spine2.cfg", "lab.clab.yml"}
+	if got := files.Paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("paths = %v", got)
+	}
+}
+
+func TestWriteIsIdempotent(t *testing.T) {
+	files, dir := generate(t, loadIntent(t, "{name: t, spines: 2, leaves: 2}"))
+	again, err := Generate(loadIntent(t, "{name: t, spines: 2, leaves: 2}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, again) {
+		t.Fatal("generating twice gave different files")
+	}
+	changed, err := again.Write(dir, false)
+	if err != nil || len(changed) != 0 {
+		t.Fatalf("second write changed %v (err %v)", changed, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "configs", "leaf2.cfg"), []byte("hostname edited\n"), 0o644)
+	changed, _ = again.Write(dir, true)
+	if !reflect.DeepEqual(changed, []string{"configs/leaf2.cfg"}) {
+		t.Errorf("dry run reports %v", changed)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "configs", "leaf2.cfg")); string(b) != "hostname edited\n" {
+		t.Error("dry run wrote the file")
+	}
+}
+
+// Reading the generated lab back must give the plan it came from.
+func TestGeneratedLabDerivesToPlan(t *testing.T) {
+	in := loadIntent(t, `
+name: big
+spines: 4
+leaves: 6
+bgp: {spine: 64512, leaf: 64601}
+vlans:
+  - {id: 10, subnet: 10.10.10.0/24}
+  - {id: 20, vni: 5020}
+hosts: {perLeaf: 2, prefix: h, start: 11}
+`)
+	_, dir := generate(t, in)
+	plan, err := Plan(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topo, err := clab.LoadTopology(filepath.Join(dir, TopologyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := inventory.Derive(topo, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Devices) != 4+6+12 {
+		t.Fatalf("%d devices", len(got.Devices))
+	}
+	for i, want := range plan.Devices {
+		if !reflect.DeepEqual(got.Devices[i], want) {
+			t.Errorf("%s derives to\n%+v\nwant\n%+v", want.Name, got.Devices[i], want)
+		}
+	}
+
+	leaf6, _ := plan.Get("leaf6")
+	if leaf6.ASN() != 64606 || leaf6.Loopback(1) != "10.255.0.16/32" || len(leaf6.Interfaces) != 6 {
+		t.Errorf("leaf6 = asn %d vtep %s, %d interfaces", leaf6.ASN(), leaf6.Loopback(1), len(leaf6.Interfaces))
+	}
+	if leaf6.VLANs[1].VNI != 5020 || leaf6.VLANs[1].RD != "10.0.0.16:20" || leaf6.VLANs[1].RouteTarget != "64512:5020" {
+		t.Errorf("leaf6 vlan 20 = %+v", leaf6.VLANs[1])
+	}
+	if h12, _ := plan.Get("h12"); h12.Interfaces[0].Address != "10.10.10.22/24" || h12.Links[0] != (inventory.Link{Interface: "eth1", Peer: "leaf6", PeerInterface: "eth6"}) {
+		t.Errorf("h12 = %+v %+v", h12.Interfaces, h12.Links)
+	}
+	spine4, _ := plan.Get("spine4")
+	if spine4.Interfaces[5].Address != "172.16.4.10/31" || len(spine4.BGP.Overlay.Neighbors) != 6 {
+		t.Errorf("spine4 = %+v", spine4.Interfaces)
+	}
+}
+
+func TestGeneratedConfigsLintClean(t *testing.T) {
+	_, dir := generate(t, loadIntent(t, "{name: t, spines: 2, leaves: 3, vlans: [{id: 10, subnet: 10.1.0.0/24}, {id: 30}], hosts: {perLeaf: 1}}"))
+	cfgs, err := eoslint.Load(filepath.Join(dir, "configs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 5 {
+		t.Fatalf("%d configs", len(cfgs))
+	}
+	for _, f := range eoslint.Lint(cfgs) {
+		t.Error(f)
+	}
+}
+
+// Every ASN model must generate configs that lint clean, read back to the
+// plan and pass the plan's own check.
+func TestBGPModels(t *testing.T) {
+	for _, bgp := range []string{
+		"{model: ibgp-rr, rt: auto}",
+		"{model: ebgp-per-rack}",
+		"{model: shared-spine, rd: auto, rt: auto}",
+	} {
+		in := loadIntent(t, "{name: t, spines: 2, leaves: 4, vlans: [{id: 10, subnet: 10.1.0.0/24}], hosts: {perLeaf: 1}, bgp: "+bgp+"}")
+		_, dir := generate(t, in)
+		cfgs, err := eoslint.Load(filepath.Join(dir, "configs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range eoslint.Lint(cfgs) {
+			t.Errorf("%s: %s", bgp, f)
+		}
+
+		plan, _ := Plan(in)
+		topo, _ := clab.LoadTopology(filepath.Join(dir, TopologyFile))
+		got, err := inventory.Derive(topo, dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var devs []devices.Device
+		for i, want := range plan.Devices {
+			if !reflect.DeepEqual(got.Devices[i], want) {
+				t.Errorf("%s: %s derives to\n%+v\nwant\n%+v", bgp, want.Name, got.Devices[i], want)
+			}
+			devs = append(devs, got.Devices[i].Device)
+		}
+		for _, m := range in.BGP.Check(devs) {
+			t.Errorf("%s: %s", bgp, m)
+		}
+	}
+
+	in := loadIntent(t, "{name: t, spines: 2, leaves: 2, bgp: {model: ibgp-rr, rt: auto}}")
+	files, _ := generate(t, in)
+	spine := string(files["configs/spine1.cfg"])
+	leaf := string(files["configs/leaf1.cfg"])
+	for _, want := range []string{"router bgp 65000", "neighbor EVPN-OVERLAY route-reflector-client", "neighbor 172.16.1.1 next-hop-self"} {
+		if !strings.Contains(spine, want) {
+			t.Errorf("ibgp-rr spine1 lacks %q", want)
+		}
+	}
+	if !strings.Contains(leaf, "router bgp 65000") || strings.Contains(leaf, "ebgp-multihop") {
+		t.Errorf("ibgp-rr leaf1:\n%s", leaf)
+	}
+}
+
+func TestIntentErrors(t *testing.T) {
+	for doc, want := range map[string]string{
+		"{name: t, spines: 2, leafs: 4}":                                          "field leafs not found",
+		"{spines: 2, leaves: 4}":                                                  "no name",
+		"{name: t, spines: 0, leaves: 4}":                                         "at least one spine",
+		"{name: t, spines: 1, leaves: 200}":                                       "do not fit",
+		"{name: t, spines: 12, leaves: 2}":                                        "router IDs overlap",
+		"{name: t, spines: 1, leaves: 1, underlay: 172.16.0.0}":                   "underlay",
+		"{name: t, spines: 1, leaves: 1, vlans: [{id: 10}, {id: 10}]}":            "listed twice",
+		"{name: t, spines: 1, leaves: 1, hosts: {perLeaf: 1}}":                    "hosts.vlan 0 is not in vlans",
+		"{name: t, spines: 1, leaves: 1, vlans: [{id: 10}], hosts: {perLeaf: 1}}": "has no subnet",
+		"{name: t, spines: 1, leaves: 1, bgp: {model: ospf}}":                     "bgp: unknown ASN model",
+	} {
+		_, err := Load(strings.NewReader(doc))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", doc, err, want)
+		}
+	}
+
+	in := loadIntent(t, "{name: t, spines: 1, leaves: 1, vlans: [{id: 10, subnet: 10.1.0.0/29}], hosts: {perLeaf: 9, start: 1}}")
+	if _, err := Generate(in); err == nil || !strings.Contains(err.Error(), "gpu8 address") {
+		t.Errorf("exhausted host subnet: err = %v", err)
+	}
+}
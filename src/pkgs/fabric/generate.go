@@ -0,0 +1,163 @@
+package fabric
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/addressing"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/renderer"
+)
+
+// Names of the generated files, relative to the lab directory.
+const (
+	TopologyFile   = "lab.clab.yml"
+	AddressingFile = "addressing.md"
+)
+
+// Files are generated file contents keyed by path relative to the lab
+// directory.
+type Files map[string][]byte
+
+// Paths returns the file names in sorted order.
+func (f Files) Paths() []string {
+	paths := make([]string, 0, len(f))
+	for p := range f {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// Generate renders every file of the lab described by in. The output only
+// depends on the intent, so generating twice gives the same bytes.
+func Generate(in *Intent) (Files, error) {
+	inv, err := Plan(in)
+	if err != nil {
+		return nil, err
+	}
+	files := Files{
+		TopologyFile:   topology(in, inv),
+		AddressingFile: addressing.Generate(inv),
+	}
+	for _, d := range inv.Devices {
+		if d.StartupConfig == "" {
+			continue
+		}
+		cfg, err := renderer.RenderConfig(d.Device)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasSuffix(cfg, []byte("\n")) {
+			cfg = append(cfg, '\n')
+		}
+		files[d.StartupConfig] = cfg
+	}
+	return files, nil
+}
+
+// Write puts the files under dir and returns the paths whose content
+// changed. Files that are already up to date are not touched, so running
+// the generator again is a no-op. With dryRun nothing is written.
+func (f Files) Write(dir string, dryRun bool) ([]string, error) {
+	var changed []string
+	for _, p := range f.Paths() {
+		path := filepath.Join(dir, filepath.FromSlash(p))
+		if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, f[p]) {
+			continue
+		}
+		changed = append(changed, p)
+		if dryRun {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return changed, err
+		}
+		if err := os.WriteFile(path, f[p], 0o644); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// topology writes lab.clab.yml in the layout of the hand-written original:
+// spines, leaves and hosts as groups, then the underlay links leaf by leaf
+// and the access links.
+func topology(in *Intent, inv *inventory.Inventory) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\n\ntopology:\n  nodes:\n", in.Name)
+	role := ""
+	for _, d := range inv.Devices {
+		if d.Role != role && role != "" {
+			b.WriteString("\n")
+		}
+		if d.Role == inventory.RoleHost && role != inventory.RoleHost {
+			b.WriteString("    # Linux hosts for quick EVPN L2 tests\n")
+		} else if d.Role == inventory.RoleHost {
+			b.WriteString("\n")
+		}
+		role = d.Role
+		fmt.Fprintf(&b, "    %s:\n      kind: %s\n      image: %s\n", d.Name, d.Kind, d.Image)
+		if d.StartupConfig != "" {
+			fmt.Fprintf(&b, "      startup-config: %s\n      enforce-startup-config: true\n", d.StartupConfig)
+		}
+		if d.Role == inventory.RoleHost {
+			fmt.Fprintf(&b, "      cmd: %s\n", hostCmd)
+		}
+		if len(d.Exec) > 0 {
+			b.WriteString("      exec:\n")
+			for _, e := range d.Exec {
+				fmt.Fprintf(&b, "        - %s\n", e)
+			}
+		}
+	}
+
+	b.WriteString("\n  links:\n")
+	spines := "every spine"
+	if in.Spines == 2 {
+		spines = "both spines"
+	}
+	fmt.Fprintf(&b, "    # Underlay: leaves to %s\n", spines)
+	var access []inventory.Link
+	var accessFrom []string
+	first := true
+	for _, d := range inv.Devices {
+		if d.Role != inventory.RoleLeaf {
+			continue
+		}
+		if !first {
+			b.WriteString("\n")
+		}
+		first = false
+		for _, l := range d.Links {
+			if peer, _ := inv.Get(l.Peer); peer.Role == inventory.RoleHost {
+				access = append(access, l)
+				accessFrom = append(accessFrom, d.Name)
+				continue
+			}
+			link(&b, d.Name, l)
+		}
+	}
+	if len(access) > 0 {
+		ports := "eth" + strconv.Itoa(in.Spines+1)
+		hosts := "host"
+		if in.Hosts.PerLeaf > 1 {
+			ports += "-eth" + strconv.Itoa(in.Spines+in.Hosts.PerLeaf)
+			hosts = "hosts"
+		}
+		fmt.Fprintf(&b, "\n    # Access links: each leaf -> its %s on %s\n", hosts, ports)
+		for i, l := range access {
+			link(&b, accessFrom[i], l)
+		}
+	}
+	return []byte(b.String())
+}
+
+func link(b *strings.Builder, node string, l inventory.Link) {
+	fmt.Fprintf(b, "    - endpoints: [\"%s:%s\", \"%s:%s\"]\n", node, l.Interface, l.Peer, l.PeerInterface)
+}
@@ -0,0 +1,199 @@
+// Package fabric generates a whole lab from a short intent file: how many
+// spines, leaves and hosts per leaf, the BGP numbering plan, loopback and
+// underlay pools and the VLANs to stretch. From it come the containerlab
+// topology, every cEOS startup config, the linux host exec lines and
+// addressing.md, all from the same plan so they agree with each other.
+//
+//	name: evpn-rdma-fabric
+//	spines: 2
+//	leaves: 4
+//	vlans: [{id: 10, subnet: 10.10.10.0/24}]
+//	hosts: {perLeaf: 1}
+//
+// Everything left out defaults to the values of the original lab.
+package fabric
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Intent is a fabric intent file.
+type Intent struct {
+	Name      string         `yaml:"name"` // containerlab lab name
+	Image     string         `yaml:"image"`
+	Spines    int            `yaml:"spines"`
+	Leaves    int            `yaml:"leaves"`
+	BGP       bgpplan.Plan   `yaml:"bgp"`
+	Loopbacks LoopbackPlan   `yaml:"loopbacks"`
+	Underlay  string         `yaml:"underlay"` // one /24 per spine, spine1 gets the second
+	VLANs     []VLAN         `yaml:"vlans"`
+	Hosts     HostPlan       `yaml:"hosts"`
+	Users     []devices.User `yaml:"users"`
+}
+
+// LoopbackPlan gives the pools Loopback0 (router ID) and Loopback1 (VTEP)
+// come from. Spine n takes host SpineStart+n-1 of RouterID, leaf n host
+// LeafStart+n-1 of both pools.
+type LoopbackPlan struct {
+	RouterID   string `yaml:"routerId"`
+	VTEP       string `yaml:"vtep"`
+	SpineStart int    `yaml:"spineStart"`
+	LeafStart  int    `yaml:"leafStart"`
+}
+
+// VLAN is a segment stretched to every leaf. VNI defaults to
+// netmath.VNIBase+ID; Subnet is where the hosts of the VLAN live.
+type VLAN struct {
+	ID     int    `yaml:"id"`
+	VNI    int    `yaml:"vni"`
+	Subnet string `yaml:"subnet"`
+}
+
+// HostPlan attaches PerLeaf linux hosts to every leaf, on the ports after
+// the spine uplinks, as access ports in VLAN. Host n (counting across
+// leaves) gets host Start+n-1 of the VLAN's subnet.
+type HostPlan struct {
+	PerLeaf int    `yaml:"perLeaf"`
+	Prefix  string `yaml:"prefix"` // "gpu" names them gpu1, gpu2, ...
+	Image   string `yaml:"image"`
+	VLAN    int    `yaml:"vlan"`
+	Start   int    `yaml:"start"`
+}
+
+// Load reads an intent in YAML (or JSON), fills in defaults and validates
+// it. Unknown fields are an error so typos don't pass silently.
+func Load(r io.Reader) (*Intent, error) {
+	var in Intent
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&in); err != nil {
+		return nil, fmt.Errorf("decode intent: %w", err)
+	}
+	in.setDefaults()
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+// LoadFile reads the intent at path.
+func LoadFile(path string) (*Intent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	in, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return in, nil
+}
+
+func (in *Intent) setDefaults() {
+	setDefault(&in.Image, "ceosimage:4.34.2.1f")
+	in.BGP.SetDefaults()
+	setDefault(&in.Loopbacks.RouterID, "10.0.0.0/24")
+	setDefault(&in.Loopbacks.VTEP, "10.255.0.0/24")
+	setDefault(&in.Loopbacks.SpineStart, 1)
+	setDefault(&in.Loopbacks.LeafStart, 11)
+	setDefault(&in.Underlay, "172.16.0.0/16")
+	setDefault(&in.Hosts.Prefix, "gpu")
+	setDefault(&in.Hosts.Image, "alpine:3.19")
+	setDefault(&in.Hosts.Start, 101)
+	if in.Hosts.VLAN == 0 && len(in.VLANs) > 0 {
+		in.Hosts.VLAN = in.VLANs[0].ID
+	}
+	for i := range in.VLANs {
+		if in.VLANs[i].VNI == 0 {
+			in.VLANs[i].VNI, _ = netmath.VNI(in.VLANs[i].ID) // range checked by Validate
+		}
+	}
+	if len(in.Users) == 0 {
+		in.Users = []devices.User{{Name: "admin", Privilege: 15, Secret: "admin"}}
+	}
+}
+
+func setDefault[T comparable](v *T, def T) {
+	var zero T
+	if *v == zero {
+		*v = def
+	}
+}
+
+// maxLeaves is how many /31s fit in a spine's /24 of the underlay.
+const maxLeaves = 128
+
+// Validate checks counts, pools and VLAN references. Address exhaustion is
+// caught when the plan is built.
+func (in *Intent) Validate() error {
+	switch {
+	case in.Name == "":
+		return fmt.Errorf("intent has no name")
+	case in.Spines < 1 || in.Leaves < 1:
+		return fmt.Errorf("need at least one spine and one leaf, have %d and %d", in.Spines, in.Leaves)
+	case in.Leaves > maxLeaves:
+		return fmt.Errorf("%d leaves do not fit a /24 of /31s per spine (max %d)", in.Leaves, maxLeaves)
+	case in.Hosts.PerLeaf < 0:
+		return fmt.Errorf("hosts.perLeaf is negative")
+	}
+	for _, p := range []struct{ name, prefix string }{
+		{"loopbacks.routerId", in.Loopbacks.RouterID},
+		{"loopbacks.vtep", in.Loopbacks.VTEP},
+		{"underlay", in.Underlay},
+	} {
+		if _, err := netip.ParsePrefix(p.prefix); err != nil {
+			return fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+	if err := in.BGP.Validate(); err != nil {
+		return fmt.Errorf("bgp: %w", err)
+	}
+	lb := in.Loopbacks
+	if lb.SpineStart < lb.LeafStart+in.Leaves && lb.LeafStart < lb.SpineStart+in.Spines {
+		return fmt.Errorf("spine and leaf router IDs overlap: spines from host %d, leaves from host %d", lb.SpineStart, lb.LeafStart)
+	}
+	seen := map[int]bool{}
+	for _, v := range in.VLANs {
+		if _, err := netmath.VNI(v.ID); err != nil {
+			return err
+		}
+		if seen[v.ID] {
+			return fmt.Errorf("vlan %d is listed twice", v.ID)
+		}
+		seen[v.ID] = true
+		if v.Subnet != "" {
+			if _, err := netip.ParsePrefix(v.Subnet); err != nil {
+				return fmt.Errorf("vlan %d: %w", v.ID, err)
+			}
+		}
+	}
+	if in.Hosts.PerLeaf > 0 {
+		v, ok := in.vlan(in.Hosts.VLAN)
+		if !ok {
+			return fmt.Errorf("hosts.vlan %d is not in vlans", in.Hosts.VLAN)
+		}
+		if v.Subnet == "" {
+			return fmt.Errorf("vlan %d carries hosts but has no subnet", v.ID)
+		}
+	}
+	return nil
+}
+
+func (in *Intent) vlan(id int) (VLAN, bool) {
+	for _, v := range in.VLANs {
+		if v.ID == id {
+			return v, true
+		}
+	}
+	return VLAN{}, false
+}
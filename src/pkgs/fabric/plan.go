@@ -0,0 +1,187 @@
+package fabric
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Peer group names and overlay session settings of the generated configs.
+const (
+	spinePeerGroup = "EVPN-OVERLAY"
+	leafPeerGroup  = "SPINES-EVPN"
+	overlaySource  = "Loopback0"
+	overlayHops    = 3
+	hostCmd        = "sleep infinity"
+	configDir      = "configs"
+)
+
+// Plan lays the fabric out: spines, then leaves, then hosts, each with its
+// addresses, sessions, links and (for hosts) exec lines. Every generated
+// file is rendered from this one inventory.
+func Plan(in *Intent) (*inventory.Inventory, error) {
+	inv := &inventory.Inventory{Lab: in.Name}
+	spines := make([]*inventory.Device, in.Spines)
+	leaves := make([]*inventory.Device, in.Leaves)
+	for s := range spines {
+		d, err := in.spine(s + 1)
+		if err != nil {
+			return nil, err
+		}
+		spines[s] = d
+	}
+	for l := range leaves {
+		d, err := in.leaf(l + 1)
+		if err != nil {
+			return nil, err
+		}
+		leaves[l] = d
+	}
+
+	// underlay: leaf l to spine s on spine port l and leaf port s, a /31
+	// from the spine's /24
+	for l, leaf := range leaves {
+		for s, spine := range spines {
+			spineIP, err := netmath.CIDRHost(in.Underlay, (s+1)*256+2*l)
+			if err != nil {
+				return nil, fmt.Errorf("underlay %s: %w", in.Underlay, err)
+			}
+			leafIP, _ := netmath.Offset(spineIP, 1)
+			connect(spine, l+1, leaf, s+1)
+			spine.Interfaces = append(spine.Interfaces, devices.Interface{Name: ethernet(l + 1), Address: spineIP + "/31"})
+			leaf.Interfaces = append(leaf.Interfaces, devices.Interface{Name: ethernet(s + 1), Address: leafIP + "/31"})
+			spine.BGP.Underlay = append(spine.BGP.Underlay, devices.Neighbor{Address: leafIP, RemoteAS: leaf.BGP.ASN})
+			leaf.BGP.Underlay = append(leaf.BGP.Underlay, devices.Neighbor{Address: spineIP, RemoteAS: spine.BGP.ASN})
+			spine.BGP.Overlay.Neighbors = append(spine.BGP.Overlay.Neighbors, devices.Neighbor{Address: leaf.BGP.RouterID, RemoteAS: leaf.BGP.ASN})
+		}
+	}
+	// leaves peer with the spines in spine order
+	for _, leaf := range leaves {
+		for _, spine := range spines {
+			leaf.BGP.Overlay.Neighbors = append(leaf.BGP.Overlay.Neighbors, devices.Neighbor{Address: spine.BGP.RouterID, RemoteAS: spine.BGP.ASN})
+		}
+	}
+
+	var hosts []*inventory.Device
+	for l, leaf := range leaves {
+		for h := 1; h <= in.Hosts.PerLeaf; h++ {
+			host, err := in.host(l*in.Hosts.PerLeaf + h)
+			if err != nil {
+				return nil, err
+			}
+			port := in.Spines + h
+			connect(leaf, port, host, 1)
+			leaf.Interfaces = append(leaf.Interfaces, devices.Interface{Name: ethernet(port), VLAN: in.Hosts.VLAN, PortFast: true})
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, group := range [][]*inventory.Device{spines, leaves, hosts} {
+		for _, d := range group {
+			inv.Devices = append(inv.Devices, *d)
+		}
+	}
+	return inv, nil
+}
+
+func (in *Intent) spine(n int) (*inventory.Device, error) {
+	rid, err := netmath.CIDRHost(in.Loopbacks.RouterID, in.Loopbacks.SpineStart+n-1)
+	if err != nil {
+		return nil, fmt.Errorf("spine%d router ID: %w", n, err)
+	}
+	d := in.eos("spine"+strconv.Itoa(n), inventory.RoleSpine)
+	d.Loopbacks = []devices.Loopback{{ID: 0, Address: rid + "/32"}}
+	d.BGP = &devices.BGP{
+		ASN:            in.BGP.ASN(bgpplan.Node{Role: inventory.RoleSpine, Index: n}),
+		RouterID:       rid,
+		Overlay:        in.overlay(spinePeerGroup),
+		RouteReflector: in.BGP.IBGP(),
+	}
+	d.Multicast = true
+	return d, nil
+}
+
+func (in *Intent) leaf(n int) (*inventory.Device, error) {
+	rid, err := netmath.CIDRHost(in.Loopbacks.RouterID, in.Loopbacks.LeafStart+n-1)
+	if err != nil {
+		return nil, fmt.Errorf("leaf%d router ID: %w", n, err)
+	}
+	vtep, err := netmath.CIDRHost(in.Loopbacks.VTEP, in.Loopbacks.LeafStart+n-1)
+	if err != nil {
+		return nil, fmt.Errorf("leaf%d VTEP: %w", n, err)
+	}
+	d := in.eos("leaf"+strconv.Itoa(n), inventory.RoleLeaf)
+	d.Loopbacks = []devices.Loopback{{ID: 0, Address: rid + "/32"}, {ID: 1, Address: vtep + "/32"}}
+	d.BGP = &devices.BGP{
+		ASN:       in.BGP.ASN(bgpplan.Node{Role: inventory.RoleLeaf, Index: n}),
+		RouterID:  rid,
+		Overlay:   in.overlay(leafPeerGroup),
+		AllowASIn: in.BGP.AllowASIn(),
+	}
+	for _, v := range in.VLANs {
+		rd, err := in.BGP.RD(rid, d.BGP.ASN, v.ID, v.VNI)
+		if err != nil {
+			return nil, fmt.Errorf("leaf%d vlan %d: %w", n, v.ID, err)
+		}
+		rt, err := in.BGP.RouteTarget(v.ID, v.VNI)
+		if err != nil {
+			return nil, fmt.Errorf("vlan %d: %w", v.ID, err)
+		}
+		d.VLANs = append(d.VLANs, devices.VLAN{ID: v.ID, VNI: v.VNI, RD: rd, RouteTarget: rt})
+	}
+	d.VXLAN = &devices.VXLAN{SourceInterface: "Loopback1"}
+	return d, nil
+}
+
+// overlay is the EVPN peer group; over iBGP the sessions are single hop.
+func (in *Intent) overlay(name string) *devices.PeerGroup {
+	g := &devices.PeerGroup{Name: name, UpdateSource: overlaySource, EBGPMultihop: overlayHops}
+	if in.BGP.IBGP() {
+		g.EBGPMultihop = 0
+	}
+	return g
+}
+
+// eos is the part every cEOS node shares.
+func (in *Intent) eos(name, role string) *inventory.Device {
+	d := &inventory.Device{Name: name, Kind: "ceos", Image: in.Image, StartupConfig: configDir + "/" + name + ".cfg"}
+	d.Hostname = name
+	d.Role = role
+	d.Users = in.Users
+	d.EAPI = true
+	return d
+}
+
+func (in *Intent) host(n int) (*inventory.Device, error) {
+	name := in.Hosts.Prefix + strconv.Itoa(n)
+	v, _ := in.vlan(in.Hosts.VLAN)
+	addr, err := netmath.CIDRHost(v.Subnet, in.Hosts.Start+n-1)
+	if err != nil {
+		return nil, fmt.Errorf("%s address: %w", name, err)
+	}
+	_, bits, _ := strings.Cut(v.Subnet, "/")
+	addr += "/" + bits
+	d := &inventory.Device{Name: name, Kind: "linux", Image: in.Hosts.Image}
+	d.Hostname = name
+	d.Role = inventory.RoleHost
+	d.Interfaces = []devices.Interface{{Name: "eth1", Address: addr, VLAN: in.Hosts.VLAN}}
+	d.Exec = []string{
+		"ip link set eth1 up",
+		"ip addr add " + addr + " dev eth1",
+	}
+	return d, nil
+}
+
+// connect records the cable between port pa of a and port pb of b on both.
+func connect(a *inventory.Device, pa int, b *inventory.Device, pb int) {
+	ia, ib := "eth"+strconv.Itoa(pa), "eth"+strconv.Itoa(pb)
+	a.Links = append(a.Links, inventory.Link{Interface: ia, Peer: b.Name, PeerInterface: ib})
+	b.Links = append(b.Links, inventory.Link{Interface: ib, Peer: a.Name, PeerInterface: ia})
+}
+
+func ethernet(port int) string { return "Ethernet" + strconv.Itoa(port) }
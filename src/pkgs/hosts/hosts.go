@@ -0,0 +1,156 @@
+// Package hosts models the linux hosts of the lab, the GPU servers hanging
+// off the leaves: their interfaces with address, VLAN and MTU, and the leaf
+// port each one plugs into. Apply sets a host's networking up with docker
+// exec, so host addressing comes from the inventory instead of shell
+// snippets.
+//
+//	for _, h := range hosts.FromInventory(inv) {
+//		ran, err := hosts.Apply(ctx, h, exec)
+//	}
+package hosts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Interface is a network interface of a host.
+type Interface struct {
+	Name    string `json:"name"`              // "eth1", or "eth1.20" for a tagged VLAN
+	Parent  string `json:"parent,omitempty"`  // the link a tagged interface rides on
+	Address string `json:"address,omitempty"` // "10.10.10.101/24"
+	VLAN    int    `json:"vlan,omitempty"`
+	Tagged  bool   `json:"tagged,omitempty"` // VLAN is carried as an 802.1Q tag
+	MTU     int    `json:"mtu,omitempty"`    // 0 leaves the container's default
+
+	// The leaf port the interface (or its parent) is cabled to.
+	Leaf     string `json:"leaf,omitempty"`
+	LeafPort string `json:"leafPort,omitempty"` // EOS name, "Ethernet3"
+}
+
+// Host is a linux node of the lab.
+type Host struct {
+	Name       string      `json:"name"`
+	Container  string      `json:"container"`
+	Interfaces []Interface `json:"interfaces"`
+}
+
+// FromInventory returns the hosts of inv in inventory order. A tagged
+// interface is one named PARENT.VLAN; the leaf port comes from the link of
+// the interface or its parent.
+func FromInventory(inv *inventory.Inventory) []Host {
+	var out []Host
+	for _, d := range inv.Devices {
+		if d.Role != inventory.RoleHost {
+			continue
+		}
+		h := Host{Name: d.Name, Container: clab.ContainerName(inv.Lab, d.Name)}
+		if d.Container != nil {
+			h.Container = d.Container.Name
+		}
+		for _, di := range d.Interfaces {
+			i := Interface{Name: di.Name, Address: di.Address, VLAN: di.VLAN, MTU: di.MTU}
+			cabled := i.Name
+			if parent, tag, ok := strings.Cut(i.Name, "."); ok {
+				i.Parent, i.Tagged, cabled = parent, true, parent
+				if i.VLAN == 0 {
+					i.VLAN, _ = strconv.Atoi(tag)
+				}
+			}
+			for _, l := range d.Links {
+				if l.Interface == cabled {
+					i.Leaf, i.LeafPort = l.Peer, netmath.EOSInterface(l.PeerInterface)
+				}
+			}
+			h.Interfaces = append(h.Interfaces, i)
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// Commands returns the ip commands that give h its networking: VLAN
+// interfaces are created, MTUs set and links brought up (untagged ones
+// first), and the IPv4 addresses of each addressed interface replaced by
+// the inventory's.
+func (h Host) Commands() [][]string {
+	var out [][]string
+	for _, i := range h.Interfaces {
+		if i.Tagged {
+			out = append(out, []string{"ip", "link", "add", "link", i.Parent, "name", i.Name, "type", "vlan", "id", strconv.Itoa(i.VLAN)})
+		}
+	}
+	intfs := slices.Clone(h.Interfaces)
+	slices.SortStableFunc(intfs, func(a, b Interface) int {
+		if a.Tagged == b.Tagged {
+			return 0
+		}
+		if b.Tagged {
+			return -1
+		}
+		return 1
+	})
+	for _, i := range intfs {
+		if i.MTU > 0 {
+			out = append(out, []string{"ip", "link", "set", i.Name, "mtu", strconv.Itoa(i.MTU)})
+		}
+		out = append(out, []string{"ip", "link", "set", i.Name, "up"})
+	}
+	for _, i := range intfs {
+		if i.Address != "" {
+			out = append(out,
+				[]string{"ip", "-4", "addr", "flush", "dev", i.Name},
+				[]string{"ip", "addr", "add", i.Address, "dev", i.Name})
+		}
+	}
+	return out
+}
+
+// Exec runs argv inside a container and returns its output, the way
+// clab.DockerExec does.
+type Exec func(ctx context.Context, container string, argv ...string) ([]byte, error)
+
+// Apply runs the commands of h in its container and returns the ones it
+// ran, as command lines. VLAN interfaces that already exist are kept, so
+// applying twice gives the same result. It stops at the first failure.
+func Apply(ctx context.Context, h Host, exec Exec) ([]string, error) {
+	links, err := exec(ctx, h.Container, "ip", "-o", "link", "show")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", h.Name, err)
+	}
+	existing := linkNames(string(links))
+	var ran []string
+	for _, argv := range h.Commands() {
+		if argv[1] == "link" && argv[2] == "add" && slices.Contains(existing, argv[6]) {
+			continue
+		}
+		line := strings.Join(argv, " ")
+		if _, err := exec(ctx, h.Container, argv...); err != nil {
+			return ran, fmt.Errorf("%s: %s: %w", h.Name, line, err)
+		}
+		ran = append(ran, line)
+	}
+	return ran, nil
+}
+
+// linkNames reads the interface names out of "ip -o link show", where a
+// VLAN interface shows up as "2: eth1.20@eth1: <...>".
+func linkNames(out string) []string {
+	var names []string
+	for _, l := range strings.Split(out, "\n") {
+		w := strings.Fields(l)
+		if len(w) < 2 || !strings.HasSuffix(w[0], ":") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimSuffix(w[1], ":"), "@")
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,141 @@
+package hosts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+)
+
+const topo = `name: t
+topology:
+  nodes:
+    leaf1:
+      kind: ceos
+      startup-config: leaf1.cfg
+    gpu1:
+      kind: linux
+      exec:
+        - ip link set eth1 mtu 9000 up
+        - ip addr add 10.10.10.101/24 dev eth1
+        - ip link add link eth2 name eth2.20 type vlan id 20
+        - ip link set eth2.20 up
+        - ip addr add 10.20.0.101/24 dev eth2.20
+  links:
+    - endpoints: ["leaf1:eth3", "gpu1:eth1"]
+    - endpoints: ["leaf1:eth4", "gpu1:eth2"]
+`
+
+const leafCfg = `hostname leaf1
+vlan 10
+interface Ethernet3
+   switchport access vlan 10
+interface Ethernet4
+   switchport mode trunk
+`
+
+func derive(t *testing.T) *inventory.Inventory {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "leaf1.cfg"), []byte(leafCfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tp, err := clab.ParseTopology([]byte(topo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := inventory.Derive(tp, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+func TestFromInventory(t *testing.T) {
+	hs := FromInventory(derive(t))
+	if len(hs) != 1 {
+		t.Fatalf("hosts = %+v", hs)
+	}
+	want := Host{Name: "gpu1", Container: "clab-t-gpu1", Interfaces: []Interface{
+		{Name: "eth1", Address: "10.10.10.101/24", VLAN: 10, MTU: 9000, Leaf: "leaf1", LeafPort: "Ethernet3"},
+		{Name: "eth2.20", Parent: "eth2", Address: "10.20.0.101/24", VLAN: 20, Tagged: true, Leaf: "leaf1", LeafPort: "Ethernet4"},
+	}}
+	if !reflect.DeepEqual(hs[0], want) {
+		t.Errorf("gpu1 =\n%+v\nwant\n%+v", hs[0], want)
+	}
+}
+
+// The checked-in lab: gpu1-4 each on port 3 of their leaf, in VLAN 10.
+func TestLabHosts(t *testing.T) {
+	tp, err := clab.LoadTopology(filepath.Join("..", "..", "..", "lab.clab.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := inventory.Derive(tp, filepath.Join("..", "..", ".."), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := FromInventory(inv)
+	if len(hs) != 4 {
+		t.Fatalf("%d hosts", len(hs))
+	}
+	for n, h := range hs {
+		i := h.Interfaces[0]
+		leaf := "leaf" + string(rune('1'+n))
+		if h.Container != "clab-evpn-rdma-fabric-"+h.Name || i.VLAN != 10 || i.Leaf != leaf || i.LeafPort != "Ethernet3" {
+			t.Errorf("%s = %+v", h.Name, h)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	h := FromInventory(derive(t))[0]
+	var ran []string
+	exec := func(_ context.Context, container string, argv ...string) ([]byte, error) {
+		if container != "clab-t-gpu1" {
+			t.Errorf("exec in %s", container)
+		}
+		line := strings.Join(argv, " ")
+		if line == "ip -o link show" {
+			return []byte("1: lo: <LOOPBACK,UP> mtu 65536\n3: eth2.20@eth2: <BROADCAST> mtu 1500\n"), nil
+		}
+		ran = append(ran, line)
+		return nil, nil
+	}
+	got, err := Apply(context.Background(), h, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ip link set eth1 mtu 9000",
+		"ip link set eth1 up",
+		"ip link set eth2.20 up",
+		"ip -4 addr flush dev eth1",
+		"ip addr add 10.10.10.101/24 dev eth1",
+		"ip -4 addr flush dev eth2.20",
+		"ip addr add 10.20.0.101/24 dev eth2.20",
+	}
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran\n%s\nwant\n%s", strings.Join(ran, "\n"), strings.Join(want, "\n"))
+	}
+	if cmds := h.Commands(); strings.Join(cmds[0], " ") != "ip link add link eth2 name eth2.20 type vlan id 20" {
+		t.Errorf("first command %q", cmds[0])
+	}
+
+	failing := func(_ context.Context, _ string, argv ...string) ([]byte, error) {
+		if argv[1] == "addr" && argv[2] == "add" {
+			return nil, errors.New("RTNETLINK answers: File exists")
+		}
+		return nil, nil
+	}
+	got, err = Apply(context.Background(), h, failing)
+	if err == nil || !strings.Contains(err.Error(), "gpu1: ip addr add 10.10.10.101/24 dev eth1") || len(got) != 5 {
+		t.Errorf("ran %q, err %v", got, err)
+	}
+}
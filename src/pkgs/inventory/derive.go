@@ -0,0 +1,173 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Derive builds an inventory from what the lab already describes: the
+// containerlab topology (nodes, kinds, images, links, exec lines), the
+// startup configs it points at, resolved against dir, and the containers
+// containerlab inspect reports for a running lab (may be nil).
+//
+// Containers are matched to nodes by name, so "clab-evpn-rdma-fabric-leaf1"
+// becomes the container of leaf1; containers of other labs are ignored.
+// Roles come from a "role" label on the node, else from the name (spine*,
+// leaf*) and kind (non-cEOS nodes are hosts).
+func Derive(topo *clab.Topology, dir string, containers []clab.Container) (*Inventory, error) {
+	inv := &Inventory{Lab: topo.Name}
+	for _, n := range topo.Topology.Nodes {
+		d := Device{
+			Name:          n.Name,
+			Kind:          n.Kind,
+			Image:         n.Image,
+			StartupConfig: n.StartupConfig,
+			Exec:          n.Exec,
+		}
+		if n.StartupConfig != "" && strings.EqualFold(n.Kind, "ceos") {
+			b, err := os.ReadFile(filepath.Join(dir, n.StartupConfig))
+			if err != nil {
+				return nil, fmt.Errorf("node %s: %w", n.Name, err)
+			}
+			root, err := eosconfig.ParseString(string(b))
+			if err != nil {
+				return nil, fmt.Errorf("node %s: parse %s: %w", n.Name, n.StartupConfig, err)
+			}
+			d.Device = DeviceFromConfig(root)
+		}
+		d.Interfaces = append(d.Interfaces, execInterfaces(n.Exec)...)
+		d.Role = nodeRole(n)
+		if n.Group != "" {
+			d.Tags = append(d.Tags, n.Group)
+		}
+		for _, l := range topo.Links(n.Name) {
+			d.Links = append(d.Links, Link{Interface: l[0].Interface, Peer: l[1].Node, PeerInterface: l[1].Interface})
+		}
+		for _, c := range containers {
+			if (c.LabName == "" || c.LabName == topo.Name) && (c.Node() == n.Name || c.Name == n.Name) {
+				d.Container = &Container{Name: c.Name, State: c.State, IPv4: c.IPv4, IPv6: c.IPv6}
+				d.MGMTAddress = c.IPv4
+			}
+		}
+		inv.Devices = append(inv.Devices, d)
+	}
+	inv.setDefaults()
+	hostVLANs(inv)
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// nodeRole decides the role of a topology node.
+func nodeRole(n clab.Node) string {
+	switch {
+	case n.Labels["role"] != "":
+		return n.Labels["role"]
+	case !strings.EqualFold(n.Kind, "ceos"):
+		return RoleHost
+	case strings.HasPrefix(n.Name, RoleSpine):
+		return RoleSpine
+	}
+	return RoleLeaf
+}
+
+// execInterfaces picks up the interfaces a linux node's exec lines set up:
+//
+//	ip link add link eth1 name eth1.20 type vlan id 20
+//	ip link set eth1 mtu 9000 up
+//	ip addr add 10.10.10.101/24 dev eth1
+//
+// in the order they are first named. Other lines are ignored.
+func execInterfaces(lines []string) []devices.Interface {
+	var out []devices.Interface
+	get := func(name string) *devices.Interface {
+		for i := range out {
+			if out[i].Name == name {
+				return &out[i]
+			}
+		}
+		out = append(out, devices.Interface{Name: name})
+		return &out[len(out)-1]
+	}
+	for _, l := range lines {
+		w := strings.Fields(l)
+		if len(w) < 4 || w[0] != "ip" {
+			continue
+		}
+		// the device follows "dev", which ip also lets you leave out
+		dev := value(w, "dev")
+		switch {
+		case strings.HasPrefix("address", w[1]) && w[2] == "add":
+			// ip addr add ADDR [brd +] dev NAME
+			if dev == "" && len(w) == 5 {
+				dev = w[4]
+			}
+			if dev != "" {
+				get(dev).Address = w[3]
+			}
+		case strings.HasPrefix("link", w[1]) && w[2] == "add":
+			// ip link add link PARENT name NAME type vlan id N
+			name, typ, id := value(w, "name"), value(w, "type"), value(w, "id")
+			if name != "" && typ == "vlan" {
+				get(name).VLAN, _ = strconv.Atoi(id)
+			}
+		case strings.HasPrefix("link", w[1]) && w[2] == "set":
+			if dev == "" {
+				dev = w[3]
+			}
+			i := get(dev)
+			if mtu := value(w, "mtu"); mtu != "" {
+				i.MTU, _ = strconv.Atoi(mtu)
+			}
+		}
+	}
+	return out
+}
+
+// value returns the word after key in w, "" if key isn't there.
+func value(w []string, key string) string {
+	for i := 0; i+1 < len(w); i++ {
+		if w[i] == key {
+			return w[i+1]
+		}
+	}
+	return ""
+}
+
+// hostVLANs puts untagged host interfaces in the access VLAN of the leaf
+// port their link ends on.
+func hostVLANs(inv *Inventory) {
+	for i := range inv.Devices {
+		d := &inv.Devices[i]
+		if d.Role != RoleHost {
+			continue
+		}
+		for j := range d.Interfaces {
+			intf := &d.Interfaces[j]
+			if intf.VLAN != 0 {
+				continue
+			}
+			for _, l := range d.Links {
+				if l.Interface != intf.Name {
+					continue
+				}
+				peer, _ := inv.Get(l.Peer)
+				port := netmath.EOSInterface(l.PeerInterface)
+				for _, pi := range peer.Interfaces {
+					if pi.Name == port {
+						intf.VLAN = pi.VLAN
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/clab"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+const labDir = "../../.."
+
+const inspectOut = `{"evpn-rdma-fabric": [
+  {"name": "clab-evpn-rdma-fabric-leaf1", "kind": "ceos", "state": "running", "ipv4_address": "172.20.20.5/24"},
+  {"name": "clab-evpn-rdma-fabric-gpu1", "kind": "linux", "state": "running", "ipv4_address": "172.20.20.9/24"}
+]}`
+
+func TestDeriveLab(t *testing.T) {
+	topo, err := clab.LoadTopology(filepath.Join(labDir, "lab.clab.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers, err := clab.ParseInspect([]byte(inspectOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := Derive(topo, labDir, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Lab != "evpn-rdma-fabric" || len(inv.Devices) != 10 {
+		t.Fatalf("lab %q with %d devices", inv.Lab, len(inv.Devices))
+	}
+	if got, want := names(inv.Devices), "spine1,spine2,leaf1,leaf2,leaf3,leaf4,gpu1,gpu2,gpu3,gpu4"; got != want {
+		t.Errorf("order = %s", got)
+	}
+
+	leaf1, _ := inv.Get("leaf1")
+	if leaf1.Role != RoleLeaf || leaf1.ASN() != 65101 || leaf1.Image != "ceosimage:4.34.2.1f" {
+		t.Errorf("leaf1 = role %q asn %d image %q", leaf1.Role, leaf1.ASN(), leaf1.Image)
+	}
+	wantLinks := []Link{
+		{"eth1", "spine1", "eth1"},
+		{"eth2", "spine2", "eth1"},
+		{"eth3", "gpu1", "eth1"},
+	}
+	if !reflect.DeepEqual(leaf1.Links, wantLinks) {
+		t.Errorf("leaf1 links = %+v", leaf1.Links)
+	}
+	if leaf1.Container == nil || leaf1.Container.State != "running" || leaf1.MGMTAddress != "172.20.20.5/24" {
+		t.Errorf("leaf1 container = %+v, mgmt %q", leaf1.Container, leaf1.MGMTAddress)
+	}
+	if leaf2, _ := inv.Get("leaf2"); leaf2.Container != nil {
+		t.Errorf("leaf2 has container %+v", leaf2.Container)
+	}
+
+	spine2, _ := inv.Get("spine2")
+	if spine2.Role != RoleSpine || len(spine2.Links) != 4 {
+		t.Errorf("spine2 = role %q, %d links", spine2.Role, len(spine2.Links))
+	}
+
+	gpu1, _ := inv.Get("gpu1")
+	if gpu1.Role != RoleHost || gpu1.Kind != "linux" || gpu1.BGP != nil {
+		t.Errorf("gpu1 = role %q kind %q bgp %+v", gpu1.Role, gpu1.Kind, gpu1.BGP)
+	}
+	if want := []devices.Interface{{Name: "eth1", Address: "10.10.10.101/24", VLAN: 10}}; !reflect.DeepEqual(gpu1.Interfaces, want) {
+		t.Errorf("gpu1 interfaces = %+v", gpu1.Interfaces)
+	}
+	if gpu1.MGMTAddress != "172.20.20.9/24" {
+		t.Errorf("gpu1 mgmt = %q", gpu1.MGMTAddress)
+	}
+}
+
+func TestDeriveRoleLabelAndOtherLabs(t *testing.T) {
+	topo, err := clab.ParseTopology([]byte(`
+name: mini
+topology:
+  kinds:
+    linux: {image: alpine:3.19}
+  nodes:
+    border1:
+      kind: ceos
+      labels: {role: spine}
+    h1:
+      kind: linux
+      group: rack9
+  links:
+    - endpoints: ["border1:eth1", "h1:eth1"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := []clab.Container{{LabName: "other", Name: "clab-other-h1", State: "running"}}
+	inv, err := Derive(topo, t.TempDir(), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := inv.Get("border1")
+	h, _ := inv.Get("h1")
+	if b.Role != RoleSpine || h.Role != RoleHost || h.Image != "alpine:3.19" || !h.HasTag("rack9") {
+		t.Errorf("border1 %+v, h1 %+v", b, h)
+	}
+	if h.Container != nil {
+		t.Errorf("h1 matched a container of another lab: %+v", h.Container)
+	}
+}
+
+// ip takes the device with or without "dev", anywhere on the line.
+func TestExecInterfaces(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		lines []string
+		want  []devices.Interface
+	}{
+		{"positional", []string{"ip link set eth1 mtu 9000 up", "ip addr add 10.10.10.101/24 dev eth1"},
+			[]devices.Interface{{Name: "eth1", Address: "10.10.10.101/24", MTU: 9000}}},
+		{"dev keyword", []string{"ip link set dev eth1 mtu 9000 up", "ip addr add 10.10.10.101/24 brd + dev eth1"},
+			[]devices.Interface{{Name: "eth1", Address: "10.10.10.101/24", MTU: 9000}}},
+		{"no dev on addr", []string{"ip address add 10.10.10.101/24 eth1"},
+			[]devices.Interface{{Name: "eth1", Address: "10.10.10.101/24"}}},
+		{"vlan", []string{"ip link add link eth1 name eth1.20 type vlan id 20", "ip link set dev eth1.20 up", "ip a add 10.20.0.1/24 dev eth1.20"},
+			[]devices.Interface{{Name: "eth1.20", Address: "10.20.0.1/24", VLAN: 20}}},
+	} {
+		if got := execInterfaces(tc.lines); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDeriveMissingStartupConfig(t *testing.T) {
+	topo, err := clab.ParseTopology([]byte(`
+name: mini
+topology:
+  nodes:
+    leaf9: {kind: ceos, startup-config: configs/leaf9.cfg}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Derive(topo, t.TempDir(), nil); err == nil {
+		t.Fatal("want an error for a missing startup config")
+	}
+}
+
+func TestDeviceFromConfigMatchesInventory(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join(labDir, "configs", "leaf1.cfg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := eosconfig.ParseString(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := DeviceFromConfig(root)
+
+	want, err := LoadFile(filepath.Join(labDir, "inventory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf1, ok := want.Get("leaf1")
+	if !ok {
+		t.Fatal("no leaf1 in inventory.json")
+	}
+	if got.Hostname != "leaf1" {
+		t.Errorf("hostname = %q", got.Hostname)
+	}
+	if !reflect.DeepEqual(got.Loopbacks, leaf1.Loopbacks) {
+		t.Errorf("loopbacks = %+v, want %+v", got.Loopbacks, leaf1.Loopbacks)
+	}
+	if got.BGP == nil || got.BGP.ASN != leaf1.BGP.ASN || got.BGP.RouterID != leaf1.BGP.RouterID {
+		t.Fatalf("bgp = %+v", got.BGP)
+	}
+	if !reflect.DeepEqual(got.BGP.Underlay, leaf1.BGP.Underlay) {
+		t.Errorf("underlay = %+v, want %+v", got.BGP.Underlay, leaf1.BGP.Underlay)
+	}
+	if got.BGP.Overlay == nil || len(got.BGP.Overlay.Neighbors) != 2 {
+		t.Errorf("overlay = %+v", got.BGP.Overlay)
+	}
+	var vlan10 *devices.VLAN
+	for i := range got.VLANs {
+		if got.VLANs[i].ID == 10 {
+			vlan10 = &got.VLANs[i]
+		}
+	}
+	if vlan10 == nil || vlan10.VNI != 1010 || vlan10.RD != "10.0.0.11:10" {
+		t.Errorf("vlan 10 = %+v", vlan10)
+	}
+	if !got.EAPI {
+		t.Error("eapi not enabled")
+	}
+}
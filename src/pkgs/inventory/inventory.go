@@ -0,0 +1,241 @@
+// Package inventory is the list of lab devices the server works on: what
+// each one is (role, kind, ASN, loopbacks), how to group it (tags) and how
+// to log in to it (a reference to a named credential).
+//
+// Inventories are YAML or JSON documents:
+//
+//	lab: evpn-rdma-fabric
+//	credentials:
+//	  default: {username: admin, password: admin}
+//	devices:
+//	  - name: leaf1
+//	    role: leaf
+//	    tags: [rack1]
+//	    loopbacks: [{id: 0, address: 10.0.0.11/32}]
+//
+// Device entries accept every field of devices.Device, so the same file
+// feeds the renderers.
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+)
+
+// Roles a device can have.
+const (
+	RoleSpine = "spine"
+	RoleLeaf  = "leaf"
+	RoleHost  = "host"
+)
+
+// Inventory is a loaded inventory document.
+type Inventory struct {
+	Lab         string                `json:"lab,omitempty"` // containerlab lab name
+	Credentials map[string]Credential `json:"credentials,omitempty"`
+	Devices     []Device              `json:"devices"`
+}
+
+// Device is one inventory entry.
+type Device struct {
+	devices.Device
+	Name        string   `json:"name"`                  // containerlab node name, "leaf1"
+	Kind        string   `json:"kind,omitempty"`        // containerlab kind: ceos or linux
+	Tags        []string `json:"tags,omitempty"`        // free-form groups, e.g. "rack1"
+	Credentials string   `json:"credentials,omitempty"` // key into Inventory.Credentials
+
+	// Filled in by Derive from the containerlab topology and inspect.
+	Image         string     `json:"image,omitempty"`
+	StartupConfig string     `json:"startupConfig,omitempty"` // relative to the topology file
+	Exec          []string   `json:"exec,omitempty"`
+	Links         []Link     `json:"links,omitempty"`
+	Container     *Container `json:"container,omitempty"` // nil when the lab isn't running
+}
+
+// Link is a cable from one of the device's interfaces to a peer.
+type Link struct {
+	Interface     string `json:"interface"` // containerlab name, "eth1"
+	Peer          string `json:"peer"`
+	PeerInterface string `json:"peerInterface"`
+}
+
+// Container is the running container of a device.
+type Container struct {
+	Name  string `json:"name"` // "clab-evpn-rdma-fabric-leaf1"
+	State string `json:"state"`
+	IPv4  string `json:"ipv4,omitempty"` // "172.20.20.7/24"
+	IPv6  string `json:"ipv6,omitempty"`
+}
+
+// Credential is a login for a device.
+type Credential struct {
+	Username    string `json:"username"`
+	Password    string `json:"password,omitempty"`
+	PasswordEnv string `json:"passwordEnv,omitempty"` // take the password from this environment variable
+}
+
+// ASN is the device's BGP AS number, 0 when it runs no BGP.
+func (d Device) ASN() int {
+	if d.BGP == nil {
+		return 0
+	}
+	return d.BGP.ASN
+}
+
+// HasTag reports whether d carries tag.
+func (d Device) HasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads an inventory in YAML or JSON, fills in defaults and validates
+// it.
+func Load(r io.Reader) (*Inventory, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// YAML is decoded generically and re-encoded, so both formats use the
+	// json tags and the same field names
+	if t := bytes.TrimSpace(b); len(t) > 0 && t[0] != '{' {
+		var doc any
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("decode inventory: %w", err)
+		}
+		if b, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("decode inventory: %w", err)
+		}
+	}
+	var inv Inventory
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&inv); err != nil {
+		return nil, fmt.Errorf("decode inventory: %w", err)
+	}
+	inv.setDefaults()
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// LoadFile reads the inventory at path.
+func LoadFile(path string) (*Inventory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	inv, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return inv, nil
+}
+
+// setDefaults fills in what can be derived: name and hostname from each
+// other, kind from role, and the "default" credential.
+func (inv *Inventory) setDefaults() {
+	_, haveDefault := inv.Credentials["default"]
+	for i := range inv.Devices {
+		d := &inv.Devices[i]
+		if d.Name == "" {
+			d.Name = d.Hostname
+		}
+		if d.Hostname == "" {
+			d.Hostname = d.Name
+		}
+		if d.Kind == "" {
+			d.Kind = "ceos"
+			if d.Role == RoleHost {
+				d.Kind = "linux"
+			}
+		}
+		if d.Credentials == "" && haveDefault && d.Kind == "ceos" {
+			d.Credentials = "default"
+		}
+	}
+}
+
+// Validate checks that names are set and unique, roles are known and
+// credential references resolve.
+func (inv *Inventory) Validate() error {
+	seen := map[string]bool{}
+	var errs []string
+	for i, d := range inv.Devices {
+		if d.Name == "" {
+			errs = append(errs, fmt.Sprintf("device %d has no name", i))
+			continue
+		}
+		if seen[d.Name] {
+			errs = append(errs, fmt.Sprintf("device %s is listed twice", d.Name))
+		}
+		seen[d.Name] = true
+		switch d.Role {
+		case RoleSpine, RoleLeaf, RoleHost:
+		default:
+			errs = append(errs, fmt.Sprintf("device %s has unknown role %q", d.Name, d.Role))
+		}
+		if _, ok := inv.Credentials[d.Credentials]; d.Credentials != "" && !ok {
+			errs = append(errs, fmt.Sprintf("device %s refers to unknown credentials %q", d.Name, d.Credentials))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid inventory: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Get returns the device with the given name.
+func (inv *Inventory) Get(name string) (Device, bool) {
+	for _, d := range inv.Devices {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+// Select returns the devices matching selector, in inventory order. See
+// ParseSelector for the syntax.
+func (inv *Inventory) Select(selector string) ([]Device, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	var out []Device
+	for _, d := range inv.Devices {
+		if sel.Match(d) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// Credential resolves the login of d. It returns the zero Credential for a
+// device without one.
+func (inv *Inventory) Credential(d Device) (Credential, error) {
+	if d.Credentials == "" {
+		return Credential{}, nil
+	}
+	c, ok := inv.Credentials[d.Credentials]
+	if !ok {
+		return Credential{}, fmt.Errorf("device %s refers to unknown credentials %q", d.Name, d.Credentials)
+	}
+	if c.PasswordEnv != "" {
+		c.Password = os.Getenv(c.PasswordEnv)
+	}
+	return c, nil
+}
@@ -0,0 +1,156 @@
+package inventory
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const yamlInv = `
+lab: evpn-rdma-fabric
+credentials:
+  default: {username: admin, password: admin}
+  ops: {username: ops, passwordEnv: LABER_TEST_PASS}
+devices:
+  - name: spine1
+    role: spine
+    tags: [core]
+    bgp: {asn: 65000, routerId: 10.0.0.1}
+  - name: leaf1
+    role: leaf
+    tags: [rack1]
+    loopbacks: [{id: 0, address: 10.0.0.11/32}]
+    bgp: {asn: 65101, routerId: 10.0.0.11}
+  - name: leaf3
+    role: leaf
+    tags: [rack2, border]
+    credentials: ops
+    bgp: {asn: 65103, routerId: 10.0.0.13}
+  - name: gpu1
+    role: host
+    tags: [rack1]
+`
+
+func load(t *testing.T, doc string) *Inventory {
+	t.Helper()
+	inv, err := Load(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+func names(ds []Device) string {
+	var out []string
+	for _, d := range ds {
+		out = append(out, d.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestLoadYAMLAndJSON(t *testing.T) {
+	y := load(t, yamlInv)
+	j := load(t, `{"lab": "evpn-rdma-fabric",
+	  "credentials": {"default": {"username": "admin", "password": "admin"}, "ops": {"username": "ops", "passwordEnv": "LABER_TEST_PASS"}},
+	  "devices": [
+	    {"name": "spine1", "role": "spine", "tags": ["core"], "bgp": {"asn": 65000, "routerId": "10.0.0.1"}},
+	    {"name": "leaf1", "role": "leaf", "tags": ["rack1"], "loopbacks": [{"id": 0, "address": "10.0.0.11/32"}], "bgp": {"asn": 65101, "routerId": "10.0.0.11"}},
+	    {"name": "leaf3", "role": "leaf", "tags": ["rack2", "border"], "credentials": "ops", "bgp": {"asn": 65103, "routerId": "10.0.0.13"}},
+	    {"name": "gpu1", "role": "host", "tags": ["rack1"]}]}`)
+	if !reflect.DeepEqual(y, j) {
+		t.Errorf("YAML and JSON inventories differ:\n%+v\n%+v", y, j)
+	}
+
+	leaf1, ok := y.Get("leaf1")
+	if !ok {
+		t.Fatal("leaf1 not found")
+	}
+	// defaults: hostname from name, kind from role, the default credential
+	if leaf1.Hostname != "leaf1" || leaf1.Kind != "ceos" || leaf1.Credentials != "default" {
+		t.Errorf("leaf1 = %+v", leaf1)
+	}
+	if leaf1.ASN() != 65101 || leaf1.Loopback(0) != "10.0.0.11/32" {
+		t.Errorf("leaf1 asn %d loopback0 %q", leaf1.ASN(), leaf1.Loopback(0))
+	}
+	if gpu, _ := y.Get("gpu1"); gpu.Kind != "linux" || gpu.Credentials != "" || gpu.ASN() != 0 {
+		t.Errorf("gpu1 = %+v", gpu)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	for doc, want := range map[string]string{
+		"devices:\n  - {name: a, role: leaf}\n  - {name: a, role: leaf}\n": "listed twice",
+		"devices:\n  - {name: a, role: border}\n":                          `unknown role "border"`,
+		"devices:\n  - {name: a, role: leaf, credentials: nope}\n":         `unknown credentials "nope"`,
+		"devices:\n  - {role: leaf}\n":                                     "has no name",
+		"devices:\n  - {name: a, role: leaf, colour: red}\n":               `unknown field "colour"`,
+	} {
+		_, err := Load(strings.NewReader(doc))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: err = %v, want %q", doc, err, want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	inv := load(t, yamlInv)
+	for sel, want := range map[string]string{
+		"":                         "spine1,leaf1,leaf3,gpu1",
+		"role=leaf":                "leaf1,leaf3",
+		"role=leaf,tag=rack1":      "leaf1",
+		"tag=rack1":                "leaf1,gpu1",
+		"tag!=rack1":               "spine1,leaf3",
+		"name=leaf*":               "leaf1,leaf3",
+		" kind = ceos , asn!=650*": "leaf1,leaf3",
+		"asn=65000":                "spine1",
+		"role!=host,tag=border":    "leaf3",
+	} {
+		got, err := inv.Select(sel)
+		if err != nil {
+			t.Errorf("%q: %v", sel, err)
+			continue
+		}
+		if names(got) != want {
+			t.Errorf("%q selected %s, want %s", sel, names(got), want)
+		}
+	}
+	for _, bad := range []string{"leaf1", "colour=red", "name=[", "role"} {
+		if _, err := inv.Select(bad); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+	if sel, _ := ParseSelector("role = leaf,tag!=rack2"); sel.String() != "role=leaf,tag!=rack2" {
+		t.Errorf("String() = %q", sel)
+	}
+}
+
+func TestCredential(t *testing.T) {
+	t.Setenv("LABER_TEST_PASS", "s3cret")
+	inv := load(t, yamlInv)
+	leaf3, _ := inv.Get("leaf3")
+	c, err := inv.Credential(leaf3)
+	if err != nil || c.Username != "ops" || c.Password != "s3cret" {
+		t.Errorf("leaf3 credential = %+v, %v", c, err)
+	}
+	gpu, _ := inv.Get("gpu1")
+	if c, err := inv.Credential(gpu); err != nil || c != (Credential{}) {
+		t.Errorf("gpu1 credential = %+v, %v", c, err)
+	}
+}
+
+// The lab's own inventory must load with its tags.
+func TestLabInventory(t *testing.T) {
+	inv, err := LoadFile(filepath.Join("..", "..", "..", "inventory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves, _ := inv.Select("role=leaf,tag=rack1")
+	if names(leaves) != "leaf1,leaf2" {
+		t.Errorf("rack1 leaves = %s", names(leaves))
+	}
+	hosts, _ := inv.Select("kind=linux")
+	if names(hosts) != "gpu1,gpu2,gpu3,gpu4" {
+		t.Errorf("linux hosts = %s", names(hosts))
+	}
+}
@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Selector picks devices by their attributes.
+type Selector []term
+
+type term struct {
+	key, value string
+	negate     bool
+}
+
+// selectorKeys are the attributes a selector can test.
+var selectorKeys = map[string]func(Device) []string{
+	"name": func(d Device) []string { return []string{d.Name} },
+	"role": func(d Device) []string { return []string{d.Role} },
+	"kind": func(d Device) []string { return []string{d.Kind} },
+	"tag":  func(d Device) []string { return d.Tags },
+	"asn":  func(d Device) []string { return []string{strconv.Itoa(d.ASN())} },
+}
+
+// ParseSelector parses a comma-separated list of key=value and key!=value
+// terms, all of which must hold: "role=leaf,tag=rack1". Keys are name,
+// role, kind, tag and asn; values may be globs ("leaf*"). A device matches
+// tag=x if any of its tags is x, and tag!=x if none is. The empty selector
+// matches every device.
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var t term
+		key, value, ok := strings.Cut(part, "!=")
+		if ok {
+			t.negate = true
+		} else if key, value, ok = strings.Cut(part, "="); !ok {
+			return nil, fmt.Errorf("selector %q: %q is not key=value", s, part)
+		}
+		t.key, t.value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if _, ok := selectorKeys[t.key]; !ok {
+			return nil, fmt.Errorf("selector %q: unknown key %q", s, t.key)
+		}
+		if _, err := path.Match(t.value, ""); err != nil {
+			return nil, fmt.Errorf("selector %q: bad pattern %q", s, t.value)
+		}
+		sel = append(sel, t)
+	}
+	return sel, nil
+}
+
+// Match reports whether d satisfies every term of s.
+func (s Selector) Match(d Device) bool {
+	for _, t := range s {
+		found := false
+		for _, v := range selectorKeys[t.key](d) {
+			if ok, _ := path.Match(t.value, v); ok {
+				found = true
+				break
+			}
+		}
+		if found == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, t := range s {
+		op := "="
+		if t.negate {
+			op = "!="
+		}
+		parts[i] = t.key + op + t.value
+	}
+	return strings.Join(parts, ",")
+}
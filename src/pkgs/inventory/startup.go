@@ -0,0 +1,291 @@
+package inventory
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+// DeviceFromConfig reads back what the renderers would need to produce an
+// EOS config: hostname, users, loopbacks, routed and access interfaces,
+// VLANs with their VNIs, RDs and RTs, tenant VRFs and anycast gateways, BGP
+// sessions and eAPI. Repeated stanzas are merged the way EOS applies them.
+// Role is left empty.
+func DeviceFromConfig(root *eosconfig.Node) devices.Device {
+	var d devices.Device
+	if h := root.Child("hostname"); h != nil && len(h.Words()) > 1 {
+		d.Hostname = h.Words()[1]
+	}
+	for _, u := range root.Select("username") {
+		w := u.Words()
+		user := devices.User{Name: w[1]}
+		for i := 2; i+1 < len(w); i++ {
+			switch w[i] {
+			case "privilege":
+				user.Privilege, _ = strconv.Atoi(w[i+1])
+			case "secret":
+				user.Secret = w[len(w)-1]
+			}
+		}
+		d.Users = append(d.Users, user)
+	}
+
+	vlans := map[int]*devices.VLAN{}
+	vlan := func(id int) *devices.VLAN {
+		if vlans[id] == nil {
+			vlans[id] = &devices.VLAN{ID: id}
+		}
+		return vlans[id]
+	}
+	for _, v := range root.Select("vlan") {
+		if id, err := strconv.Atoi(word(v, 1)); err == nil {
+			if n := v.Child("name"); n != nil {
+				vlan(id).Name = word(n, 1)
+			} else {
+				vlan(id)
+			}
+		}
+	}
+
+	var vrfOrder []string
+	vrfs := map[string]*devices.VRF{}
+	vrf := func(name string) *devices.VRF {
+		if vrfs[name] == nil {
+			vrfs[name] = &devices.VRF{Name: name}
+			vrfOrder = append(vrfOrder, name)
+		}
+		return vrfs[name]
+	}
+	for _, v := range root.Select("vrf instance") {
+		vrf(word(v, 2))
+	}
+	if m := root.Child("ip virtual-router mac-address"); m != nil {
+		d.VirtualRouterMAC = word(m, 3)
+	}
+
+	var intfOrder []string
+	intfs := map[string]*devices.Interface{}
+	for _, sec := range root.Select("interface") {
+		name := word(sec, 1)
+		switch {
+		case strings.HasPrefix(name, "Loopback"):
+			id, _ := strconv.Atoi(strings.TrimPrefix(name, "Loopback"))
+			if a := address(sec); a != "" {
+				d.Loopbacks = slices.DeleteFunc(d.Loopbacks, func(l devices.Loopback) bool { return l.ID == id })
+				d.Loopbacks = append(d.Loopbacks, devices.Loopback{ID: id, Address: a})
+			}
+		case strings.HasPrefix(name, "Vxlan"):
+			if d.VXLAN == nil {
+				d.VXLAN = &devices.VXLAN{}
+			}
+			if s := sec.Child("vxlan source-interface"); s != nil {
+				d.VXLAN.SourceInterface = word(s, 2)
+			}
+			for _, m := range sec.Select("vxlan vlan * vni") {
+				id, err1 := strconv.Atoi(word(m, 2))
+				vni, err2 := strconv.Atoi(word(m, 4))
+				if err1 == nil && err2 == nil {
+					vlan(id).VNI = vni
+				}
+			}
+			for _, m := range sec.Select("vxlan vrf * vni") {
+				if vni, err := strconv.Atoi(word(m, 4)); err == nil {
+					vrf(word(m, 2)).VNI = vni
+				}
+			}
+		case strings.HasPrefix(name, "Management"):
+			// dhcp from the containerlab mgmt network; inspect knows the address
+		default:
+			i := intfs[name]
+			if i == nil {
+				i = &devices.Interface{Name: name}
+				intfs[name] = i
+				intfOrder = append(intfOrder, name)
+			}
+			if a := address(sec); a != "" {
+				i.Address = a
+			}
+			if a := sec.Child("ip address virtual"); a != nil {
+				i.Gateway = word(a, 3)
+			}
+			if v := sec.Child("vrf"); v != nil {
+				i.VRF = word(v, 1)
+			}
+			if v := sec.Child("switchport access vlan"); v != nil {
+				i.VLAN, _ = strconv.Atoi(word(v, 3))
+			}
+			if sec.Has("spanning-tree portfast") {
+				i.PortFast = true
+			}
+			if m := sec.Child("mtu"); m != nil {
+				i.MTU, _ = strconv.Atoi(word(m, 1))
+			}
+		}
+	}
+	for _, name := range intfOrder {
+		d.Interfaces = append(d.Interfaces, *intfs[name])
+	}
+	slices.SortStableFunc(d.Loopbacks, func(a, b devices.Loopback) int { return a.ID - b.ID })
+
+	if bgp := root.Select("router bgp"); len(bgp) > 0 {
+		d.BGP = bgpFromConfig(bgp)
+		for _, v := range root.Select("router bgp", "vlan") {
+			id, err := strconv.Atoi(word(v, 1))
+			if err != nil {
+				continue
+			}
+			if rd := v.Child("rd"); rd != nil {
+				vlan(id).RD = word(rd, 1)
+			}
+			for _, rt := range v.Select("route-target") {
+				if w := rt.Words(); len(w) >= 3 && w[1] != "export" {
+					vlan(id).RouteTarget = w[2]
+				}
+			}
+		}
+		for _, v := range root.Select("router bgp", "vrf") {
+			if rd := v.Child("rd"); rd != nil {
+				vrf(word(v, 1)).RD = word(rd, 1)
+			}
+			// route-target import evpn 65000:50001
+			for _, rt := range v.Select("route-target") {
+				if w := rt.Words(); len(w) >= 4 && w[1] != "export" && w[2] == "evpn" {
+					vrf(word(v, 1)).RouteTarget = w[3]
+				}
+			}
+		}
+	}
+	ids := make([]int, 0, len(vlans))
+	for id := range vlans {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		d.VLANs = append(d.VLANs, *vlans[id])
+	}
+	for _, name := range vrfOrder {
+		d.VRFs = append(d.VRFs, *vrfs[name])
+	}
+
+	for _, api := range root.Select("management api http-commands") {
+		if api.Has("no shutdown") {
+			d.EAPI = true
+		}
+	}
+	d.Multicast = root.Has("router multicast")
+	return d
+}
+
+// bgpFromConfig reads the router bgp stanzas: neighbors outside a peer
+// group are the underlay, and the peer group activated for EVPN (or the
+// first one) is the overlay. Route reflection and allowas-in on any
+// session count for the whole instance.
+func bgpFromConfig(sections []*eosconfig.Node) *devices.BGP {
+	b := &devices.BGP{}
+	type peer struct {
+		remoteAS int
+		group    string
+	}
+	var (
+		order  []string
+		peers  = map[string]*peer{}
+		groups []string
+		evpn   string
+	)
+	get := func(addr string) *peer {
+		if peers[addr] == nil {
+			peers[addr] = &peer{}
+			order = append(order, addr)
+		}
+		return peers[addr]
+	}
+	groupAttrs := map[string]*devices.PeerGroup{}
+	for _, sec := range sections {
+		b.ASN, _ = strconv.Atoi(word(sec, 2))
+		if r := sec.Child("router-id"); r != nil {
+			b.RouterID = word(r, 1)
+		}
+		for _, n := range sec.Select("neighbor") {
+			w := n.Words()
+			if len(w) < 3 {
+				continue
+			}
+			name := w[1]
+			switch w[2] {
+			case "route-reflector-client":
+				b.RouteReflector = true
+				continue
+			case "allowas-in":
+				b.AllowASIn = 1
+				if len(w) > 3 {
+					b.AllowASIn, _ = strconv.Atoi(w[3])
+				}
+				continue
+			}
+			switch {
+			case len(w) == 4 && w[2] == "peer" && w[3] == "group":
+				groups = append(groups, name)
+				groupAttrs[name] = &devices.PeerGroup{Name: name}
+			case groupAttrs[name] != nil:
+				g := groupAttrs[name]
+				switch w[2] {
+				case "update-source":
+					g.UpdateSource = word(n, 3)
+				case "ebgp-multihop":
+					g.EBGPMultihop, _ = strconv.Atoi(word(n, 3))
+				}
+			case w[2] == "peer" && len(w) >= 5:
+				p := get(name)
+				p.group = w[4]
+				// legacy one-line form: neighbor A peer group G remote-as N
+				if len(w) >= 7 && w[5] == "remote-as" {
+					p.remoteAS, _ = strconv.Atoi(w[6])
+				}
+			case w[2] == "remote-as" && len(w) >= 4:
+				get(name).remoteAS, _ = strconv.Atoi(w[3])
+			}
+		}
+		for _, a := range sec.Select("address-family evpn", "neighbor * activate") {
+			if groupAttrs[word(a, 1)] != nil && evpn == "" {
+				evpn = word(a, 1)
+			}
+		}
+	}
+	if evpn == "" && len(groups) > 0 {
+		evpn = groups[0]
+	}
+	for _, addr := range order {
+		p := peers[addr]
+		n := devices.Neighbor{Address: addr, RemoteAS: p.remoteAS}
+		switch {
+		case p.group == "":
+			b.Underlay = append(b.Underlay, n)
+		case p.group == evpn:
+			groupAttrs[evpn].Neighbors = append(groupAttrs[evpn].Neighbors, n)
+		}
+	}
+	if evpn != "" {
+		b.Overlay = groupAttrs[evpn]
+	}
+	return b
+}
+
+// address returns the "ip address" of an interface section, without dhcp
+// and anycast (virtual) addresses.
+func address(sec *eosconfig.Node) string {
+	if a := sec.Child("ip address"); a != nil && word(a, 2) != "dhcp" && word(a, 2) != "virtual" {
+		return word(a, 2)
+	}
+	return ""
+}
+
+// word returns the i-th word of n's line, or "".
+func word(n *eosconfig.Node, i int) string {
+	if w := n.Words(); i < len(w) {
+		return w[i]
+	}
+	return ""
+}
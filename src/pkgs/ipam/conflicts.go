@@ -0,0 +1,183 @@
+package ipam
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Use is an address configured on a device interface.
+type Use struct {
+	Device    string `json:"device"`
+	Interface string `json:"interface"` // EOS name, "Ethernet1"
+	Prefix    string `json:"prefix"`    // "172.16.1.1/31"
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+}
+
+func (u Use) String() string {
+	return fmt.Sprintf("%s %s %s", u.Device, u.Interface, u.Prefix)
+}
+
+// Conflict is a configured address the IPAM disagrees with.
+type Conflict struct {
+	Use     Use    `json:"use"`
+	Message string `json:"message"`
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", c.Use.File, c.Use.Line, c.Use, c.Message)
+}
+
+// ConfigUses lists the interface addresses of a parsed EOS config. dhcp
+// interfaces are skipped.
+func ConfigUses(device, file string, root *eosconfig.Node) []Use {
+	var uses []Use
+	for _, sec := range root.Select("interface") {
+		w := sec.Words()
+		if len(w) < 2 {
+			continue
+		}
+		for _, a := range sec.Select("ip address") {
+			aw := a.Words()
+			if len(aw) < 3 || aw[2] == "dhcp" {
+				continue
+			}
+			uses = append(uses, Use{Device: device, Interface: w[1], Prefix: aw[2], File: file, Line: a.Line})
+		}
+	}
+	return uses
+}
+
+// Check compares configured addresses with the IPAM. It reports addresses
+// inside a reserved range (the clab management network), the same address
+// on two interfaces, addresses of a pool whose block is allocated to other
+// devices or to nobody, and blocks of the wrong size.
+func (m *IPAM) Check(uses []Use) []Conflict {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Conflict
+	add := func(u Use, format string, args ...any) {
+		out = append(out, Conflict{Use: u, Message: fmt.Sprintf(format, args...)})
+	}
+	first := map[netip.Addr]Use{}
+	for _, u := range uses {
+		p, err := netip.ParsePrefix(u.Prefix)
+		if err != nil {
+			add(u, "not an address: %v", err)
+			continue
+		}
+		if r := m.reserved(u.Prefix); r != "" {
+			add(u, "overlaps reserved %s", r)
+		}
+		if o, dup := first[p.Addr()]; dup && (o.Device != u.Device || o.Interface != u.Interface) {
+			add(u, "%s is also configured on %s %s (%s:%d)", p.Addr(), o.Device, o.Interface, o.File, o.Line)
+		} else if !dup {
+			first[p.Addr()] = u
+		}
+		pool, block, ok := m.poolOf(p.Addr())
+		if !ok {
+			continue
+		}
+		if p.Bits() != pool.Size {
+			add(u, "pool %s hands out /%d blocks, configured as /%d", pool.Name, pool.Size, p.Bits())
+		}
+		a, ok := m.byPrefix(pool.Name, block)
+		switch {
+		case !ok:
+			add(u, "%s of pool %s is not allocated", block, pool.Name)
+		case len(a.Nodes) > 0 && !slices.Contains(a.Nodes, u.Device):
+			add(u, "%s of pool %s is allocated to %s", block, pool.Name, a.Key)
+		}
+	}
+	return out
+}
+
+// Import records the blocks existing configs already use, so a hand-made
+// lab can be brought under the IPAM. A loopback block is keyed by its
+// device, any other by its link ("leaf1:eth1 spine1:eth1"). Blocks that
+// are allocated already, reserved or of the wrong size are left alone;
+// Check reports them. Import returns the allocations it added.
+func (m *IPAM) Import(uses []Use) []Allocation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	type group struct {
+		pool  Pool
+		block string
+		uses  []Use
+	}
+	var order []string
+	groups := map[string]*group{}
+	for _, u := range uses {
+		p, err := netip.ParsePrefix(u.Prefix)
+		if err != nil || m.reserved(u.Prefix) != "" {
+			continue
+		}
+		pool, block, ok := m.poolOf(p.Addr())
+		if !ok || p.Bits() != pool.Size {
+			continue
+		}
+		if _, taken := m.byPrefix(pool.Name, block); taken {
+			continue
+		}
+		id := pool.Name + " " + block
+		if groups[id] == nil {
+			groups[id] = &group{pool: pool, block: block}
+			order = append(order, id)
+		}
+		groups[id].uses = append(groups[id].uses, u)
+	}
+	var added []Allocation
+	for _, id := range order {
+		g := groups[id]
+		var ends, nodes []string
+		for _, u := range g.uses {
+			ends = append(ends, u.Device+":"+netmath.LinuxInterface(u.Interface))
+			if !slices.Contains(nodes, u.Device) {
+				nodes = append(nodes, u.Device)
+			}
+		}
+		slices.Sort(ends)
+		key := strings.Join(ends, " ")
+		if g.pool.Size == g.pool.bitLen() && len(nodes) == 1 {
+			key = nodes[0]
+		}
+		if _, dup := m.get(g.pool.Name, key); dup {
+			continue
+		}
+		a := Allocation{Pool: g.pool.Name, Key: key, Prefix: g.block, Nodes: nodes}
+		m.Allocations = append(m.Allocations, a)
+		added = append(added, a)
+	}
+	return added
+}
+
+// poolOf finds the pool containing addr and the block of it addr is in.
+func (m *IPAM) poolOf(addr netip.Addr) (Pool, string, bool) {
+	for _, p := range m.Pools {
+		pp, _ := netip.ParsePrefix(p.Prefix)
+		if pp.Contains(addr) {
+			b, _ := addr.Prefix(p.Size)
+			return p, b.String(), true
+		}
+	}
+	return Pool{}, "", false
+}
+
+func (m *IPAM) byPrefix(pool, prefix string) (Allocation, bool) {
+	for _, a := range m.Allocations {
+		if a.Pool == pool && a.Prefix == prefix {
+			return a, true
+		}
+	}
+	return Allocation{}, false
+}
+
+func (p Pool) bitLen() int {
+	pp, _ := netip.ParsePrefix(p.Prefix)
+	return pp.Addr().BitLen()
+}
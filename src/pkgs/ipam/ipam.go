@@ -0,0 +1,418 @@
+// Package ipam hands out the fabric's addresses from named pools: router
+// IDs, VTEP loopbacks, point-to-point /31s and host subnets. Allocations
+// are keyed by device or link, so asking again for the same key returns
+// the same block, and they are kept in a JSON file next to the lab so
+// every run sees the same plan.
+//
+//	db, _ := ipam.Open("../ipam.json")
+//	a, _ := db.Allocate(ipam.PoolRouterID, "leaf1", "leaf1") // 10.0.0.1/32
+//	db.ReleaseNode("leaf4")
+//	db.Save()
+package ipam
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// Names of the default pools.
+const (
+	PoolRouterID = "router-id" // Loopback0
+	PoolVTEP     = "vtep"      // Loopback1
+	PoolP2P      = "p2p"       // underlay /31s
+	PoolHosts    = "hosts"     // host VLAN subnets
+)
+
+// ManagementNetwork is containerlab's default management subnet; nothing
+// is ever allocated from it.
+const ManagementNetwork = "172.20.20.0/24"
+
+// Pool is a prefix carved into equal blocks of /Size.
+type Pool struct {
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`          // "10.0.0.0/24"
+	Size   int    `json:"size"`            // prefix length handed out: 32, 31, 24
+	Start  int    `json:"start,omitempty"` // index of the first block to hand out
+}
+
+// Allocation is one block of a pool given to a key, "leaf1" or
+// "leaf1:eth1 spine1:eth1". Nodes are the devices that use it; releasing a
+// node frees its allocations.
+type Allocation struct {
+	Pool   string   `json:"pool"`
+	Key    string   `json:"key"`
+	Prefix string   `json:"prefix"`
+	Nodes  []string `json:"nodes,omitempty"`
+}
+
+// IPAM is a set of pools, reserved ranges and allocations, optionally
+// backed by a file. It is safe for concurrent use.
+type IPAM struct {
+	mu          sync.Mutex
+	path        string
+	Reserved    []string     `json:"reserved"`
+	Pools       []Pool       `json:"pools"`
+	Allocations []Allocation `json:"allocations"`
+}
+
+// DefaultPools are the pools of the original lab's addressing plan.
+func DefaultPools() []Pool {
+	return []Pool{
+		{Name: PoolRouterID, Prefix: "10.0.0.0/24", Size: 32, Start: 1},
+		{Name: PoolVTEP, Prefix: "10.255.0.0/24", Size: 32, Start: 1},
+		{Name: PoolP2P, Prefix: "172.16.0.0/16", Size: 31},
+		{Name: PoolHosts, Prefix: "10.10.0.0/16", Size: 24, Start: 10},
+	}
+}
+
+// New returns an IPAM with the default pools and the management network
+// reserved.
+func New() *IPAM {
+	m := &IPAM{Reserved: []string{ManagementNetwork}}
+	for _, p := range DefaultPools() {
+		if err := m.AddPool(p); err != nil {
+			panic(err) // the defaults are known to be valid
+		}
+	}
+	return m
+}
+
+// Open loads the IPAM file at path. A missing file gives New(), which Save
+// then creates.
+func Open(path string) (*IPAM, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		m := New()
+		m.path = path
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &IPAM{path: path}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Save writes the IPAM back to the file it was opened from, replacing it
+// atomically.
+func (m *IPAM) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.path == "" {
+		return fmt.Errorf("ipam has no file")
+	}
+	m.sort()
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".ipam-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+// sort orders allocations by pool, then address, so the file diffs well.
+func (m *IPAM) sort() {
+	slices.SortFunc(m.Allocations, func(a, b Allocation) int {
+		if c := strings.Compare(a.Pool, b.Pool); c != 0 {
+			return c
+		}
+		pa, _ := netip.ParsePrefix(a.Prefix)
+		pb, _ := netip.ParsePrefix(b.Prefix)
+		return pa.Addr().Compare(pb.Addr())
+	})
+}
+
+// AddPool adds p. Pools may not overlap each other or a reserved range.
+func (m *IPAM) AddPool(p Pool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkPool(p); err != nil {
+		return err
+	}
+	m.Pools = append(m.Pools, p)
+	return nil
+}
+
+func (m *IPAM) checkPool(p Pool) error {
+	pp, err := netip.ParsePrefix(p.Prefix)
+	if err != nil {
+		return fmt.Errorf("pool %s: %w", p.Name, err)
+	}
+	if pp != pp.Masked() {
+		return fmt.Errorf("pool %s: %s is not a network address", p.Name, p.Prefix)
+	}
+	if p.Name == "" {
+		return fmt.Errorf("pool %s has no name", p.Prefix)
+	}
+	if p.Size < pp.Bits() || p.Size > pp.Addr().BitLen() || p.Size-pp.Bits() > 30 || pp.Addr().BitLen()-pp.Bits() > 62 {
+		return fmt.Errorf("pool %s: cannot carve /%d blocks from %s", p.Name, p.Size, p.Prefix)
+	}
+	if p.Start < 0 || p.Start >= p.blocks() {
+		return fmt.Errorf("pool %s: start %d is outside its %d blocks", p.Name, p.Start, p.blocks())
+	}
+	for _, r := range m.Reserved {
+		if rp, err := netip.ParsePrefix(r); err == nil && rp.Overlaps(pp) {
+			return fmt.Errorf("pool %s (%s) overlaps reserved %s", p.Name, p.Prefix, r)
+		}
+	}
+	for _, o := range m.Pools {
+		if o.Name == p.Name {
+			return fmt.Errorf("pool %s already exists", p.Name)
+		}
+		if op, _ := netip.ParsePrefix(o.Prefix); op.Overlaps(pp) {
+			return fmt.Errorf("pool %s (%s) overlaps pool %s (%s)", p.Name, p.Prefix, o.Name, o.Prefix)
+		}
+	}
+	return nil
+}
+
+// validate re-checks a loaded file: pools one by one, then that every
+// allocation is a block of its pool and no block is given out twice.
+func (m *IPAM) validate() error {
+	pools := m.Pools
+	m.Pools = nil
+	for _, p := range pools {
+		if err := m.checkPool(p); err != nil {
+			return err
+		}
+		m.Pools = append(m.Pools, p)
+	}
+	owner := map[string]string{} // prefix to pool/key
+	keys := map[string]bool{}
+	for _, a := range m.Allocations {
+		p, ok := m.pool(a.Pool)
+		if !ok {
+			return fmt.Errorf("allocation %s: no pool %s", a.Key, a.Pool)
+		}
+		if _, err := p.index(a.Prefix); err != nil {
+			return fmt.Errorf("allocation %s: %w", a.Key, err)
+		}
+		id := a.Pool + "/" + a.Key
+		if other, dup := owner[a.Prefix]; dup {
+			return fmt.Errorf("%s is allocated to both %s and %s", a.Prefix, other, id)
+		}
+		if keys[id] {
+			return fmt.Errorf("key %s has two allocations in pool %s", a.Key, a.Pool)
+		}
+		owner[a.Prefix], keys[id] = id, true
+	}
+	return nil
+}
+
+func (m *IPAM) pool(name string) (Pool, bool) {
+	for _, p := range m.Pools {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Pool{}, false
+}
+
+// blocks is the number of /Size blocks in the pool.
+func (p Pool) blocks() int {
+	pp, _ := netip.ParsePrefix(p.Prefix)
+	return 1 << (p.Size - pp.Bits())
+}
+
+// block returns block i of the pool, "172.16.0.6/31" for i 3 of a /31 pool.
+func (p Pool) block(i int) (string, error) {
+	pp, _ := netip.ParsePrefix(p.Prefix)
+	a, err := netmath.CIDRHost(p.Prefix, i<<(pp.Addr().BitLen()-p.Size))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d", a, p.Size), nil
+}
+
+// index is the reverse of block.
+func (p Pool) index(prefix string) (int, error) {
+	pp, _ := netip.ParsePrefix(p.Prefix)
+	bp, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return 0, err
+	}
+	if bp.Bits() != p.Size || bp != bp.Masked() || !pp.Contains(bp.Addr()) {
+		return 0, fmt.Errorf("%s is not a /%d block of pool %s (%s)", prefix, p.Size, p.Name, p.Prefix)
+	}
+	diff := new(big.Int).SetBytes(bp.Addr().AsSlice())
+	diff.Sub(diff, new(big.Int).SetBytes(pp.Addr().AsSlice()))
+	return int(diff.Rsh(diff, uint(pp.Addr().BitLen()-p.Size)).Int64()), nil
+}
+
+// Get returns the allocation of key in pool.
+func (m *IPAM) Get(pool, key string) (Allocation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(pool, key)
+}
+
+func (m *IPAM) get(pool, key string) (Allocation, bool) {
+	for _, a := range m.Allocations {
+		if a.Pool == pool && a.Key == key {
+			return a, true
+		}
+	}
+	return Allocation{}, false
+}
+
+// Allocate returns the block of key in pool, giving it the lowest free
+// block if it has none yet. nodes are recorded for ReleaseNode.
+func (m *IPAM) Allocate(pool, key string, nodes ...string) (Allocation, error) {
+	return m.allocate(pool, key, -1, nodes)
+}
+
+// AllocateAt gives key block index of pool, e.g. the leaf number, so the
+// plan doesn't depend on the order devices are added in. It fails if the
+// block belongs to another key or key already holds a different block.
+func (m *IPAM) AllocateAt(pool, key string, index int, nodes ...string) (Allocation, error) {
+	if index < 0 {
+		return Allocation{}, fmt.Errorf("negative block index %d", index)
+	}
+	return m.allocate(pool, key, index, nodes)
+}
+
+func (m *IPAM) allocate(pool, key string, index int, nodes []string) (Allocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.pool(pool)
+	if !ok {
+		return Allocation{}, fmt.Errorf("no pool %s", pool)
+	}
+	if key == "" {
+		return Allocation{}, fmt.Errorf("pool %s: empty key", pool)
+	}
+	if a, ok := m.get(pool, key); ok {
+		if i, _ := p.index(a.Prefix); index >= 0 && i != index {
+			return Allocation{}, fmt.Errorf("%s already holds %s in pool %s", key, a.Prefix, pool)
+		}
+		return a, nil
+	}
+	taken := map[string]string{}
+	for _, a := range m.Allocations {
+		if a.Pool == pool {
+			taken[a.Prefix] = a.Key
+		}
+	}
+	try := func(i int) (string, error) {
+		b, err := p.block(i)
+		if err != nil {
+			return "", err
+		}
+		if owner, ok := taken[b]; ok {
+			return "", fmt.Errorf("%s is allocated to %s", b, owner)
+		}
+		if r := m.reserved(b); r != "" {
+			return "", fmt.Errorf("%s is inside reserved %s", b, r)
+		}
+		return b, nil
+	}
+	var prefix string
+	if index >= 0 {
+		if index >= p.blocks() {
+			return Allocation{}, fmt.Errorf("pool %s has no block %d", pool, index)
+		}
+		b, err := try(index)
+		if err != nil {
+			return Allocation{}, fmt.Errorf("pool %s: %w", pool, err)
+		}
+		prefix = b
+	} else {
+		for i := p.Start; i < p.blocks() && prefix == ""; i++ {
+			prefix, _ = try(i)
+		}
+		if prefix == "" {
+			return Allocation{}, fmt.Errorf("pool %s (%s) is exhausted", pool, p.Prefix)
+		}
+	}
+	a := Allocation{Pool: pool, Key: key, Prefix: prefix, Nodes: slices.Clone(nodes)}
+	m.Allocations = append(m.Allocations, a)
+	return a, nil
+}
+
+// reserved returns the reserved range prefix overlaps, or "".
+func (m *IPAM) reserved(prefix string) string {
+	bp, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return ""
+	}
+	for _, r := range m.Reserved {
+		if rp, err := netip.ParsePrefix(r); err == nil && rp.Overlaps(bp) {
+			return r
+		}
+	}
+	return ""
+}
+
+// Release frees the block of key in pool and reports whether there was one.
+func (m *IPAM) Release(pool, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.Allocations)
+	m.Allocations = slices.DeleteFunc(m.Allocations, func(a Allocation) bool { return a.Pool == pool && a.Key == key })
+	return len(m.Allocations) != n
+}
+
+// ReleaseNode takes node off every allocation it uses and returns the ones
+// that are freed: its loopbacks, the links to it (a link is gone with
+// either end) and shared blocks, such as a host subnet, once no other node
+// is left on them.
+func (m *IPAM) ReleaseNode(node string) []Allocation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var freed []Allocation
+	m.Allocations = slices.DeleteFunc(m.Allocations, func(a Allocation) bool {
+		if !slices.Contains(a.Nodes, node) {
+			return false
+		}
+		if a.Pool == PoolP2P || len(a.Nodes) == 1 {
+			freed = append(freed, a)
+			return true
+		}
+		return false
+	})
+	for i, a := range m.Allocations {
+		if slices.Contains(a.Nodes, node) {
+			m.Allocations[i].Nodes = slices.DeleteFunc(slices.Clone(a.Nodes), func(n string) bool { return n == node })
+		}
+	}
+	return freed
+}
+
+// LinkKey names a link independent of the order its ends are given in:
+// LinkKey("spine1:eth1", "leaf1:eth1") is "leaf1:eth1 spine1:eth1".
+func LinkKey(a, b string) string {
+	if b < a {
+		a, b = b, a
+	}
+	return a + " " + b
+}
@@ -0,0 +1,202 @@
+package ipam
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eoslint"
+)
+
+func TestAllocate(t *testing.T) {
+	m := New()
+	a, err := m.Allocate(PoolRouterID, "spine1", "spine1")
+	if err != nil || a.Prefix != "10.0.0.1/32" {
+		t.Fatalf("spine1 = %+v, %v", a, err)
+	}
+	b, _ := m.Allocate(PoolRouterID, "spine2", "spine2")
+	again, _ := m.Allocate(PoolRouterID, "spine1")
+	if b.Prefix != "10.0.0.2/32" || again.Prefix != a.Prefix {
+		t.Errorf("spine2 %s, spine1 again %s", b.Prefix, again.Prefix)
+	}
+
+	leaf, err := m.AllocateAt(PoolRouterID, "leaf1", 11, "leaf1")
+	if err != nil || leaf.Prefix != "10.0.0.11/32" {
+		t.Fatalf("leaf1 = %+v, %v", leaf, err)
+	}
+	if _, err := m.AllocateAt(PoolRouterID, "leaf9", 11); err == nil || !strings.Contains(err.Error(), "allocated to leaf1") {
+		t.Errorf("taken block: err = %v", err)
+	}
+	if _, err := m.AllocateAt(PoolRouterID, "leaf1", 12); err == nil {
+		t.Error("leaf1 moved to another block")
+	}
+	if _, err := m.AllocateAt(PoolRouterID, "leaf9", 256); err == nil {
+		t.Error("block past the end of the pool")
+	}
+
+	link, _ := m.Allocate(PoolP2P, LinkKey("spine1:eth1", "leaf1:eth1"), "leaf1", "spine1")
+	if link.Prefix != "172.16.0.0/31" || link.Key != "leaf1:eth1 spine1:eth1" {
+		t.Errorf("link = %+v", link)
+	}
+	if h, _ := m.Allocate(PoolHosts, "vlan10"); h.Prefix != "10.10.10.0/24" {
+		t.Errorf("hosts = %+v", h)
+	}
+	if _, err := m.Allocate("nope", "x"); err == nil {
+		t.Error("allocated from a missing pool")
+	}
+}
+
+func TestPoolsAndReserved(t *testing.T) {
+	m := New()
+	for _, p := range []Pool{
+		{Name: "mgmt", Prefix: "172.20.0.0/16", Size: 32},
+		{Name: "dup", Prefix: "10.0.0.128/25", Size: 32},
+		{Name: "p2p", Prefix: "192.0.2.0/24", Size: 31},
+		{Name: "wide", Prefix: "192.0.2.0/24", Size: 16},
+		{Name: "host", Prefix: "192.0.2.1/24", Size: 32},
+	} {
+		if err := m.AddPool(p); err == nil {
+			t.Errorf("pool %+v accepted", p)
+		}
+	}
+
+	// ranges reserved after a pool was made are still skipped
+	m = &IPAM{}
+	if err := m.AddPool(Pool{Name: "small", Prefix: "192.0.2.0/30", Size: 31}); err != nil {
+		t.Fatal(err)
+	}
+	m.Reserved = append(m.Reserved, "192.0.2.0/31")
+	a, err := m.Allocate("small", "a")
+	if err != nil || a.Prefix != "192.0.2.2/31" {
+		t.Fatalf("a = %+v, %v", a, err)
+	}
+	if _, err := m.Allocate("small", "b"); err == nil || !strings.Contains(err.Error(), "exhausted") {
+		t.Errorf("b: err = %v", err)
+	}
+	if _, err := m.AllocateAt("small", "b", 0); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("reserved block: err = %v", err)
+	}
+}
+
+func TestPersistAndRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipam.json")
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AllocateAt(PoolRouterID, "leaf2", 12, "leaf2")
+	m.AllocateAt(PoolRouterID, "leaf1", 11, "leaf1")
+	m.Allocate(PoolP2P, LinkKey("leaf1:eth1", "spine1:eth1"), "leaf1", "spine1")
+	m.Allocate(PoolP2P, LinkKey("leaf2:eth1", "spine1:eth2"), "leaf2", "spine1")
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Allocations) != 4 || m.Allocations[2].Key != "leaf1" {
+		t.Fatalf("reloaded %+v", m.Allocations)
+	}
+	freed := m.ReleaseNode("leaf2")
+	if len(freed) != 2 || len(m.Allocations) != 2 {
+		t.Errorf("freed %+v, left %+v", freed, m.Allocations)
+	}
+	if !m.Release(PoolRouterID, "leaf1") || m.Release(PoolRouterID, "leaf1") {
+		t.Error("release leaf1")
+	}
+	// a shared block stays with the nodes still on it
+	vlan10, _ := m.Allocate(PoolHosts, "vlan10", "gpu1", "gpu2")
+	if freed := m.ReleaseNode("gpu1"); len(freed) != 0 {
+		t.Errorf("releasing gpu1 freed %+v", freed)
+	}
+	if a, _ := m.Allocate(PoolHosts, "vlan20"); a.Prefix == vlan10.Prefix {
+		t.Errorf("vlan20 got gpu2's %s", a.Prefix)
+	}
+	if freed := m.ReleaseNode("gpu2"); len(freed) != 1 || freed[0].Key != "vlan10" || len(freed[0].Nodes) != 1 {
+		t.Errorf("releasing gpu2 freed %+v", freed)
+	}
+	m.Release(PoolHosts, "vlan20")
+
+	// a released block is handed out again
+	if a, _ := m.Allocate(PoolP2P, "new"); a.Prefix != "172.16.0.2/31" {
+		t.Errorf("new = %+v", a)
+	}
+
+	os.WriteFile(path, []byte(`{"pools": [{"name": "p", "prefix": "10.9.0.0/24", "size": 32}],
+	  "allocations": [{"pool": "p", "key": "a", "prefix": "10.9.0.1/32"}, {"pool": "p", "key": "b", "prefix": "10.9.0.1/32"}]}`), 0o644)
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "allocated to both") {
+		t.Errorf("duplicate block: err = %v", err)
+	}
+}
+
+func labUses(t *testing.T) []Use {
+	t.Helper()
+	cfgs, err := eoslint.Load("../../../configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uses []Use
+	for _, c := range cfgs {
+		uses = append(uses, ConfigUses(c.Hostname(), c.File, c.Root)...)
+	}
+	return uses
+}
+
+func TestImportAndCheckLab(t *testing.T) {
+	m := New()
+	uses := labUses(t)
+	if got := m.Check(uses); len(got) == 0 {
+		t.Fatal("empty IPAM reports no unallocated addresses")
+	}
+	added := m.Import(uses)
+	count := map[string]int{}
+	for _, a := range added {
+		count[a.Pool]++
+	}
+	if count[PoolRouterID] != 6 || count[PoolVTEP] != 4 || count[PoolP2P] != 8 {
+		t.Errorf("imported %v", count)
+	}
+	if a, ok := m.Get(PoolP2P, "leaf3:eth2 spine2:eth3"); !ok || a.Prefix != "172.16.2.4/31" {
+		t.Errorf("leaf3-spine2 link = %+v", a)
+	}
+	if a, ok := m.Get(PoolVTEP, "leaf4"); !ok || a.Prefix != "10.255.0.14/32" {
+		t.Errorf("leaf4 vtep = %+v", a)
+	}
+	for _, c := range m.Check(uses) {
+		t.Errorf("after import: %s", c)
+	}
+	if len(m.Import(uses)) != 0 {
+		t.Error("second import added allocations")
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	m := New()
+	m.AllocateAt(PoolRouterID, "leaf1", 11, "leaf1")
+	m.AllocateAt(PoolRouterID, "leaf2", 12, "leaf2")
+	uses := []Use{
+		{Device: "leaf1", Interface: "Loopback0", Prefix: "10.0.0.11/32", File: "leaf1.cfg", Line: 20},
+		{Device: "leaf3", Interface: "Loopback0", Prefix: "10.0.0.12/32", File: "leaf3.cfg", Line: 20},
+		{Device: "leaf3", Interface: "Loopback9", Prefix: "10.0.0.11/32", File: "leaf3.cfg", Line: 30},
+		{Device: "leaf3", Interface: "Ethernet9", Prefix: "172.20.20.5/24", File: "leaf3.cfg", Line: 40},
+		{Device: "leaf3", Interface: "Loopback2", Prefix: "10.0.0.0/24", File: "leaf3.cfg", Line: 50},
+	}
+	var got []string
+	for _, c := range m.Check(uses) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"leaf3.cfg:20: leaf3 Loopback0 10.0.0.12/32: 10.0.0.12/32 of pool router-id is allocated to leaf2",
+		"leaf3.cfg:30: leaf3 Loopback9 10.0.0.11/32: 10.0.0.11 is also configured on leaf1 Loopback0 (leaf1.cfg:20)",
+		"leaf3.cfg:30: leaf3 Loopback9 10.0.0.11/32: 10.0.0.11/32 of pool router-id is allocated to leaf1",
+		"leaf3.cfg:40: leaf3 Ethernet9 172.20.20.5/24: overlaps reserved 172.20.20.0/24",
+		"leaf3.cfg:50: leaf3 Loopback2 10.0.0.0/24: pool router-id hands out /32 blocks, configured as /24",
+		"leaf3.cfg:50: leaf3 Loopback2 10.0.0.0/24: 10.0.0.0/32 of pool router-id is not allocated",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("conflicts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,84 @@
+// Package logging sets up the structured (log/slog) logger shared by the
+// clients, the renderer and the web handlers.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Level is the process-wide log level. It can be changed at runtime, e.g.
+// from the /loglevel endpoint.
+var Level = new(slog.LevelVar)
+
+// Init installs the default logger writing to w in "text" or "json" format
+// at the given level.
+func Init(w io.Writer, format, level string) error {
+	if err := SetLevel(level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: Level}
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// SetLevel changes the log level; it accepts debug, info, warn and error.
+func SetLevel(level string) error {
+	if level == "" {
+		level = "info"
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	Level.Set(l)
+	return nil
+}
+
+// Debug reports whether debug records (full payload dumps) are enabled.
+func Debug() bool {
+	return Level.Level() <= slog.LevelDebug
+}
+
+type ctxKey struct{}
+
+// NewRequestID returns a short random ID for correlating log records.
+func NewRequestID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID stores a request ID in ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger, tagged with the request ID from
+// ctx when there is one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
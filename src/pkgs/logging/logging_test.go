@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"username admin privilege 15 secret admin", "username admin privilege 15 secret <redacted>"},
+		{"username admin secret sha512 $6$abc/def", "username admin secret sha512 <redacted>"},
+		{"enable password 7 08224D", "enable password 7 <redacted>"},
+		{`{"cmds":["configure","username ops secret hunter2","end"]}`, `{"cmds":["configure","username ops secret <redacted>","end"]}`},
+		{`{"user":"admin","pass":"admin"}`, `{"user":"admin","pass":"<redacted>"}`},
+		{`{"Password": "a\"b"}`, `{"Password": "<redacted>"}`},
+		{"show bgp evpn summary", "show bgp evpn summary"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLevelAndRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Init(&buf, "json", "info"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithRequestID(context.Background(), "abc123")
+	FromContext(ctx).Debug("hidden")
+	FromContext(ctx).Info("shown", "device", "leaf1")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), `"request_id":"abc123"`) {
+		t.Fatalf("unexpected output at info: %s", buf.String())
+	}
+
+	if err := SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if !Debug() {
+		t.Fatal("Debug() false after SetLevel(debug)")
+	}
+	FromContext(ctx).Debug("now visible")
+	if !strings.Contains(buf.String(), "now visible") {
+		t.Fatalf("debug record missing: %s", buf.String())
+	}
+	if err := SetLevel("chatty"); err == nil {
+		t.Fatal("accepted unknown level")
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+}
@@ -0,0 +1,21 @@
+package logging
+
+import "regexp"
+
+// redacted replaces every secret we strip from logs.
+const redacted = "<redacted>"
+
+var (
+	// "username admin privilege 15 secret admin",
+	// "username admin secret sha512 $6$...", "enable password 7 0822..."
+	cliSecretRe = regexp.MustCompile(`(?i)\b(secret|password)(\s+)(?:(0|5|7|8a|sha512)(\s+))?("[^"]*"|[^\s"\\]+)`)
+	// {"pass": "admin"}, "password":"admin"
+	jsonSecretRe = regexp.MustCompile(`(?i)("(?:pass|passwd|password|secret)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// Redact masks passwords and "secret" values in CLI text and JSON so that
+// payloads and responses can be dumped at debug level.
+func Redact(s string) string {
+	s = jsonSecretRe.ReplaceAllString(s, `$1"`+redacted+`"`)
+	return cliSecretRe.ReplaceAllString(s, "$1$2$3$4"+redacted)
+}
@@ -0,0 +1,181 @@
+// Package netconf is a small NETCONF (RFC 6241) client for cEOS nodes
+// running "management api netconf" over SSH.
+package netconf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+)
+
+// Capability URIs this client cares about.
+const (
+	CapBase10    = "urn:ietf:params:netconf:base:1.0"
+	CapBase11    = "urn:ietf:params:netconf:base:1.1"
+	CapCandidate = "urn:ietf:params:netconf:capability:candidate:1.0"
+	CapValidate  = "urn:ietf:params:netconf:capability:validate:1.1"
+)
+
+// baseNS is the namespace of every NETCONF protocol element.
+const baseNS = "urn:ietf:params:xml:ns:netconf:base:1.0"
+
+// Datastore names a configuration datastore.
+type Datastore string
+
+const (
+	Running   Datastore = "running"
+	Candidate Datastore = "candidate"
+	Startup   Datastore = "startup"
+)
+
+// Config holds what a NETCONF session needs to log in.
+type Config struct {
+	User     string
+	Password string
+	Port     int // 830 by default
+	Timeout  time.Duration
+}
+
+// Session is one NETCONF session. Its methods are safe for concurrent
+// use; RPCs are serialised.
+type Session struct {
+	ID           int
+	Capabilities []string
+
+	mu     sync.Mutex
+	msgID  int
+	f      *framer
+	closer io.Closer
+}
+
+// Dial opens an SSH connection to addr, starts the "netconf" subsystem and
+// exchanges hellos.
+func Dial(addr string, cfg Config) (*Session, error) {
+	if cfg.Port == 0 {
+		cfg.Port = 830
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, fmt.Sprint(cfg.Port))
+	}
+	conf := &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.Password(cfg.Password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // lab only
+		Timeout:         cfg.Timeout,
+	}
+	conn, err := ssh.Dial("tcp", addr, conf)
+	if err != nil {
+		return nil, fmt.Errorf("netconf dial %s: %w", addr, err)
+	}
+	sess, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("netconf session: %w", err)
+	}
+	w, err := sess.StdinPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r, err := sess.StdoutPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := sess.RequestSubsystem("netconf"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("netconf subsystem: %w", err)
+	}
+	s, err := NewSession(r, w, closeAll{sess, conn})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// DialDevice opens a session to an inventory device's management address.
+func DialDevice(d devices.Device, cfg Config) (*Session, error) {
+	addr := d.MGMTAddress
+	if i := strings.IndexByte(addr, '/'); i > 0 {
+		addr = addr[:i]
+	}
+	if addr == "" {
+		return nil, errors.New("netconf: device has no management address")
+	}
+	return Dial(addr, cfg)
+}
+
+// NewSession runs the hello exchange over an already established
+// transport. closer is closed by Close.
+func NewSession(r io.Reader, w io.Writer, closer io.Closer) (*Session, error) {
+	s := &Session{f: newFramer(r, w), closer: closer}
+	hello := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<hello xmlns="%s"><capabilities><capability>%s</capability><capability>%s</capability></capabilities></hello>`,
+		baseNS, CapBase10, CapBase11)
+	if err := s.f.writeMsg([]byte(hello)); err != nil {
+		return nil, fmt.Errorf("netconf: send hello: %w", err)
+	}
+	msg, err := s.f.readMsg()
+	if err != nil {
+		return nil, fmt.Errorf("netconf: read hello: %w", err)
+	}
+	var h struct {
+		XMLName      xml.Name `xml:"hello"`
+		Capabilities []string `xml:"capabilities>capability"`
+		SessionID    int      `xml:"session-id"`
+	}
+	if err := xml.Unmarshal(msg, &h); err != nil {
+		return nil, fmt.Errorf("netconf: parse hello: %w", err)
+	}
+	for i, c := range h.Capabilities {
+		h.Capabilities[i] = strings.TrimSpace(c)
+	}
+	s.ID, s.Capabilities = h.SessionID, h.Capabilities
+	s.f.chunked = s.HasCapability(CapBase11)
+	return s, nil
+}
+
+// HasCapability reports whether the server advertised uri. Parameters
+// after "?" are ignored.
+func (s *Session) HasCapability(uri string) bool {
+	for _, c := range s.Capabilities {
+		if c == uri || strings.HasPrefix(c, uri+"?") {
+			return true
+		}
+	}
+	return false
+}
+
+// Close ends the session politely and tears down the transport.
+func (s *Session) Close() error {
+	_, err := s.Exec(`<close-session/>`)
+	if cerr := s.closer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type closeAll []io.Closer
+
+func (c closeAll) Close() error {
+	var errs []error
+	for _, cl := range c {
+		if err := cl.Close(); err != nil && !errors.Is(err, io.EOF) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
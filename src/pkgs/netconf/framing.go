@@ -0,0 +1,95 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// endOfMessage terminates every NETCONF 1.0 message (RFC 6242 section 4.3).
+const endOfMessage = "]]>]]>"
+
+// framer reads and writes NETCONF messages. It starts out with the 1.0
+// end-of-message framing used for <hello> and switches to 1.1 chunked
+// framing once both peers advertise base:1.1.
+type framer struct {
+	r       *bufio.Reader
+	w       io.Writer
+	chunked bool
+}
+
+func newFramer(r io.Reader, w io.Writer) *framer {
+	return &framer{r: bufio.NewReader(r), w: w}
+}
+
+// writeMsg sends one message with the current framing.
+func (f *framer) writeMsg(msg []byte) error {
+	var err error
+	if f.chunked {
+		_, err = fmt.Fprintf(f.w, "\n#%d\n%s\n##\n", len(msg), msg)
+	} else {
+		_, err = fmt.Fprintf(f.w, "%s\n%s", msg, endOfMessage)
+	}
+	return err
+}
+
+// readMsg returns the next message with its framing stripped.
+func (f *framer) readMsg() ([]byte, error) {
+	if f.chunked {
+		return f.readChunked()
+	}
+	var buf bytes.Buffer
+	for {
+		b, err := f.r.ReadBytes('>')
+		buf.Write(b)
+		if bytes.HasSuffix(buf.Bytes(), []byte(endOfMessage)) {
+			return bytes.TrimSpace(buf.Bytes()[:buf.Len()-len(endOfMessage)]), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readChunked reads chunks ("\n#<size>\n<data>") up to the end-of-chunks
+// marker "\n##\n".
+func (f *framer) readChunked() ([]byte, error) {
+	var msg bytes.Buffer
+	for {
+		if err := f.expect('\n'); err != nil {
+			return nil, err
+		}
+		if err := f.expect('#'); err != nil {
+			return nil, err
+		}
+		line, err := f.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = line[:len(line)-1]
+		if line == "#" {
+			return bytes.TrimSpace(msg.Bytes()), nil
+		}
+		size, err := strconv.ParseUint(line, 10, 32)
+		if err != nil || size == 0 {
+			return nil, fmt.Errorf("netconf: bad chunk size %q", line)
+		}
+		if _, err := io.CopyN(&msg, f.r, int64(size)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *framer) expect(want byte) error {
+	got, err := f.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return errors.New("netconf: malformed chunk header")
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package netconf
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+)
+
+// fakeServer is a local SSH server with a "netconf" subsystem that keeps a
+// running and a candidate datastore in memory.
+type fakeServer struct {
+	ln net.Listener
+
+	mu         sync.Mutex
+	running    string
+	candidate  string
+	lockedBy   int
+	sessions   int
+	lastFilter string
+}
+
+type dsXML struct {
+	Running   *struct{} `xml:"running"`
+	Candidate *struct{} `xml:"candidate"`
+}
+
+func (d dsXML) name() string {
+	if d.Candidate != nil {
+		return "candidate"
+	}
+	return "running"
+}
+
+type filterXML struct {
+	Type  string `xml:"type,attr"`
+	Inner string `xml:",innerxml"`
+}
+
+type rpcMsg struct {
+	MessageID string `xml:"message-id,attr"`
+	Get       *struct {
+		Filter filterXML `xml:"filter"`
+	} `xml:"get"`
+	GetConfig *struct {
+		Source dsXML     `xml:"source"`
+		Filter filterXML `xml:"filter"`
+	} `xml:"get-config"`
+	EditConfig *struct {
+		Target dsXML `xml:"target"`
+		Config struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"config"`
+	} `xml:"edit-config"`
+	Validate *struct {
+		Source dsXML `xml:"source"`
+	} `xml:"validate"`
+	Commit  *struct{} `xml:"commit"`
+	Discard *struct{} `xml:"discard-changes"`
+	Lock    *struct {
+		Target dsXML `xml:"target"`
+	} `xml:"lock"`
+	Unlock *struct {
+		Target dsXML `xml:"target"`
+	} `xml:"unlock"`
+	CloseSession *struct{} `xml:"close-session"`
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "admin" && string(pass) == "admin" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	conf.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	srv := &fakeServer{ln: ln, running: "<system><hostname>leaf1</hostname></system>"}
+	srv.candidate = srv.running
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serveConn(nc, conf)
+		}
+	}()
+	return srv
+}
+
+func (s *fakeServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeServer) serveConn(nc net.Conn, conf *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, conf)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range chReqs {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "netconf"
+				req.Reply(ok, nil)
+				if ok {
+					go s.session(ch)
+				}
+			}
+		}()
+	}
+}
+
+func (s *fakeServer) session(ch ssh.Channel) {
+	defer ch.Close()
+	s.mu.Lock()
+	s.sessions++
+	id := s.sessions
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.lockedBy == id {
+			s.lockedBy = 0
+		}
+		s.mu.Unlock()
+	}()
+
+	f := newFramer(ch, ch)
+	hello := fmt.Sprintf(`<hello xmlns="%s"><capabilities>
+  <capability>%s</capability>
+  <capability>%s</capability>
+  <capability>%s</capability>
+  <capability>%s</capability>
+</capabilities><session-id>%d</session-id></hello>`, baseNS, CapBase10, CapBase11, CapCandidate, CapValidate, id)
+	if f.writeMsg([]byte(hello)) != nil {
+		return
+	}
+	if _, err := f.readMsg(); err != nil {
+		return
+	}
+	f.chunked = true
+	for {
+		raw, err := f.readMsg()
+		if err != nil {
+			return
+		}
+		var m rpcMsg
+		if err := xml.Unmarshal(raw, &m); err != nil {
+			return
+		}
+		body, closing := s.handle(id, m)
+		reply := fmt.Sprintf(`<rpc-reply message-id="%s" xmlns="%s">%s</rpc-reply>`, m.MessageID, baseNS, body)
+		if f.writeMsg([]byte(reply)) != nil || closing {
+			return
+		}
+	}
+}
+
+func rpcErr(tag, msg string) string {
+	return fmt.Sprintf(`<rpc-error><error-type>protocol</error-type><error-tag>%s</error-tag>`+
+		`<error-severity>error</error-severity><error-message>%s</error-message></rpc-error>`, tag, msg)
+}
+
+func (s *fakeServer) handle(id int, m rpcMsg) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case m.Get != nil:
+		s.lastFilter = m.Get.Filter.Inner
+		return "<data>" + s.running + `<state><uptime>42</uptime></state></data>`, false
+	case m.GetConfig != nil:
+		s.lastFilter = m.GetConfig.Filter.Inner
+		if m.GetConfig.Source.name() == "candidate" {
+			return "<data>" + s.candidate + "</data>", false
+		}
+		return "<data>" + s.running + "</data>", false
+	case m.EditConfig != nil:
+		if s.lockedBy != 0 && s.lockedBy != id {
+			return rpcErr("in-use", "datastore locked"), false
+		}
+		s.candidate = m.EditConfig.Config.Inner
+	case m.Validate != nil:
+		if strings.Contains(s.candidate, "<bogus") {
+			return rpcErr("invalid-value", "unknown element bogus"), false
+		}
+	case m.Commit != nil:
+		s.running = s.candidate
+	case m.Discard != nil:
+		s.candidate = s.running
+	case m.Lock != nil:
+		if s.lockedBy != 0 {
+			return rpcErr("lock-denied", "lock held by session "+strconv.Itoa(s.lockedBy)), false
+		}
+		s.lockedBy = id
+	case m.Unlock != nil:
+		if s.lockedBy != id {
+			return rpcErr("operation-failed", "not locked by this session"), false
+		}
+		s.lockedBy = 0
+	case m.CloseSession != nil:
+		return "<ok/>", true
+	default:
+		return rpcErr("operation-not-supported", "unsupported"), false
+	}
+	return "<ok/>", false
+}
+
+func TestSessionCandidateWorkflow(t *testing.T) {
+	srv := newFakeServer(t)
+	cfg := Config{User: "admin", Password: "admin", Port: srv.port()}
+	dev := devices.Device{MGMTAddress: "127.0.0.1/24"}
+
+	s, err := DialDevice(dev, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 1 || !s.HasCapability(CapCandidate) || !s.f.chunked {
+		t.Fatalf("hello not negotiated: id=%d caps=%q chunked=%v", s.ID, s.Capabilities, s.f.chunked)
+	}
+
+	got, err := s.GetConfig(Running, `<system/>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "<hostname>leaf1</hostname>") || srv.lastFilter != "<system/>" {
+		t.Fatalf("get-config = %q (filter %q)", got, srv.lastFilter)
+	}
+
+	if err := s.Lock(Candidate); err != nil {
+		t.Fatal(err)
+	}
+	other, err := Dial("127.0.0.1", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rerr RPCError
+	if err := other.Lock(Candidate); !errors.As(err, &rerr) || rerr.Tag != "lock-denied" {
+		t.Fatalf("second lock: got %v, want lock-denied", err)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.EditConfig(Candidate, `<system><hostname>leaf1-new</hostname></system>`, EditOptions{DefaultOperation: "merge"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(Candidate); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetConfig(Running, ""); !strings.Contains(got, "leaf1-new") {
+		t.Fatalf("running after commit = %q", got)
+	}
+
+	if err := s.EditConfig(Candidate, `<bogus/>`, EditOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(Candidate); err == nil {
+		t.Fatal("validate accepted a bogus candidate")
+	}
+	if err := s.DiscardChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetConfig(Candidate, ""); !strings.Contains(got, "leaf1-new") {
+		t.Fatalf("candidate after discard = %q", got)
+	}
+
+	state, err := s.Get(`<state/>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(state, "<uptime>42</uptime>") {
+		t.Fatalf("get = %q", state)
+	}
+	if err := s.Unlock(Candidate); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFramerRoundTrip(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		r, w := net.Pipe()
+		tx := newFramer(nil, w)
+		rx := newFramer(r, nil)
+		tx.chunked, rx.chunked = chunked, chunked
+		go func() {
+			tx.writeMsg([]byte("<rpc>first</rpc>"))
+			tx.writeMsg([]byte("<rpc>second ]]> with marker-ish text</rpc>"))
+		}()
+		for _, want := range []string{"<rpc>first</rpc>", "<rpc>second ]]> with marker-ish text</rpc>"} {
+			got, err := rx.readMsg()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("chunked=%v: got %q, want %q", chunked, got, want)
+			}
+		}
+		r.Close()
+		w.Close()
+	}
+}
@@ -0,0 +1,156 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Reply is a parsed <rpc-reply>.
+type Reply struct {
+	XMLName   xml.Name   `xml:"rpc-reply"`
+	MessageID string     `xml:"message-id,attr"`
+	OK        *struct{}  `xml:"ok"`
+	Errors    []RPCError `xml:"rpc-error"`
+	Data      struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"data"`
+}
+
+// RPCError is one <rpc-error> returned by the server.
+type RPCError struct {
+	Type     string `xml:"error-type"`
+	Tag      string `xml:"error-tag"`
+	Severity string `xml:"error-severity"`
+	Path     string `xml:"error-path"`
+	Message  string `xml:"error-message"`
+}
+
+func (e RPCError) Error() string {
+	msg := fmt.Sprintf("netconf %s error %s", e.Type, e.Tag)
+	if e.Message != "" {
+		msg += ": " + strings.TrimSpace(e.Message)
+	}
+	if e.Path != "" {
+		msg += " (" + strings.TrimSpace(e.Path) + ")"
+	}
+	return msg
+}
+
+// Exec sends one operation wrapped in an <rpc> and waits for its reply.
+// Errors of severity "error" are returned as RPCError; warnings stay in
+// the reply.
+func (s *Session) Exec(op string) (*Reply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgID++
+	id := fmt.Sprint(s.msgID)
+	msg := fmt.Sprintf(`<rpc message-id="%s" xmlns="%s">%s</rpc>`, id, baseNS, op)
+	if err := s.f.writeMsg([]byte(msg)); err != nil {
+		return nil, fmt.Errorf("netconf: send rpc: %w", err)
+	}
+	raw, err := s.f.readMsg()
+	if err != nil {
+		return nil, fmt.Errorf("netconf: read reply: %w", err)
+	}
+	var r Reply
+	if err := xml.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("netconf: parse reply: %w", err)
+	}
+	if r.MessageID != id {
+		return nil, fmt.Errorf("netconf: reply for message %q, want %q", r.MessageID, id)
+	}
+	for _, e := range r.Errors {
+		if e.Severity != "warning" {
+			return &r, e
+		}
+	}
+	return &r, nil
+}
+
+// Get retrieves running configuration and state data. filter is an
+// optional subtree filter body, e.g. `<interfaces xmlns="..."/>`.
+func (s *Session) Get(filter string) (string, error) {
+	r, err := s.Exec("<get>" + subtree(filter) + "</get>")
+	if err != nil {
+		return "", err
+	}
+	return r.Data.Inner, nil
+}
+
+// GetConfig retrieves configuration from source, optionally narrowed by a
+// subtree filter.
+func (s *Session) GetConfig(source Datastore, filter string) (string, error) {
+	r, err := s.Exec("<get-config>" + datastore("source", source) + subtree(filter) + "</get-config>")
+	if err != nil {
+		return "", err
+	}
+	return r.Data.Inner, nil
+}
+
+// EditOptions tunes an edit-config.
+type EditOptions struct {
+	DefaultOperation string // merge (server default), replace or none
+	TestOption       string // test-then-set, set or test-only
+	ErrorOption      string // stop-on-error, continue-on-error or rollback-on-error
+}
+
+// EditConfig loads config (the children of <config>) into target.
+func (s *Session) EditConfig(target Datastore, config string, opts EditOptions) error {
+	var b strings.Builder
+	b.WriteString("<edit-config>")
+	b.WriteString(datastore("target", target))
+	if opts.DefaultOperation != "" {
+		b.WriteString("<default-operation>" + opts.DefaultOperation + "</default-operation>")
+	}
+	if opts.TestOption != "" {
+		b.WriteString("<test-option>" + opts.TestOption + "</test-option>")
+	}
+	if opts.ErrorOption != "" {
+		b.WriteString("<error-option>" + opts.ErrorOption + "</error-option>")
+	}
+	b.WriteString("<config>" + config + "</config></edit-config>")
+	_, err := s.Exec(b.String())
+	return err
+}
+
+// Validate checks the contents of source without applying it.
+func (s *Session) Validate(source Datastore) error {
+	_, err := s.Exec("<validate>" + datastore("source", source) + "</validate>")
+	return err
+}
+
+// Commit copies the candidate datastore to running.
+func (s *Session) Commit() error {
+	_, err := s.Exec("<commit/>")
+	return err
+}
+
+// DiscardChanges reverts the candidate datastore to running.
+func (s *Session) DiscardChanges() error {
+	_, err := s.Exec("<discard-changes/>")
+	return err
+}
+
+// Lock takes the datastore lock for this session.
+func (s *Session) Lock(target Datastore) error {
+	_, err := s.Exec("<lock>" + datastore("target", target) + "</lock>")
+	return err
+}
+
+// Unlock releases a lock taken with Lock.
+func (s *Session) Unlock(target Datastore) error {
+	_, err := s.Exec("<unlock>" + datastore("target", target) + "</unlock>")
+	return err
+}
+
+func datastore(elem string, ds Datastore) string {
+	return fmt.Sprintf("<%s><%s/></%s>", elem, ds, elem)
+}
+
+func subtree(filter string) string {
+	if strings.TrimSpace(filter) == "" {
+		return ""
+	}
+	return `<filter type="subtree">` + filter + `</filter>`
+}
@@ -0,0 +1,262 @@
+// Package netmath holds the address arithmetic the renderer templates and
+// the fabric tooling need: host and offset math on prefixes, /31 peers,
+// IS-IS NETs, BGP route distinguishers and targets, VLAN to VNI mapping and
+// EOS/Linux interface names.
+package netmath
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ISISArea is the area prefix of the NETs built by ISISNet; it matches the
+// one auto_lab uses.
+const ISISArea = "49.0001"
+
+// VNIBase is added to a VLAN ID to get its VNI: VLAN 10 is VNI 1010.
+const VNIBase = 1000
+
+// parse accepts "10.0.0.1" as well as "10.0.0.1/32". bits is -1 when s
+// has no prefix length.
+func parse(s string) (netip.Addr, int, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Addr{}, 0, err
+		}
+		return p.Addr(), p.Bits(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, 0, err
+	}
+	return a, -1, nil
+}
+
+// format writes a back in the shape it was given: with "/bits" or without.
+func format(a netip.Addr, bits int) string {
+	if bits < 0 {
+		return a.String()
+	}
+	return netip.PrefixFrom(a, bits).String()
+}
+
+// add returns a shifted by n addresses, failing if it leaves the family.
+func add(a netip.Addr, n *big.Int) (netip.Addr, error) {
+	v := new(big.Int).SetBytes(a.AsSlice())
+	v.Add(v, n)
+	size := len(a.AsSlice())
+	if v.Sign() < 0 || v.BitLen() > size*8 {
+		return netip.Addr{}, fmt.Errorf("%s%+d is out of range", a, n)
+	}
+	buf := make([]byte, size)
+	v.FillBytes(buf)
+	out, _ := netip.AddrFromSlice(buf)
+	return out, nil
+}
+
+// Addr strips the prefix length: Addr("10.0.0.11/32") is "10.0.0.11".
+func Addr(s string) (string, error) {
+	a, _, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+	return a.String(), nil
+}
+
+// CIDRHost returns host number n of prefix, counting from the network
+// address; negative n counts back from the last address.
+// CIDRHost("10.1.1.0/31", 1) is "10.1.1.1".
+func CIDRHost(prefix string, n int) (string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return "", err
+	}
+	p = p.Masked()
+	hostBits := p.Addr().BitLen() - p.Bits()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+	idx := big.NewInt(int64(n))
+	if n < 0 {
+		idx.Add(idx, size)
+	}
+	if idx.Sign() < 0 || idx.Cmp(size) >= 0 {
+		return "", fmt.Errorf("prefix %s has no host %d", p, n)
+	}
+	a, err := add(p.Addr(), idx)
+	if err != nil {
+		return "", err
+	}
+	return a.String(), nil
+}
+
+// Offset shifts an address by n and keeps its prefix length, if any:
+// Offset("10.0.0.11/32", 1) is "10.0.0.12/32".
+func Offset(s string, n int) (string, error) {
+	a, bits, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+	out, err := add(a, big.NewInt(int64(n)))
+	if err != nil {
+		return "", err
+	}
+	return format(out, bits), nil
+}
+
+// P2PPeer returns the other end of a point-to-point link: the partner in a
+// /31 (or /127), or the other usable host of a /30 (or /126). A bare
+// address is taken as a /31. The result has the same shape as the input.
+func P2PPeer(s string) (string, error) {
+	a, bits, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+	width := a.BitLen() - bits
+	if bits < 0 {
+		width = 1
+	}
+	last := a.AsSlice()[len(a.AsSlice())-1]
+	var peer netip.Addr
+	switch width {
+	case 1:
+		if last%2 == 0 {
+			peer, err = add(a, big.NewInt(1))
+		} else {
+			peer, err = add(a, big.NewInt(-1))
+		}
+	case 2:
+		switch last % 4 {
+		case 1:
+			peer, err = add(a, big.NewInt(1))
+		case 2:
+			peer, err = add(a, big.NewInt(-1))
+		default:
+			return "", fmt.Errorf("%s is not a host address of its /%d", s, bits)
+		}
+	default:
+		return "", fmt.Errorf("%s is not a point-to-point prefix", s)
+	}
+	if err != nil {
+		return "", err
+	}
+	return format(peer, bits), nil
+}
+
+// ISISNet derives an IS-IS NET from an IPv4 loopback the way auto_lab's
+// convert_to_isis_net does: each octet as two hex digits, grouped in
+// fours, e.g. ISISNet("10.0.0.11/32") is "49.0001.0A00.000B.00".
+func ISISNet(loopback string) (string, error) {
+	a, _, err := parse(loopback)
+	if err != nil {
+		return "", err
+	}
+	if !a.Is4() {
+		return "", fmt.Errorf("IS-IS NET needs an IPv4 loopback, got %s", loopback)
+	}
+	o := a.As4()
+	return fmt.Sprintf("%s.%02X%02X.%02X%02X.00", ISISArea, o[0], o[1], o[2], o[3]), nil
+}
+
+// extCommunity formats "<admin>:<n>" for route distinguishers and targets.
+// admin is an IPv4 address (with n up to 65535), a 2-byte ASN (n up to
+// 2^32-1) or a 4-byte ASN (n up to 65535).
+func extCommunity(admin string, n int) (string, error) {
+	if a, _, err := parse(admin); err == nil {
+		if !a.Is4() {
+			return "", fmt.Errorf("%s is not an IPv4 address", admin)
+		}
+		if n < 0 || n > 0xFFFF {
+			return "", fmt.Errorf("assigned number %d does not fit with an IPv4 administrator", n)
+		}
+		return fmt.Sprintf("%s:%d", a, n), nil
+	}
+	asn, err := strconv.ParseUint(admin, 10, 32)
+	if err != nil || asn == 0 {
+		return "", fmt.Errorf("%q is neither an IPv4 address nor an ASN", admin)
+	}
+	limit := int64(0xFFFFFFFF)
+	if asn > 0xFFFF {
+		limit = 0xFFFF
+	}
+	if n < 0 || int64(n) > limit {
+		return "", fmt.Errorf("assigned number %d does not fit with ASN %d", n, asn)
+	}
+	return fmt.Sprintf("%d:%d", asn, n), nil
+}
+
+// RD formats a route distinguisher from a router ID or ASN and a local
+// number: RD("10.0.0.11", 10) is "10.0.0.11:10".
+func RD(admin string, n int) (string, error) {
+	return extCommunity(admin, n)
+}
+
+// RouteTarget formats a route target from an ASN and a number, usually the
+// VNI: RouteTarget(65000, 1010) is "65000:1010".
+func RouteTarget(asn, n int) (string, error) {
+	return extCommunity(strconv.Itoa(asn), n)
+}
+
+// VNI maps a VLAN to its VXLAN network identifier, VNIBase+vlan unless a
+// different base is given.
+func VNI(vlan int, base ...int) (int, error) {
+	if vlan < 1 || vlan > 4094 {
+		return 0, fmt.Errorf("VLAN %d is out of range", vlan)
+	}
+	b := VNIBase
+	if len(base) > 0 {
+		b = base[0]
+	}
+	vni := b + vlan
+	if vni < 1 || vni > 1<<24-1 {
+		return 0, fmt.Errorf("VNI %d is out of range", vni)
+	}
+	return vni, nil
+}
+
+var (
+	linuxIntfRe = regexp.MustCompile(`^eth(\d+)(?:_(\d+))?$`)
+	eosIntfRe   = regexp.MustCompile(`^(?i:et|eth|ethernet)(\d+)(?:/(\d+))?$`)
+)
+
+// EOSInterface returns the EOS name of an interface given either way:
+// "eth1" (as in containerlab links) becomes "Ethernet1", "eth1_1"
+// "Ethernet1/1", "eth0" "Management0", and "et1" "Ethernet1". Other names
+// are returned unchanged.
+func EOSInterface(name string) string {
+	if m := linuxIntfRe.FindStringSubmatch(name); m != nil {
+		if m[1] == "0" && m[2] == "" {
+			return "Management0"
+		}
+		if m[2] != "" {
+			return "Ethernet" + m[1] + "/" + m[2]
+		}
+		return "Ethernet" + m[1]
+	}
+	if m := eosIntfRe.FindStringSubmatch(name); m != nil {
+		if m[2] != "" {
+			return "Ethernet" + m[1] + "/" + m[2]
+		}
+		return "Ethernet" + m[1]
+	}
+	return name
+}
+
+// LinuxInterface is the reverse of EOSInterface: "Ethernet1" becomes
+// "eth1", "Ethernet1/1" "eth1_1" and "Management0" "eth0".
+func LinuxInterface(name string) string {
+	eos := EOSInterface(name)
+	if strings.EqualFold(eos, "Management0") {
+		return "eth0"
+	}
+	if m := eosIntfRe.FindStringSubmatch(eos); m != nil {
+		if m[2] != "" {
+			return "eth" + m[1] + "_" + m[2]
+		}
+		return "eth" + m[1]
+	}
+	return name
+}
@@ -0,0 +1,157 @@
+package netmath
+
+import "testing"
+
+func TestAddressMath(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func() (string, error)
+		want    string
+		wantErr bool
+	}{
+		{"addr strips length", func() (string, error) { return Addr("10.0.0.11/32") }, "10.0.0.11", false},
+		{"addr bare", func() (string, error) { return Addr("10.0.0.11") }, "10.0.0.11", false},
+		{"addr bad", func() (string, error) { return Addr("10.0.0") }, "", true},
+		{"host 0", func() (string, error) { return CIDRHost("10.1.1.0/31", 0) }, "10.1.1.0", false},
+		{"host 1", func() (string, error) { return CIDRHost("10.1.1.0/31", 1) }, "10.1.1.1", false},
+		{"host unmasked", func() (string, error) { return CIDRHost("10.10.10.77/24", 101) }, "10.10.10.101", false},
+		{"host last", func() (string, error) { return CIDRHost("172.20.20.0/24", -1) }, "172.20.20.255", false},
+		{"host out of range", func() (string, error) { return CIDRHost("10.1.1.0/31", 2) }, "", true},
+		{"host v6", func() (string, error) { return CIDRHost("fd00::/64", 17) }, "fd00::11", false},
+		{"host v6 /64 last", func() (string, error) { return CIDRHost("2001:db8::/64", -1) }, "2001:db8::ffff:ffff:ffff:ffff", false},
+		{"host v6 /64 first from the end", func() (string, error) { return CIDRHost("2001:db8::/64", -1<<63) }, "2001:db8:0:0:8000::", false},
+		{"host v6 /48 last", func() (string, error) { return CIDRHost("2001:db8:1::/48", -2) }, "2001:db8:1:ffff:ffff:ffff:ffff:fffe", false},
+		{"host v6 /127 out of range", func() (string, error) { return CIDRHost("fd00::/127", -3) }, "", true},
+		{"offset keeps length", func() (string, error) { return Offset("10.0.0.11/32", 1) }, "10.0.0.12/32", false},
+		{"offset carries", func() (string, error) { return Offset("10.0.0.255", 1) }, "10.0.1.0", false},
+		{"offset negative", func() (string, error) { return Offset("10.0.1.0", -1) }, "10.0.0.255", false},
+		{"offset overflow", func() (string, error) { return Offset("255.255.255.255", 1) }, "", true},
+		{"peer /31 even", func() (string, error) { return P2PPeer("10.1.1.0/31") }, "10.1.1.1/31", false},
+		{"peer /31 odd", func() (string, error) { return P2PPeer("10.1.1.1/31") }, "10.1.1.0/31", false},
+		{"peer bare", func() (string, error) { return P2PPeer("10.1.2.1") }, "10.1.2.0", false},
+		{"peer /30", func() (string, error) { return P2PPeer("192.168.0.5/30") }, "192.168.0.6/30", false},
+		{"peer /30 network", func() (string, error) { return P2PPeer("192.168.0.4/30") }, "", true},
+		{"peer /24", func() (string, error) { return P2PPeer("10.10.10.1/24") }, "", true},
+		{"peer /127", func() (string, error) { return P2PPeer("fd00::1/127") }, "fd00::/127", false},
+	}
+	for _, tt := range tests {
+		got, err := tt.fn()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestISISNet(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"10.0.0.11/32", "49.0001.0A00.000B.00", false},
+		{"10.0.0.1", "49.0001.0A00.0001.00", false},
+		{"192.168.255.254", "49.0001.C0A8.FFFE.00", false},
+		{"fd00::1", "", true},
+		{"leaf1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ISISNet(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ISISNet(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestRDAndRouteTarget(t *testing.T) {
+	tests := []struct {
+		admin   string
+		n       int
+		want    string
+		wantErr bool
+	}{
+		{"10.0.0.11", 10, "10.0.0.11:10", false},
+		{"10.0.0.11/32", 10, "10.0.0.11:10", false},
+		{"10.0.0.11", 70000, "", true},
+		{"65000", 1010, "65000:1010", false},
+		{"65000", 100000, "65000:100000", false},
+		{"4200000001", 1010, "4200000001:1010", false},
+		{"4200000001", 70000, "", true},
+		{"0", 1, "", true},
+		{"fd00::1", 1, "", true},
+		{"spine", 1, "", true},
+	}
+	for _, tt := range tests {
+		got, err := RD(tt.admin, tt.n)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("RD(%q, %d) = %q, %v; want %q", tt.admin, tt.n, got, err, tt.want)
+		}
+	}
+	if got, err := RouteTarget(65000, 1010); err != nil || got != "65000:1010" {
+		t.Errorf("RouteTarget(65000, 1010) = %q, %v", got, err)
+	}
+	if _, err := RouteTarget(-1, 10); err == nil {
+		t.Error("RouteTarget accepted a negative ASN")
+	}
+}
+
+func TestVNI(t *testing.T) {
+	tests := []struct {
+		vlan    int
+		base    []int
+		want    int
+		wantErr bool
+	}{
+		{10, nil, 1010, false},
+		{4094, nil, 5094, false},
+		{10, []int{10000}, 10010, false},
+		{0, nil, 0, true},
+		{4095, nil, 0, true},
+		{10, []int{1 << 24}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := VNI(tt.vlan, tt.base...)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("VNI(%d, %v) = %d, %v; want %d", tt.vlan, tt.base, got, err, tt.want)
+		}
+	}
+}
+
+func TestInterfaceNames(t *testing.T) {
+	tests := []struct {
+		linux, eos string
+	}{
+		{"eth1", "Ethernet1"},
+		{"eth12", "Ethernet12"},
+		{"eth1_1", "Ethernet1/1"},
+		{"eth0", "Management0"},
+	}
+	for _, tt := range tests {
+		if got := EOSInterface(tt.linux); got != tt.eos {
+			t.Errorf("EOSInterface(%q) = %q, want %q", tt.linux, got, tt.eos)
+		}
+		if got := LinuxInterface(tt.eos); got != tt.linux {
+			t.Errorf("LinuxInterface(%q) = %q, want %q", tt.eos, got, tt.linux)
+		}
+	}
+
+	aliases := map[string]string{
+		"Ethernet3":     "Ethernet3",
+		"et3":           "Ethernet3",
+		"Et3/1":         "Ethernet3/1",
+		"ethernet3":     "Ethernet3",
+		"Loopback0":     "Loopback0",
+		"Vxlan1":        "Vxlan1",
+		"Port-Channel1": "Port-Channel1",
+	}
+	for in, want := range aliases {
+		if got := EOSInterface(in); got != want {
+			t.Errorf("EOSInterface(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := LinuxInterface("Loopback0"); got != "Loopback0" {
+		t.Errorf("LinuxInterface(Loopback0) = %q", got)
+	}
+}
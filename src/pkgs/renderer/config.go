@@ -0,0 +1,25 @@
+package renderer
+
+import (
+	"fmt"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+)
+
+// RenderConfig renders the complete startup config of d with the template
+// for its role, "eos/spine" or "eos/leaf".
+func RenderConfig(d devices.Device) ([]byte, error) {
+	return Default().RenderConfig(d)
+}
+
+// RenderConfig renders the complete startup config of d from r.
+func (r *Registry) RenderConfig(d devices.Device) ([]byte, error) {
+	if d.Role == "" {
+		return nil, fmt.Errorf("device %q has no role", d.Hostname)
+	}
+	out, err := r.Render("eos/"+d.Role, d)
+	if err != nil {
+		return nil, fmt.Errorf("render %s: %w", d.Hostname, err)
+	}
+	return out, nil
+}
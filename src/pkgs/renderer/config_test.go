@@ -0,0 +1,72 @@
+package renderer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+)
+
+func loadFabric(t *testing.T) map[string]devices.Device {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "fabric.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fx struct {
+		Devices []devices.Device `json:"devices"`
+	}
+	if err := json.Unmarshal(b, &fx); err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]devices.Device{}
+	for _, d := range fx.Devices {
+		byName[d.Hostname] = d
+	}
+	return byName
+}
+
+// The rendered config has to be the checked-in one as a tree: every line
+// under the same parent, nothing missing either way.
+func TestRenderConfigMatchesStartupConfigs(t *testing.T) {
+	fabric := loadFabric(t)
+	for _, name := range []string{"leaf1", "spine1"} {
+		t.Run(name, func(t *testing.T) {
+			out, err := RenderConfig(fabric[name])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(string(out), "end\n") {
+				t.Errorf("config does not finish with end")
+			}
+			file, err := os.ReadFile(filepath.Join("..", "..", "..", "configs", name+".cfg"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := eosconfig.ParseString(string(file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := eosconfig.ParseString(string(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := eosconfig.Diff(want, got); len(d.Children) > 0 {
+				t.Errorf("%s.cfg needs these to become the rendered config:\n%s", name, d)
+			}
+			if d := eosconfig.Diff(got, want); len(d.Children) > 0 {
+				t.Errorf("the rendered config needs these to become %s.cfg:\n%s", name, d)
+			}
+		})
+	}
+}
+
+func TestRenderConfigNeedsRole(t *testing.T) {
+	if _, err := RenderConfig(devices.Device{Hostname: "x"}); err == nil {
+		t.Fatal("rendered a device without a role")
+	}
+}
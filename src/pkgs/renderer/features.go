@@ -0,0 +1,91 @@
+package renderer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// featurePrefix is where feature snippets live below templates/.
+const featurePrefix = "features/"
+
+// ISISInstance is the IS-IS instance the feature snippets configure, the
+// same one auto_lab uses.
+const ISISInstance = "ISIS_BASE"
+
+// FeatureData is what feature snippets are executed with: the device plus
+// the values derived from its Loopback0.
+type FeatureData struct {
+	devices.Device
+	Instance   string // IS-IS instance name
+	LoopbackIP string // Loopback0 without the prefix length
+	ISISNet    string // e.g. 49.0001.0A00.000B.00
+	NodeIndex  int    // SR node segment index, the last loopback octet
+}
+
+// NewFeatureData derives the feature values of d. It fails when d has no
+// IPv4 Loopback0 to build the NET from.
+func NewFeatureData(d devices.Device) (FeatureData, error) {
+	lo := d.Loopback(0)
+	if lo == "" {
+		return FeatureData{}, fmt.Errorf("device %q has no Loopback0", d.Hostname)
+	}
+	ip, err := netmath.Addr(lo)
+	if err != nil {
+		return FeatureData{}, fmt.Errorf("device %q: %w", d.Hostname, err)
+	}
+	isisNet, err := netmath.ISISNet(ip)
+	if err != nil {
+		return FeatureData{}, fmt.Errorf("device %q: %w", d.Hostname, err)
+	}
+	// ISISNet only accepts IPv4, so the last octet is always there
+	index, _ := strconv.Atoi(ip[strings.LastIndexByte(ip, '.')+1:])
+	return FeatureData{Device: d, Instance: ISISInstance, LoopbackIP: ip, ISISNet: isisNet, NodeIndex: index}, nil
+}
+
+// Features lists the feature snippets of the default registry.
+func Features() []string {
+	return Default().Features()
+}
+
+// Features lists the feature snippets, e.g. "isis", "mpls", "ipv6".
+func (r *Registry) Features() []string {
+	var out []string
+	for _, name := range r.Names() {
+		if strings.HasPrefix(name, featurePrefix) {
+			out = append(out, strings.TrimPrefix(name, featurePrefix))
+		}
+	}
+	return out
+}
+
+// RenderFeatures renders the named feature snippets for d from the default
+// registry.
+func RenderFeatures(d devices.Device, names ...string) ([]byte, error) {
+	return Default().RenderFeatures(d, names...)
+}
+
+// RenderFeatures renders the named feature snippets for d, in order, into
+// one config fragment that can be pasted or pushed on top of the running
+// config.
+func (r *Registry) RenderFeatures(d devices.Device, names ...string) ([]byte, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no features requested")
+	}
+	data, err := NewFeatureData(d)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	for _, name := range names {
+		b, err := r.Render(featurePrefix+name, data)
+		if err != nil {
+			return nil, fmt.Errorf("feature %s for %s: %w", name, d.Hostname, err)
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
@@ -0,0 +1,48 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+)
+
+func TestRenderFeatures(t *testing.T) {
+	leaf1 := loadFabric(t)["leaf1"]
+	if got := strings.Join(Features(), ","); got != "ipv6,isis,mpls" {
+		t.Fatalf("features = %s", got)
+	}
+	out, err := RenderFeatures(leaf1, "isis", "mpls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := string(out)
+	for _, want := range []string{
+		"router isis ISIS_BASE\n   net 49.0001.0A00.000B.00\n",
+		"interface Ethernet1\n   isis enable ISIS_BASE\n   isis network point-to-point\n",
+		"interface Ethernet2\n   isis enable ISIS_BASE\n",
+		"node-segment ipv4 index 11\n",
+		"interface Ethernet2\n   mpls ldp interface\n",
+	} {
+		if !strings.Contains(cfg, want) {
+			t.Errorf("missing %q in\n%s", want, cfg)
+		}
+	}
+	// the access port is not a fabric link
+	if strings.Contains(cfg, "interface Ethernet3") {
+		t.Errorf("access port got underlay config:\n%s", cfg)
+	}
+}
+
+func TestRenderFeaturesErrors(t *testing.T) {
+	leaf1 := loadFabric(t)["leaf1"]
+	if _, err := RenderFeatures(leaf1); err == nil {
+		t.Error("rendered without features")
+	}
+	if _, err := RenderFeatures(leaf1, "ospf"); err == nil {
+		t.Error("rendered an unknown feature")
+	}
+	if _, err := RenderFeatures(devices.Device{Hostname: "gpu1"}, "isis"); err == nil {
+		t.Error("rendered a device without Loopback0")
+	}
+}
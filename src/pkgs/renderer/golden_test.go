@@ -0,0 +1,123 @@
+package renderer
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/textdiff"
+)
+
+// update rewrites the golden files from the current templates:
+//
+//	go test ./pkgs/renderer -run TestGolden -update
+var update = flag.Bool("update", false, "rewrite testdata/golden from the current templates")
+
+// partials only hold {{ define }}s and render nothing on their own.
+var partials = map[string]bool{"eos/common": true}
+
+// goldenCase is one template rendered with one piece of fixture data.
+type goldenCase struct {
+	name string // file name below the template's golden dir
+	data any
+}
+
+// goldenCases picks the fixture data for template name from the devices of
+// one inventory.
+func goldenCases(t *testing.T, name string, devs []devices.Device) []goldenCase {
+	var cases []goldenCase
+	switch {
+	case name == "eapi_payload":
+		cases = append(cases,
+			goldenCase{"show-version", PayloadData{Method: "runCmds", Version: 1, Format: "json", Cmds: []string{"show version"}, ID: 1}},
+			goldenCase{"configure", PayloadData{Method: "runCmds", Version: 1, Format: "text", Cmds: []string{"enable", "configure", "vlan 20", "end"}, ID: 7}},
+		)
+	case strings.HasPrefix(name, "eos/"):
+		role := strings.TrimPrefix(name, "eos/")
+		for _, d := range devs {
+			if d.Role == role {
+				cases = append(cases, goldenCase{d.Hostname, d})
+			}
+		}
+	case strings.HasPrefix(name, featurePrefix):
+		for _, d := range devs {
+			if d.Loopback(0) == "" {
+				continue
+			}
+			data, err := NewFeatureData(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cases = append(cases, goldenCase{d.Hostname, data})
+		}
+	}
+	return cases
+}
+
+// TestGolden renders every template with every fixture inventory under
+// testdata and compares the result with
+// testdata/golden/<inventory>/<template>/<case>.golden.
+func TestGolden(t *testing.T) {
+	inventories, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(inventories) == 0 {
+		t.Fatalf("no fixture inventories: %v", err)
+	}
+	reg, err := NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]bool{}
+	for _, inv := range inventories {
+		devs, err := devices.LoadFile(inv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		invName := strings.TrimSuffix(filepath.Base(inv), ".json")
+		for _, name := range reg.Names() {
+			if partials[name] {
+				continue
+			}
+			cases := goldenCases(t, name, devs)
+			covered[name] = covered[name] || len(cases) > 0
+			for _, c := range cases {
+				t.Run(invName+"/"+name+"/"+c.name, func(t *testing.T) {
+					got, err := reg.Render(name, c.data)
+					if err != nil {
+						t.Fatal(err)
+					}
+					checkGolden(t, filepath.Join("testdata", "golden", invName, name, c.name+".golden"), string(got))
+				})
+			}
+		}
+	}
+	for _, name := range reg.Names() {
+		if !partials[name] && !covered[name] {
+			t.Errorf("template %s has no golden cases; add them to goldenCases", name)
+		}
+	}
+}
+
+// checkGolden compares got with the golden file at path, or rewrites the
+// file with -update.
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if d := textdiff.Unified(path, "rendered", string(want), got, 3); d != "" {
+		t.Errorf("output differs from golden file (run with -update to accept):\n%s", d)
+	}
+}
@@ -0,0 +1,264 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// builtinFS holds the templates shipped with the binary. A template is
+// named after its path below templates/ without the .tmpl extension, e.g.
+// templates/eapi_payload.tmpl is "eapi_payload".
+//
+//go:embed templates
+var builtinFS embed.FS
+
+// tmplExt is the extension of template files, built-in or overriding.
+const tmplExt = ".tmpl"
+
+// Registry parses templates once and renders them by name. Templates found
+// in an optional override directory shadow built-ins of the same name, and
+// can be reloaded while the process runs.
+type Registry struct {
+	overrideDir string
+
+	mu    sync.RWMutex
+	set   *template.Template
+	names []string
+	stamp string // fingerprint of the override dir at the last load
+}
+
+// NewRegistry parses the built-in templates plus those under overrideDir
+// ("" for none).
+func NewRegistry(overrideDir string) (*Registry, error) {
+	r := &Registry{overrideDir: overrideDir}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+var (
+	defaultMu  sync.RWMutex
+	defaultReg *Registry // built-ins only, loaded on first use
+)
+
+func mustBuiltin() *Registry {
+	r, err := NewRegistry("")
+	if err != nil {
+		panic(fmt.Sprintf("renderer: built-in templates: %v", err))
+	}
+	return r
+}
+
+// Default returns the registry used by the package-level Render.
+func Default() *Registry {
+	defaultMu.RLock()
+	r := defaultReg
+	defaultMu.RUnlock()
+	if r != nil {
+		return r
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultReg == nil {
+		defaultReg = mustBuiltin()
+	}
+	return defaultReg
+}
+
+// SetDefault replaces the registry used by the package-level Render, e.g.
+// with one that has an override directory.
+func SetDefault(r *Registry) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultReg = r
+}
+
+// Render executes the named template with data.
+func (r *Registry) Render(name string, data any) ([]byte, error) {
+	start := time.Now()
+	r.mu.RLock()
+	set := r.set
+	r.mu.RUnlock()
+	tpl := set.Lookup(name)
+	if tpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("execute template: %w", err)
+	}
+	slog.Debug("rendered template", "template", name, "duration", time.Since(start))
+	return buf.Bytes(), nil
+}
+
+// Names lists the registered templates in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.names...)
+}
+
+// loaded is one parse of the built-in and override templates.
+type loaded struct {
+	set     *template.Template
+	names   []string
+	samples map[string]any // from <name>.sample.json in the override dir
+	stamp   string
+}
+
+// load reads and parses the built-in templates plus those under
+// overrideDir, without validating them.
+func load(overrideDir string) (*loaded, error) {
+	sources := map[string]string{}
+	if err := collect(builtinFS, "templates", sources); err != nil {
+		return nil, fmt.Errorf("built-in templates: %w", err)
+	}
+	l := &loaded{samples: map[string]any{}}
+	if overrideDir != "" {
+		var err error
+		if l.stamp, err = fingerprint(overrideDir); err != nil {
+			return nil, fmt.Errorf("override templates: %w", err)
+		}
+		if err := collect(os.DirFS(overrideDir), ".", sources); err != nil {
+			return nil, fmt.Errorf("override templates: %w", err)
+		}
+		if err := collectSamples(os.DirFS(overrideDir), l.samples); err != nil {
+			return nil, fmt.Errorf("override samples: %w", err)
+		}
+	}
+
+	for name := range sources {
+		l.names = append(l.names, name)
+	}
+	sort.Strings(l.names)
+	// missingkey=error: a key absent from map data fails instead of
+	// rendering "<no value>"
+	l.set = template.New("").Funcs(funcs).Option("missingkey=error")
+	for _, name := range l.names {
+		if _, err := l.set.New(name).Parse(sources[name]); err != nil {
+			return nil, fmt.Errorf("parse template: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// Reload re-reads the override directory, re-parses every template and
+// validates them against their sample data. On error the previously
+// loaded templates stay in use; validation failures are a
+// *ValidationError.
+func (r *Registry) Reload() error {
+	l, err := load(r.overrideDir)
+	if err != nil {
+		return err
+	}
+	if problems := l.validate(); Failed(problems) {
+		return &ValidationError{Problems: problems}
+	}
+	r.mu.Lock()
+	r.set, r.names, r.stamp = l.set, l.names, l.stamp
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch polls the override directory every interval and reloads the
+// templates when a file was added, removed or modified. It returns when
+// ctx is done.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	if r.overrideDir == "" {
+		return
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		stamp, err := fingerprint(r.overrideDir)
+		r.mu.RLock()
+		same := stamp == r.stamp
+		r.mu.RUnlock()
+		if err != nil || same {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			slog.Error("template reload failed, keeping previous templates", "dir", r.overrideDir, "err", err)
+			// remember the broken state so we don't retry until it changes again
+			r.mu.Lock()
+			r.stamp = stamp
+			r.mu.Unlock()
+			continue
+		}
+		slog.Info("templates reloaded", "dir", r.overrideDir)
+	}
+}
+
+// collect reads every *.tmpl below root in fsys into sources, keyed by
+// template name.
+func collect(fsys fs.FS, root string, sources map[string]string) error {
+	return fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, tmplExt) {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		sources[strings.TrimSuffix(path.Clean(rel), tmplExt)] = string(b)
+		return nil
+	})
+}
+
+// collectSamples reads every *.sample.json in fsys into samples, keyed by
+// the name of the template it belongs to.
+func collectSamples(fsys fs.FS, samples map[string]any) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, sampleExt) {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		samples[strings.TrimSuffix(path.Clean(p), sampleExt)] = v
+		return nil
+	})
+}
+
+// fingerprint summarises names, sizes and mtimes of the templates and
+// samples in dir.
+func fingerprint(dir string) (string, error) {
+	var b strings.Builder
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !(strings.HasSuffix(p, tmplExt) || strings.HasSuffix(p, sampleExt)) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return b.String(), err
+}
@@ -0,0 +1,84 @@
+package renderer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryOverrideShadowsBuiltin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "eapi_payload.tmpl"), []byte(`override {{ .Method }}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "extra"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "extra", "hello.tmpl"), []byte(`hello {{ toJSON .Cmds }}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(reg.Names(), ","); !strings.Contains(got, "eapi_payload") || !strings.Contains(got, "extra/hello") {
+		t.Fatalf("names = %s", got)
+	}
+	out, err := reg.Render("eapi_payload", PayloadData{Method: "runCmds"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "override runCmds" {
+		t.Errorf("override not used: %q", out)
+	}
+	out, err = reg.Render("extra/hello", PayloadData{Cmds: []string{"show version"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `hello ["show version"]` {
+		t.Errorf("got %q", out)
+	}
+	if _, err := reg.Render("missing", nil); err == nil {
+		t.Error("rendered a template that does not exist")
+	}
+}
+
+func TestRegistryWatchReloads(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "note.tmpl")
+	if err := os.WriteFile(file, []byte(`v1`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reg.Watch(ctx, 5*time.Millisecond)
+
+	// a broken edit keeps the previous version
+	if err := os.WriteFile(file, []byte(`{{ .Broken `), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if out, err := reg.Render("note", nil); err != nil || string(out) != "v1" {
+		t.Fatalf("after broken edit: %q, %v", out, err)
+	}
+
+	if err := os.WriteFile(file, []byte(`v2, longer`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if out, _ := reg.Render("note", nil); string(out) == "v2, longer" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("template was not reloaded")
+}
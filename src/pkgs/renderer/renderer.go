@@ -1,13 +1,10 @@
 package renderer
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
-	"path/filepath"
 	"text/template"
 
-	// "github.com/montybeatnik/arista-lab/laber/pkgs/arista"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
 )
 
 // PayloadData represents (Aritsa) payload
@@ -19,21 +16,28 @@ type PayloadData struct {
 	ID      int
 }
 
-func RenderTemplate(tplPath string, data PayloadData) ([]byte, error) {
-	funcs := template.FuncMap{
-		"toJSON": func(v any) (string, error) {
-			b, err := json.Marshal(v)
-			return string(b), err
-		},
-	}
-	base := filepath.Base(tplPath)
-	tpl, err := template.New(base).Funcs(funcs).ParseFiles(tplPath)
-	if err != nil {
-		return nil, fmt.Errorf("parse template: %w", err)
-	}
-	var buf bytes.Buffer
-	if err := tpl.ExecuteTemplate(&buf, base, data); err != nil {
-		return nil, fmt.Errorf("execute template: %w", err)
-	}
-	return buf.Bytes(), nil
+// funcs is the function map every template is parsed with. Besides toJSON
+// it carries the netmath helpers, e.g. {{ isisNet .Loopback }} or
+// {{ rt 65000 (vni 10) }}.
+var funcs = template.FuncMap{
+	"toJSON": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"ipAddr":    netmath.Addr,
+	"cidrHost":  netmath.CIDRHost,
+	"ipOffset":  netmath.Offset,
+	"p2pPeer":   netmath.P2PPeer,
+	"isisNet":   netmath.ISISNet,
+	"rd":        netmath.RD,
+	"rt":        netmath.RouteTarget,
+	"vni":       netmath.VNI,
+	"eosIntf":   netmath.EOSInterface,
+	"linuxIntf": netmath.LinuxInterface,
+}
+
+// Render renders the named template from the default registry, e.g.
+// Render("eapi_payload", PayloadData{...}).
+func Render(name string, data any) ([]byte, error) {
+	return Default().Render(name, data)
 }
@@ -1,24 +1,71 @@
 package renderer
 
 import (
-	"fmt"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
-
-	"github.com/montybeatnik/arista-lab/laber/pkgs/arista"
 )
 
 func TestRenderTemplate(t *testing.T) {
-	tmplPath := "../../templates/eapi_payload.tmpl"
 	cmds := []string{"show bgp evpn summary"}
-	payload := arista.PayloadData{
+	payload := PayloadData{
 		Method:  "runCmds",
 		Version: 1,
 		Format:  "json",
 		Cmds:    cmds,
 	}
-	body, err := RenderTemplate(tmplPath, payload)
+	body, err := Render("eapi_payload", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+		Params  struct {
+			Version int      `json:"version"`
+			Format  string   `json:"format"`
+			Cmds    []string `json:"cmds"`
+		} `json:"params"`
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("payload is not JSON: %v\n%s", err, body)
+	}
+	if got.JSONRPC != "2.0" || got.Method != "runCmds" || got.Params.Version != 1 || got.Params.Format != "json" || got.ID != 0 {
+		t.Errorf("unexpected payload %+v", got)
+	}
+	if !reflect.DeepEqual(got.Params.Cmds, cmds) {
+		t.Errorf("cmds = %q, want %q", got.Params.Cmds, cmds)
+	}
+}
+
+func TestNetmathFuncs(t *testing.T) {
+	dir := t.TempDir()
+	src := `{{ isisNet "10.0.0.11/32" }} {{ p2pPeer "10.1.1.0/31" }} {{ rd (ipAddr "10.0.0.11/32") 10 }} {{ rt 65000 (vni 10) }} {{ eosIntf "eth3" }}`
+	if err := os.WriteFile(filepath.Join(dir, "math.tmpl"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := reg.Render("math", nil)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	want := "49.0001.0A00.000B.00 10.1.1.1/31 10.0.0.11:10 65000:1010 Ethernet3"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.tmpl"), []byte(`{{ p2pPeer "10.10.10.0/24" }}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Render("bad", nil); err == nil {
+		t.Error("a failing helper did not fail the render")
 	}
-	fmt.Println(string(body))
 }
@@ -0,0 +1,84 @@
+package renderer
+
+import (
+	"strings"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+)
+
+// sampleExt is the extension of the JSON sample data an override template
+// can ship next to itself, e.g. extra/hello.sample.json for
+// extra/hello.tmpl.
+const sampleExt = ".sample.json"
+
+// sampleLeaf and sampleSpine exercise every branch of the eos/ templates.
+func sampleLeaf() devices.Device {
+	return devices.Device{
+		Hostname:  "leaf1",
+		Role:      "leaf",
+		Users:     []devices.User{{Name: "admin", Privilege: 15, Secret: "admin"}},
+		Loopbacks: []devices.Loopback{{ID: 0, Address: "10.0.0.11/32"}, {ID: 1, Address: "10.255.0.11/32"}},
+		Interfaces: []devices.Interface{
+			{Name: "Ethernet1", Address: "172.16.1.1/31"},
+			{Name: "Ethernet2", Address: "172.16.2.1/31", VRF: "default"},
+			{Name: "Ethernet3", VLAN: 10, PortFast: true},
+			{Name: "Vlan10", Gateway: "10.10.10.1/24", VRF: "TENANT-A"},
+		},
+		VLANs:            []devices.VLAN{{ID: 10, Name: "gpu", VNI: 1010, RD: "10.0.0.11:10", RouteTarget: "65000:1010"}},
+		VRFs:             []devices.VRF{{Name: "TENANT-A", VNI: 50001, RD: "10.0.0.11:50001", RouteTarget: "65000:50001"}},
+		VirtualRouterMAC: "00:1c:73:00:00:01",
+		VXLAN:            &devices.VXLAN{SourceInterface: "Loopback1"},
+		BGP: &devices.BGP{
+			ASN:      65101,
+			RouterID: "10.0.0.11",
+			Underlay: []devices.Neighbor{{Address: "172.16.1.0", RemoteAS: 65000}},
+			Overlay: &devices.PeerGroup{
+				Name:         "SPINES-EVPN",
+				UpdateSource: "Loopback0",
+				EBGPMultihop: 3,
+				Neighbors:    []devices.Neighbor{{Address: "10.0.0.1", RemoteAS: 65000}},
+			},
+		},
+		EAPI: true,
+	}
+}
+
+func sampleSpine() devices.Device {
+	return devices.Device{
+		Hostname:   "spine1",
+		Role:       "spine",
+		Users:      []devices.User{{Name: "admin", Privilege: 15, Secret: "admin"}},
+		Loopbacks:  []devices.Loopback{{ID: 0, Address: "10.0.0.1/32"}},
+		Interfaces: []devices.Interface{{Name: "Ethernet1", Address: "172.16.1.0/31"}},
+		BGP: &devices.BGP{
+			ASN:      65000,
+			RouterID: "10.0.0.1",
+			Underlay: []devices.Neighbor{{Address: "172.16.1.1", RemoteAS: 65101}},
+			Overlay: &devices.PeerGroup{
+				Name:         "EVPN-OVERLAY",
+				UpdateSource: "Loopback0",
+				EBGPMultihop: 3,
+				Neighbors:    []devices.Neighbor{{Address: "10.0.0.11", RemoteAS: 65101}},
+			},
+		},
+		Multicast: true,
+		EAPI:      true,
+	}
+}
+
+// builtinSample returns the data the built-in template name is validated
+// with.
+func builtinSample(name string) (any, bool) {
+	switch {
+	case name == "eapi_payload":
+		return PayloadData{Method: "runCmds", Version: 1, Format: "json", Cmds: []string{"show version"}, ID: 1}, true
+	case name == "eos/spine":
+		return sampleSpine(), true
+	case strings.HasPrefix(name, "eos/"):
+		return sampleLeaf(), true
+	case strings.HasPrefix(name, featurePrefix):
+		data, err := NewFeatureData(sampleLeaf())
+		return data, err == nil
+	}
+	return nil, false
+}
@@ -0,0 +1,315 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Problem is one finding of template validation.
+type Problem struct {
+	Template string `json:"template"`
+	Location string `json:"location,omitempty"` // "eos/leaf:12:5"
+	Message  string `json:"message"`
+	Warning  bool   `json:"warning,omitempty"` // reported, but doesn't fail validation
+}
+
+func (p Problem) String() string {
+	where := p.Template
+	if p.Location != "" {
+		where = p.Location
+	}
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", where, level, p.Message)
+}
+
+// ValidationError is returned by Reload when a template fails validation.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, p := range e.Problems {
+		if !p.Warning {
+			msgs = append(msgs, p.String())
+		}
+	}
+	return "invalid templates: " + strings.Join(msgs, "; ")
+}
+
+// Failed reports whether problems holds anything but warnings.
+func Failed(problems []Problem) bool {
+	for _, p := range problems {
+		if !p.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint loads the built-in templates plus those under overrideDir and
+// returns every problem found, warnings included. The error is only set
+// when the templates cannot be loaded or parsed at all.
+func Lint(overrideDir string) ([]Problem, error) {
+	l, err := load(overrideDir)
+	if err != nil {
+		return nil, err
+	}
+	return l.validate(), nil
+}
+
+// validate executes every template against its sample data and checks
+// statically that every field it references exists in that data, including
+// in branches the sample doesn't take.
+func (l *loaded) validate() []Problem {
+	var problems []Problem
+	for _, name := range l.names {
+		data, ok := l.samples[name]
+		if !ok {
+			data, ok = builtinSample(name)
+		}
+		if !ok {
+			problems = append(problems, Problem{
+				Template: name,
+				Message:  "no sample data, template was only parsed; add " + name + sampleExt,
+				Warning:  true,
+			})
+			continue
+		}
+		tpl := l.set.Lookup(name)
+		c := &fieldChecker{set: l.set, seen: map[string]bool{}}
+		t, v := typeOf(data), reflect.ValueOf(data)
+		c.walk(tpl.Tree, tpl.Root, scope{dot: t, root: t, dotVal: v, rootVal: v})
+		if err := tpl.Execute(io.Discard, data); err != nil {
+			p := execProblem(name, err)
+			// the field checker usually explains the same spot better
+			if !slices.ContainsFunc(c.problems, func(q Problem) bool { return q.Location == p.Location }) {
+				problems = append(problems, p)
+			}
+		}
+		for _, p := range c.problems {
+			p.Template = name
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// execLocRe splits "template: eos/leaf:2:41: executing ..." into location
+// and message.
+var execLocRe = regexp.MustCompile(`^template: (\S+:\d+(?::\d+)?): (.*)$`)
+
+// execProblem turns an execution error into a Problem.
+func execProblem(name string, err error) Problem {
+	msg := err.Error()
+	var ee template.ExecError
+	if errors.As(err, &ee) {
+		msg = ee.Err.Error()
+	}
+	if m := execLocRe.FindStringSubmatch(msg); m != nil {
+		return Problem{Template: name, Location: m[1], Message: m[2]}
+	}
+	return Problem{Template: name, Message: strings.TrimPrefix(msg, "template: ")}
+}
+
+// typeOf is reflect.TypeOf that leaves nil as "unknown".
+func typeOf(v any) reflect.Type {
+	if v == nil {
+		return nil
+	}
+	return reflect.TypeOf(v)
+}
+
+// scope is what dot and $ are at a point of the template. A nil type means
+// unknown (e.g. the result of a function) and switches checking off. The
+// sample values ride along for map-backed samples, whose keys can't be
+// known from the type.
+type scope struct {
+	dot, root reflect.Type
+	dotVal    reflect.Value
+	rootVal   reflect.Value
+}
+
+// fieldChecker walks parse trees and records fields that cannot resolve.
+type fieldChecker struct {
+	set      *template.Template
+	seen     map[string]bool // template+type pairs already checked
+	problems []Problem
+}
+
+func (c *fieldChecker) walk(tree *parse.Tree, node parse.Node, s scope) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(tree, child, s)
+		}
+	case *parse.ActionNode:
+		c.pipe(tree, n.Pipe, s)
+	case *parse.IfNode:
+		c.pipe(tree, n.Pipe, s)
+		c.walk(tree, n.List, s)
+		c.walk(tree, n.ElseList, s)
+	case *parse.WithNode:
+		t, v := c.pipe(tree, n.Pipe, s)
+		inner := s
+		inner.dot, inner.dotVal = t, v
+		c.walk(tree, n.List, inner)
+		c.walk(tree, n.ElseList, s)
+	case *parse.RangeNode:
+		t, v := c.pipe(tree, n.Pipe, s)
+		inner := s
+		inner.dot, inner.dotVal = elemType(t), reflect.Value{}
+		if v.IsValid() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() > 0 {
+			inner.dotVal = v.Index(0)
+		}
+		c.walk(tree, n.List, inner)
+		c.walk(tree, n.ElseList, s)
+	case *parse.TemplateNode:
+		called := c.set.Lookup(n.Name)
+		if called == nil || called.Tree == nil {
+			return // reported when executing
+		}
+		var t reflect.Type
+		var v reflect.Value
+		if n.Pipe != nil {
+			t, v = c.pipe(tree, n.Pipe, s)
+		}
+		key := n.Name + "\x00" + fmt.Sprint(t)
+		if t == nil || c.seen[key] {
+			return
+		}
+		c.seen[key] = true
+		c.walk(called.Tree, called.Root, scope{dot: t, root: t, dotVal: v, rootVal: v})
+	}
+}
+
+// pipe checks every command of a pipeline and returns what it evaluates to
+// when that can be told from the types: a lone field, variable or dot.
+func (c *fieldChecker) pipe(tree *parse.Tree, p *parse.PipeNode, s scope) (reflect.Type, reflect.Value) {
+	if p == nil {
+		return nil, reflect.Value{}
+	}
+	var t reflect.Type
+	var v reflect.Value
+	for _, cmd := range p.Cmds {
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				t, v = c.resolve(tree, a, s.dot, s.dotVal, a.Ident)
+			case *parse.VariableNode:
+				if a.Ident[0] == "$" {
+					t, v = c.resolve(tree, a, s.root, s.rootVal, a.Ident[1:])
+				} else {
+					t, v = nil, reflect.Value{}
+				}
+			case *parse.DotNode:
+				t, v = s.dot, s.dotVal
+			case *parse.PipeNode:
+				c.pipe(tree, a, s)
+				t, v = nil, reflect.Value{}
+			default:
+				t, v = nil, reflect.Value{}
+			}
+		}
+		if len(cmd.Args) != 1 {
+			t, v = nil, reflect.Value{}
+		}
+	}
+	if len(p.Cmds) != 1 {
+		return nil, reflect.Value{}
+	}
+	return t, v
+}
+
+// resolve follows fields from t, reporting the first one that does not
+// exist.
+func (c *fieldChecker) resolve(tree *parse.Tree, node parse.Node, t reflect.Type, v reflect.Value, fields []string) (reflect.Type, reflect.Value) {
+	if t == nil {
+		return nil, reflect.Value{}
+	}
+	for i, f := range fields {
+		for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface) {
+			if t.Kind() == reflect.Interface {
+				if !v.IsValid() || v.IsNil() {
+					return nil, reflect.Value{}
+				}
+				v = v.Elem()
+				t = v.Type()
+				continue
+			}
+			if v.IsValid() && !v.IsNil() {
+				v = v.Elem()
+			} else {
+				v = reflect.Value{}
+			}
+			t = t.Elem()
+		}
+		if t == nil {
+			return nil, reflect.Value{}
+		}
+		if m, ok := reflect.PointerTo(t).MethodByName(f); ok && m.Type.NumOut() > 0 {
+			t, v = m.Type.Out(0), reflect.Value{}
+			continue
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			sf, ok := t.FieldByName(f)
+			if ok && sf.IsExported() {
+				if v.IsValid() {
+					v = v.FieldByIndex(sf.Index)
+				}
+				t = sf.Type
+				continue
+			}
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				break
+			}
+			if !v.IsValid() {
+				t = t.Elem()
+				continue
+			}
+			if mv := v.MapIndex(reflect.ValueOf(f)); mv.IsValid() {
+				t, v = mv.Type(), mv
+				continue
+			}
+		}
+		location, _ := tree.ErrorContext(node)
+		c.problems = append(c.problems, Problem{
+			Location: location,
+			Message:  fmt.Sprintf("field .%s is referenced but not provided by %s", strings.Join(fields[:i+1], "."), t),
+		})
+		return nil, reflect.Value{}
+	}
+	return t, v
+}
+
+// elemType is the type dot has inside a range over t.
+func elemType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return t.Elem()
+	case reflect.Int:
+		return t
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package renderer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLintBuiltinsClean(t *testing.T) {
+	problems, err := Lint("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("built-in template: %s", p)
+	}
+}
+
+func TestLintFindsProblems(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		// the branch is never taken for the sample, still reported
+		"features/ospf.tmpl":   "router ospf 1\n{{ if .Multicast }}   router-id {{ .OSPFRouterID }}\n{{ end }}",
+		"eos/leaf.tmpl":        "hostname {{ .Hostname }}\n{{ range .VLANs }}vlan {{ .ID }} state {{ .State }}\n{{ end }}",
+		"greeting.tmpl":        "hello {{ .Name }} from {{ .Site }}",
+		"greeting.sample.json": `{"name": "typo", "Name": "leaf1"}`,
+		"note.tmpl":            "just text",
+	})
+	problems, err := Lint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	all := strings.Join(got, "\n")
+	for _, want := range []string{
+		"features/ospf:2:",
+		"field .OSPFRouterID is referenced but not provided by renderer.FeatureData",
+		"eos/leaf:2:",
+		"field .State is referenced but not provided by devices.VLAN",
+		"greeting:1:26: error: ",
+		"field .Site is referenced but not provided by map[string]interface {}",
+		"note: warning: no sample data",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing %q in\n%s", want, all)
+		}
+	}
+	if !Failed(problems) {
+		t.Error("problems did not fail validation")
+	}
+}
+
+func TestRenderMissingKeyFails(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"greeting.tmpl": "hello {{ .Name }} from {{ .Site }}"})
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := reg.Render("greeting", map[string]any{"Name": "leaf1"})
+	if err == nil {
+		t.Fatalf("rendered %q instead of failing on the missing key", out)
+	}
+}
+
+func TestReloadRejectsInvalidTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"eos/spine.tmpl": "hostname {{ .Hostname }}\n"})
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"eos/spine.tmpl": "hostname {{ .Hostnme }}\n"})
+	err = reg.Reload()
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Problems) == 0 {
+		t.Fatalf("Reload = %v, want a ValidationError", err)
+	}
+	out, err := reg.Render("eos/spine", sampleSpine())
+	if err != nil || string(out) != "hostname spine1\n" {
+		t.Fatalf("previous template not kept: %q, %v", out, err)
+	}
+	if _, err := NewRegistry(dir); err == nil {
+		t.Fatal("NewRegistry accepted an invalid template")
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/renderer"
+)
+
+// Delta returns the config that takes d from the services of from to those
+// of to, as a tree of modes; Flatten gives the commands to push. A nil
+// catalog stands for no services, so Delta(d, c, nil, plan) is the cleanup
+// of everything c put on d.
+func Delta(d devices.Device, from, to *Catalog, plan bgpplan.Plan) (*eosconfig.Node, error) {
+	before, err := render(d, from, plan)
+	if err != nil {
+		return nil, err
+	}
+	after, err := render(d, to, plan)
+	if err != nil {
+		return nil, err
+	}
+	return eosconfig.Diff(before, after), nil
+}
+
+func render(d devices.Device, c *Catalog, plan bgpplan.Plan) (*eosconfig.Node, error) {
+	if c != nil {
+		var err error
+		if d, err = c.Apply(d, plan); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := renderer.RenderConfig(d)
+	if err != nil {
+		return nil, err
+	}
+	return eosconfig.ParseString(string(cfg))
+}
+
+// Change is what one leaf needs to move between two catalogs.
+type Change struct {
+	Leaf     string   `json:"leaf"`
+	Config   string   `json:"config"`   // the delta in running-config layout
+	Commands []string `json:"commands"` // the delta as configure commands
+}
+
+// Changes computes the delta of every leaf either catalog puts services on,
+// in the order of devs, leaving out leaves that need nothing.
+func Changes(devs []devices.Device, from, to *Catalog, plan bgpplan.Plan) ([]Change, error) {
+	var leaves []string
+	for _, c := range []*Catalog{from, to} {
+		if c != nil {
+			leaves = append(leaves, c.Leaves()...)
+		}
+	}
+	var out []Change
+	for _, d := range devs {
+		if !slices.Contains(leaves, d.Hostname) {
+			continue
+		}
+		leaves = slices.DeleteFunc(leaves, func(l string) bool { return l == d.Hostname })
+		delta, err := Delta(d, from, to, plan)
+		if err != nil {
+			return nil, err
+		}
+		if len(delta.Children) == 0 {
+			continue
+		}
+		out = append(out, Change{Leaf: d.Hostname, Config: delta.String(), Commands: delta.Flatten()})
+	}
+	if len(leaves) > 0 {
+		return nil, fmt.Errorf("services on %s, which is not in the inventory", leaves[0])
+	}
+	return out, nil
+}
@@ -0,0 +1,329 @@
+// Package services models the EVPN tenant services of the fabric: L2
+// segments (a VLAN stretched as an L2VNI, with the leaf ports in it),
+// tenant VRFs carried as L3VNIs for symmetric IRB, and anycast gateways on
+// the segments routed in a VRF.
+//
+// A Catalog is laid over the leaves of the inventory with Apply. What a
+// leaf needs to move from one catalog to another is the difference of the
+// two rendered configs (Delta), so removing a service from the catalog
+// gives the config that cleans it up.
+package services
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/netmath"
+)
+
+// DefaultGatewayMAC is the virtual-router MAC when the catalog sets none.
+const DefaultGatewayMAC = "00:1c:73:00:00:01"
+
+// Catalog is a services file.
+type Catalog struct {
+	GatewayMAC string    `yaml:"gatewayMac,omitempty" json:"gatewayMac,omitempty"` // ip virtual-router mac-address
+	VRFs       []VRF     `yaml:"vrfs,omitempty" json:"vrfs,omitempty"`
+	Segments   []Segment `yaml:"segments,omitempty" json:"segments,omitempty"`
+}
+
+// VRF is a tenant routing table. It exists on every leaf that carries one
+// of its segments.
+type VRF struct {
+	Name string `yaml:"name" json:"name"`
+	VNI  int    `yaml:"vni" json:"vni"` // L3VNI
+}
+
+// Segment is a VLAN stretched over EVPN. It is on the leaves listed plus
+// those with one of its ports; with a VRF and Gateway every such leaf also
+// routes it with the same anycast address.
+type Segment struct {
+	Name    string   `yaml:"name" json:"name"`
+	VLAN    int      `yaml:"vlan" json:"vlan"`
+	VNI     int      `yaml:"vni,omitempty" json:"vni,omitempty"` // netmath.VNI(VLAN) when left out
+	VRF     string   `yaml:"vrf,omitempty" json:"vrf,omitempty"`
+	Gateway string   `yaml:"gateway,omitempty" json:"gateway,omitempty"` // "10.20.0.1/24"
+	Leaves  []string `yaml:"leaves,omitempty" json:"leaves,omitempty"`
+	Ports   []string `yaml:"ports,omitempty" json:"ports,omitempty"` // access ports, "leaf1:eth4" or "leaf1:Ethernet4"
+}
+
+// Load reads a catalog in YAML (or JSON), fills in defaults and validates
+// it. Unknown fields are an error.
+func Load(r io.Reader) (*Catalog, error) {
+	var c Catalog
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decode services: %w", err)
+	}
+	c.setDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LoadFile reads the catalog at path. A missing file is an empty catalog,
+// which is where a lab without services starts.
+func LoadFile(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Catalog{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// WriteFile saves c to path, replacing the file atomically.
+func (c *Catalog) WriteFile(path string) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".services-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Catalog) setDefaults() {
+	if c.GatewayMAC == "" {
+		c.GatewayMAC = DefaultGatewayMAC
+	}
+	for i := range c.Segments {
+		if c.Segments[i].VNI == 0 {
+			c.Segments[i].VNI, _ = netmath.VNI(c.Segments[i].VLAN) // range checked by Validate
+		}
+	}
+}
+
+// Validate checks names, VLANs and VNIs for clashes, that routed segments
+// name a known VRF, and that every port is a leaf:interface pair used once.
+func (c *Catalog) Validate() error {
+	if _, err := net.ParseMAC(c.GatewayMAC); err != nil {
+		return fmt.Errorf("gatewayMac: %w", err)
+	}
+	vnis := map[int]string{}
+	vni := func(n int, owner string) error {
+		if n < 1 || n > 1<<24-1 {
+			return fmt.Errorf("%s: VNI %d is out of range", owner, n)
+		}
+		if prev, ok := vnis[n]; ok {
+			return fmt.Errorf("%s: VNI %d is also used by %s", owner, n, prev)
+		}
+		vnis[n] = owner
+		return nil
+	}
+	vrfs := map[string]bool{}
+	for _, v := range c.VRFs {
+		switch {
+		case v.Name == "" || strings.ContainsAny(v.Name, " \t"):
+			return fmt.Errorf("vrf %q: bad name", v.Name)
+		case vrfs[v.Name]:
+			return fmt.Errorf("vrf %s is listed twice", v.Name)
+		}
+		vrfs[v.Name] = true
+		if err := vni(v.VNI, "vrf "+v.Name); err != nil {
+			return err
+		}
+	}
+
+	names, vlans, ports := map[string]bool{}, map[int]string{}, map[string]string{}
+	for _, s := range c.Segments {
+		owner := "segment " + s.Name
+		switch {
+		case s.Name == "" || strings.ContainsAny(s.Name, " \t"):
+			return fmt.Errorf("segment %q: bad name", s.Name)
+		case names[s.Name]:
+			return fmt.Errorf("segment %s is listed twice", s.Name)
+		case s.VLAN < 1 || s.VLAN > 4094:
+			return fmt.Errorf("%s: VLAN %d is out of range", owner, s.VLAN)
+		case vlans[s.VLAN] != "":
+			return fmt.Errorf("%s: VLAN %d is also used by segment %s", owner, s.VLAN, vlans[s.VLAN])
+		case s.VRF != "" && !vrfs[s.VRF]:
+			return fmt.Errorf("%s: vrf %s is not in vrfs", owner, s.VRF)
+		case s.Gateway != "" && s.VRF == "":
+			return fmt.Errorf("%s: a gateway needs a vrf", owner)
+		case len(s.Leaves) == 0 && len(s.Ports) == 0:
+			return fmt.Errorf("%s is on no leaf; give it leaves or ports", owner)
+		}
+		names[s.Name], vlans[s.VLAN] = true, s.Name
+		if err := vni(s.VNI, owner); err != nil {
+			return err
+		}
+		if s.Gateway != "" {
+			if p, err := netip.ParsePrefix(s.Gateway); err != nil || p.Addr() == p.Masked().Addr() {
+				return fmt.Errorf("%s: gateway %q is not a host address with its prefix length", owner, s.Gateway)
+			}
+		}
+		for _, p := range s.Ports {
+			leaf, intf, ok := port(p)
+			if !ok {
+				return fmt.Errorf("%s: port %q is not leaf:interface", owner, p)
+			}
+			key := leaf + ":" + intf
+			if prev, ok := ports[key]; ok {
+				return fmt.Errorf("%s: port %s is also in segment %s", owner, key, prev)
+			}
+			ports[key] = s.Name
+		}
+	}
+	return nil
+}
+
+// port splits "leaf1:eth4" into the leaf and the EOS interface name.
+func port(p string) (leaf, intf string, ok bool) {
+	leaf, intf, ok = strings.Cut(p, ":")
+	if !ok || leaf == "" || intf == "" {
+		return "", "", false
+	}
+	return leaf, netmath.EOSInterface(intf), true
+}
+
+// on reports whether s is on leaf.
+func (s Segment) on(leaf string) bool {
+	if slices.Contains(s.Leaves, leaf) {
+		return true
+	}
+	for _, p := range s.Ports {
+		if l, _, _ := port(p); l == leaf {
+			return true
+		}
+	}
+	return false
+}
+
+// Leaves lists the leaves the catalog puts services on, sorted.
+func (c *Catalog) Leaves() []string {
+	var out []string
+	for _, s := range c.Segments {
+		out = append(out, s.Leaves...)
+		for _, p := range s.Ports {
+			if l, _, ok := port(p); ok {
+				out = append(out, l)
+			}
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+func (c *Catalog) vrf(name string) VRF {
+	for _, v := range c.VRFs {
+		if v.Name == name {
+			return v
+		}
+	}
+	return VRF{}
+}
+
+// Apply returns d with the services of the catalog that are on it: the
+// segments' VLANs with RDs and RTs from plan, their access ports, the VRFs
+// they are routed in and the anycast gateway SVIs. A VLAN or port d
+// already has is taken over by the catalog. d itself is not modified.
+func (c *Catalog) Apply(d devices.Device, plan bgpplan.Plan) (devices.Device, error) {
+	var segs []Segment
+	for _, s := range c.Segments {
+		if s.on(d.Hostname) {
+			segs = append(segs, s)
+		}
+	}
+	if len(segs) == 0 {
+		return d, nil
+	}
+	switch {
+	case d.Role != "" && d.Role != "leaf":
+		return d, fmt.Errorf("%s is a %s, services only go on leaves", d.Hostname, d.Role)
+	case d.BGP == nil:
+		return d, fmt.Errorf("%s runs no BGP to advertise services with", d.Hostname)
+	case d.VXLAN == nil:
+		return d, fmt.Errorf("%s has no Vxlan1 interface", d.Hostname)
+	}
+	d.Interfaces = slices.Clone(d.Interfaces)
+	d.VLANs = slices.Clone(d.VLANs)
+	d.VRFs = slices.Clone(d.VRFs)
+	setInterface := func(i devices.Interface) {
+		at := slices.IndexFunc(d.Interfaces, func(x devices.Interface) bool { return x.Name == i.Name })
+		if at < 0 {
+			d.Interfaces = append(d.Interfaces, i)
+		} else {
+			d.Interfaces[at] = i
+		}
+	}
+
+	for _, s := range segs {
+		rd, err := plan.RD(d.BGP.RouterID, d.BGP.ASN, s.VLAN, s.VNI)
+		if err != nil {
+			return d, fmt.Errorf("%s segment %s: %w", d.Hostname, s.Name, err)
+		}
+		rt, err := plan.RouteTarget(s.VLAN, s.VNI)
+		if err != nil {
+			return d, fmt.Errorf("%s segment %s: %w", d.Hostname, s.Name, err)
+		}
+		d.VLANs = slices.DeleteFunc(d.VLANs, func(v devices.VLAN) bool { return v.ID == s.VLAN })
+		d.VLANs = append(d.VLANs, devices.VLAN{ID: s.VLAN, Name: s.Name, VNI: s.VNI, RD: rd, RouteTarget: rt})
+
+		for _, p := range s.Ports {
+			leaf, intf, _ := port(p)
+			if leaf != d.Hostname {
+				continue
+			}
+			for _, i := range d.Interfaces {
+				if i.Name == intf && i.Address != "" {
+					return d, fmt.Errorf("%s %s is a routed port, it can't join segment %s", d.Hostname, intf, s.Name)
+				}
+			}
+			setInterface(devices.Interface{Name: intf, VLAN: s.VLAN, PortFast: true})
+		}
+
+		if s.VRF == "" {
+			continue
+		}
+		if !slices.ContainsFunc(d.VRFs, func(v devices.VRF) bool { return v.Name == s.VRF }) {
+			v := c.vrf(s.VRF)
+			// the L3VNI stands in for the VLAN in <router-id>:<vlan> RDs
+			rd, err := plan.RD(d.BGP.RouterID, d.BGP.ASN, v.VNI, v.VNI)
+			if err != nil {
+				return d, fmt.Errorf("%s vrf %s: %w", d.Hostname, v.Name, err)
+			}
+			rt, err := plan.RouteTarget(v.VNI, v.VNI)
+			if err != nil {
+				return d, fmt.Errorf("%s vrf %s: %w", d.Hostname, v.Name, err)
+			}
+			d.VRFs = append(d.VRFs, devices.VRF{Name: v.Name, VNI: v.VNI, RD: rd, RouteTarget: rt})
+		}
+		if s.Gateway != "" {
+			setInterface(devices.Interface{Name: "Vlan" + strconv.Itoa(s.VLAN), Gateway: s.Gateway, VRF: s.VRF})
+			d.VirtualRouterMAC = c.GatewayMAC
+		}
+	}
+	// the order a config lists them in, so Apply and the configs agree
+	slices.SortFunc(d.VLANs, func(a, b devices.VLAN) int { return a.ID - b.ID })
+	return d, nil
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/montybeatnik/arista-lab/laber/pkgs/bgpplan"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/devices"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eosconfig"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/eoslint"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/inventory"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/renderer"
+)
+
+const tenants = `
+vrfs: [{name: GPU, vni: 50001}]
+segments:
+  - {name: gpu, vlan: 10, vrf: GPU, gateway: 10.10.10.1/24, ports: [leaf1:eth3, leaf2:eth3]}
+  - {name: storage, vlan: 20, leaves: [leaf3], ports: [leaf1:Ethernet4]}
+`
+
+func load(t *testing.T, doc string) *Catalog {
+	t.Helper()
+	c, err := Load(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func labDevices(t *testing.T) []devices.Device {
+	t.Helper()
+	devs, err := devices.LoadFile(filepath.Join("..", "..", "..", "inventory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return devs
+}
+
+func leaf(t *testing.T, name string) devices.Device {
+	t.Helper()
+	for _, d := range labDevices(t) {
+		if d.Hostname == name {
+			return d
+		}
+	}
+	t.Fatalf("no %s in the inventory", name)
+	return devices.Device{}
+}
+
+func TestApply(t *testing.T) {
+	c := load(t, tenants)
+	base := leaf(t, "leaf1")
+	d, err := c.Apply(base, bgpplan.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(base.VLANs) != 1 || len(base.Interfaces) != 3 {
+		t.Error("Apply modified the device it was given")
+	}
+	wantVLANs := []devices.VLAN{
+		{ID: 10, Name: "gpu", VNI: 1010, RD: "10.0.0.11:10", RouteTarget: "65000:1010"},
+		{ID: 20, Name: "storage", VNI: 1020, RD: "10.0.0.11:20", RouteTarget: "65000:1020"},
+	}
+	if !reflect.DeepEqual(d.VLANs, wantVLANs) {
+		t.Errorf("vlans = %+v", d.VLANs)
+	}
+	if want := []devices.VRF{{Name: "GPU", VNI: 50001, RD: "10.0.0.11:50001", RouteTarget: "65000:50001"}}; !reflect.DeepEqual(d.VRFs, want) {
+		t.Errorf("vrfs = %+v", d.VRFs)
+	}
+	wantIntfs := append(slices.Clone(base.Interfaces),
+		devices.Interface{Name: "Vlan10", Gateway: "10.10.10.1/24", VRF: "GPU"},
+		devices.Interface{Name: "Ethernet4", VLAN: 20, PortFast: true})
+	if !reflect.DeepEqual(d.Interfaces, wantIntfs) || d.VirtualRouterMAC != DefaultGatewayMAC {
+		t.Errorf("interfaces = %+v, mac %q", d.Interfaces, d.VirtualRouterMAC)
+	}
+
+	// the rendered config reads back to the same device
+	cfg, err := renderer.RenderConfig(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := eosconfig.ParseString(string(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := inventory.DeviceFromConfig(root)
+	got.Role = d.Role
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("config reads back as\n%+v\nwant\n%+v", got, d)
+	}
+
+	leaf3, err := c.Apply(leaf(t, "leaf3"), bgpplan.Default())
+	if err != nil || len(leaf3.VRFs) != 0 || len(leaf3.VLANs) != 2 || leaf3.VirtualRouterMAC != "" {
+		t.Errorf("L2-only leaf3 = %+v, %v", leaf3, err)
+	}
+	leaf4 := leaf(t, "leaf4")
+	if got, err := c.Apply(leaf4, bgpplan.Default()); err != nil || !reflect.DeepEqual(got, leaf4) {
+		t.Errorf("leaf4 carries no service but changed (err %v)", err)
+	}
+}
+
+func TestAppliedConfigsLintClean(t *testing.T) {
+	c := load(t, tenants)
+	dir := t.TempDir()
+	for _, d := range labDevices(t) {
+		if d.Role != "leaf" && d.Role != "spine" {
+			continue
+		}
+		d, err := c.Apply(d, bgpplan.Default())
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := renderer.RenderConfig(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, d.Hostname+".cfg"), cfg, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgs, err := eoslint.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range eoslint.Lint(cfgs) {
+		t.Error(f)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	for doc, want := range map[string]string{
+		"{segments: [{name: s, vlan: 20, ports: [leaf1:eth1]}]}": "leaf1 Ethernet1 is a routed port",
+		"{segments: [{name: s, vlan: 20, leaves: [spine1]}]}":    "spine1 is a spine",
+	} {
+		c := load(t, doc)
+		_, err := Changes(labDevices(t), nil, c, bgpplan.Default())
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", doc, err, want)
+		}
+	}
+	c := load(t, "{segments: [{name: s, vlan: 20, leaves: [leaf9]}]}")
+	if _, err := Changes(labDevices(t), nil, c, bgpplan.Default()); err == nil || !strings.Contains(err.Error(), "leaf9, which is not in the inventory") {
+		t.Errorf("unknown leaf: err = %v", err)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	c := load(t, tenants)
+	devs := labDevices(t)
+	up, err := Changes(devs, nil, c, bgpplan.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaves []string
+	for _, ch := range up {
+		leaves = append(leaves, ch.Leaf)
+	}
+	if strings.Join(leaves, ",") != "leaf1,leaf2,leaf3" {
+		t.Fatalf("changed leaves %v", leaves)
+	}
+	for _, want := range []string{
+		"vrf instance GPU",
+		"interface Vlan10\n   vrf GPU\n   ip address virtual 10.10.10.1/24\n",
+		"interface Vxlan1\n   vxlan vlan 20 vni 1020\n   vxlan vrf GPU vni 50001\n",
+		"ip virtual-router mac-address 00:1c:73:00:00:01\nip routing vrf GPU\n",
+		"   vrf GPU\n      rd 10.0.0.11:50001\n      route-target import evpn 65000:50001\n",
+	} {
+		if !strings.Contains(up[0].Config, want) {
+			t.Errorf("leaf1 delta lacks %q:\n%s", want, up[0].Config)
+		}
+	}
+	if strings.Contains(up[1].Config, "interface Ethernet3") {
+		t.Errorf("leaf2 gpu port is already in vlan 10:\n%s", up[1].Config)
+	}
+
+	// dropping the storage segment cleans up after it, and only it
+	next := load(t, tenants)
+	next.Segments = next.Segments[:1]
+	down, err := Changes(devs, c, next, bgpplan.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ch := range down {
+		got = append(got, ch.Leaf+": "+strings.Join(ch.Commands, "; "))
+	}
+	want := []string{
+		"leaf1: no vlan 20; default interface Ethernet4; interface Vxlan1; no vxlan vlan 20 vni 1020; exit; router bgp 65101; no vlan 20; exit",
+		"leaf3: no vlan 20; interface Vxlan1; no vxlan vlan 20 vni 1020; exit; router bgp 65103; no vlan 20; exit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cleanup:\n%s", strings.Join(got, "\n"))
+	}
+
+	// everything goes: nothing of the tenant is left
+	teardown, err := Changes(devs, c, nil, bgpplan.Default())
+	if err != nil || len(teardown) != 3 {
+		t.Fatalf("teardown %d leaves, %v", len(teardown), err)
+	}
+	for _, want := range []string{"no vrf instance GPU", "no interface Vlan10", "no ip routing vrf GPU", "   no vrf GPU"} {
+		if !strings.Contains(teardown[1].Config, want) {
+			t.Errorf("leaf2 teardown lacks %q:\n%s", want, teardown[1].Config)
+		}
+	}
+	if again, _ := Changes(devs, c, c, bgpplan.Default()); len(again) != 0 {
+		t.Errorf("unchanged catalog gives %+v", again)
+	}
+}
+
+// Moving a segment to another VRF removes the old tenant VRF and gives the
+// SVI its gateway again, which EOS drops with the VRF change.
+func TestChangesVRFSwap(t *testing.T) {
+	c := load(t, tenants)
+	next := load(t, strings.NewReplacer("name: GPU, vni: 50001", "name: STORAGE, vni: 50002", "vrf: GPU", "vrf: STORAGE").Replace(tenants))
+	changes, err := Changes(labDevices(t), c, next, bgpplan.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) == 0 || changes[0].Leaf != "leaf1" {
+		t.Fatalf("changes %+v", changes)
+	}
+	for _, want := range []string{
+		"no vrf instance GPU\n",
+		"vrf instance STORAGE\n",
+		"interface Vlan10\n   vrf STORAGE\n   ip address virtual 10.10.10.1/24\n",
+		"   no vrf GPU\n",
+	} {
+		if !strings.Contains(changes[0].Config, want) {
+			t.Errorf("leaf1 delta lacks %q:\n%s", want, changes[0].Config)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for doc, want := range map[string]string{
+		"{segment: []}":       "field segment not found",
+		"{gatewayMac: 00:1c}": "gatewayMac",
+		"{vrfs: [{name: A, vni: 5}, {name: A, vni: 6}]}":                                                          "vrf A is listed twice",
+		"{vrfs: [{name: A, vni: 0}]}":                                                                             "VNI 0 is out of range",
+		"{segments: [{name: s, vlan: 5000, leaves: [leaf1]}]}":                                                    "VLAN 5000 is out of range",
+		"{segments: [{name: s, vlan: 10, leaves: [l]}, {name: t, vlan: 10, leaves: [l]}]}":                        "also used by segment s",
+		"{vrfs: [{name: A, vni: 1010}], segments: [{name: s, vlan: 10, leaves: [l]}]}":                            "VNI 1010 is also used by vrf A",
+		"{segments: [{name: s, vlan: 10, vrf: B, leaves: [l]}]}":                                                  "vrf B is not in vrfs",
+		"{segments: [{name: s, vlan: 10, gateway: 10.1.0.1/24, leaves: [l]}]}":                                    "needs a vrf",
+		"{vrfs: [{name: A, vni: 5}], segments: [{name: s, vlan: 10, vrf: A, gateway: 10.1.0.0/24, leaves: [l]}]}": "not a host address",
+		"{segments: [{name: s, vlan: 10}]}":                                                                       "on no leaf",
+		"{segments: [{name: s, vlan: 10, ports: [eth3]}]}":                                                        "not leaf:interface",
+		"{segments: [{name: s, vlan: 10, ports: [l:eth3]}, {name: t, vlan: 11, ports: [l:Ethernet3]}]}":           "port l:Ethernet3 is also in segment s",
+	} {
+		if _, err := Load(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", doc, err, want)
+		}
+	}
+}
+
+func TestWriteAndLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.state.yml")
+	empty, err := LoadFile(path)
+	if err != nil || len(empty.Segments) != 0 {
+		t.Fatalf("missing file = %+v, %v", empty, err)
+	}
+	c := load(t, tenants)
+	if err := c.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	back, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, c) {
+		t.Errorf("reloaded %+v, want %+v", back, c)
+	}
+}
@@ -0,0 +1,152 @@
+// Package textdiff produces line-based unified diffs, as used for golden
+// files and for comparing rendered configs with running ones.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the kind of an Edit.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Edit is one line of a diff. ALine and BLine are 1-based line numbers in
+// the old and new text; the one that does not apply is 0.
+type Edit struct {
+	Op    Op
+	Line  string
+	ALine int
+	BLine int
+}
+
+// lines splits s into lines without their terminators; a trailing newline
+// does not start another line.
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Lines computes a shortest line edit script turning a into b.
+func Lines(a, b string) []Edit {
+	return diff(lines(a), lines(b))
+}
+
+// diff is the textbook LCS table; configs are a few hundred lines, so the
+// quadratic memory is fine.
+func diff(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var edits []Edit
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			edits = append(edits, Edit{Op: Equal, Line: a[i], ALine: i + 1, BLine: j + 1})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			// deletions first, the way diff(1) prints them
+			edits = append(edits, Edit{Op: Delete, Line: a[i], ALine: i + 1})
+			i++
+		default:
+			edits = append(edits, Edit{Op: Insert, Line: b[j], BLine: j + 1})
+			j++
+		}
+	}
+	return edits
+}
+
+// Unified returns a unified diff of a and b with context lines around each
+// change, or "" when they are equal.
+func Unified(aName, bName, a, b string, context int) string {
+	edits := Lines(a, b)
+	var out strings.Builder
+	for start := 0; start < len(edits); {
+		// find the next change
+		for start < len(edits) && edits[start].Op == Equal {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		lo := max(start-context, 0)
+		// extend the hunk while changes are within 2*context of each other
+		hi, lastChange := start, start
+		for hi < len(edits) && hi-lastChange <= 2*context {
+			if edits[hi].Op != Equal {
+				lastChange = hi
+			}
+			hi++
+		}
+		hi = min(lastChange+context+1, len(edits))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		writeHunk(&out, edits, lo, hi)
+		start = hi
+	}
+	return out.String()
+}
+
+// writeHunk writes edits[lo:hi] as one hunk.
+func writeHunk(out *strings.Builder, edits []Edit, lo, hi int) {
+	aStart, bStart := 1, 1
+	for _, e := range edits[:lo] {
+		if e.Op != Insert {
+			aStart++
+		}
+		if e.Op != Delete {
+			bStart++
+		}
+	}
+	aLen, bLen := 0, 0
+	for _, e := range edits[lo:hi] {
+		if e.Op != Insert {
+			aLen++
+		}
+		if e.Op != Delete {
+			bLen++
+		}
+	}
+	// an empty side is addressed by the line before it
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, e := range edits[lo:hi] {
+		out.WriteByte(byte(e.Op))
+		out.WriteString(e.Line)
+		out.WriteByte('\n')
+	}
+}
+
+func hunkRange(start, n int) string {
+	if n == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
@@ -0,0 +1,70 @@
+package textdiff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{
+			"change in the middle",
+			"hostname leaf1\n!\nvlan 10\n!\nip routing\n",
+			"hostname leaf1\n!\nvlan 20\n!\nip routing\n",
+			"--- a\n+++ b\n@@ -2,3 +2,3 @@\n !\n-vlan 10\n+vlan 20\n !\n",
+		},
+		{
+			"insert at end",
+			"a\nb\n",
+			"a\nb\nc\n",
+			"--- a\n+++ b\n@@ -2 +2,2 @@\n b\n+c\n",
+		},
+		{
+			"from empty",
+			"",
+			"a\n",
+			"--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"0\n2\n3\n4\n5\n6\n7\n8\n",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+0\n 2\n@@ -8,2 +8 @@\n 8\n-9\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := Unified("a", "b", tt.a, tt.b, 1); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestUnifiedPatchApplies checks the hunks against patch(1) when it is
+// available.
+func TestUnifiedPatchApplies(t *testing.T) {
+	if _, err := exec.LookPath("patch"); err != nil {
+		t.Skip("patch not installed")
+	}
+	a := "hostname leaf1\n!\nvlan 10\n!\ninterface Ethernet1\n   no switchport\n!\nip routing\n!\nend\n"
+	b := "hostname leaf1\n!\nvlan 10\nvlan 20\n!\ninterface Ethernet1\n   mtu 9214\n   no switchport\n!\nend\n"
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cfg")
+	if err := os.WriteFile(file, []byte(a), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("patch", "-s", file)
+	cmd.Stdin = strings.NewReader(Unified("cfg", "cfg", a, b, 2))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("patch: %v\n%s", err, out)
+	}
+	got, _ := os.ReadFile(file)
+	if string(got) != b {
+		t.Fatalf("patched file:\n%s", got)
+	}
+}
@@ -2,20 +2,30 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
+
 	"github.com/montybeatnik/arista-lab/laber/pkgs/arista"
+	"github.com/montybeatnik/arista-lab/laber/pkgs/logging"
 )
 
 func main() {
-	url := "https://172.20.20.9/command-api"
-	client := arista.NewEosClient(url)
-	bgpEvpnSummaryResp, err := client.BGPSummary()
+	if err := logging.Init(os.Stderr, "text", os.Getenv("LOG_LEVEL")); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	ip := "172.20.20.9"
+	log := slog.Default().With("mgmt_ip", ip)
+	// eAPI first, SSH CLI when the node refuses eAPI
+	client := arista.WithLogging(arista.NewClient(ip, "admin", "admin"), log)
+	bgpEvpnSummaryResp, err := arista.BGPSummary(client)
 	if err != nil {
-		fmt.Printf("Run failed: %v\n", err)
+		log.Error("bgp summary failed", "err", err)
 	}
 	fmt.Println(bgpEvpnSummaryResp)
-	ver, err := client.Version()
+	ver, err := arista.Version(client)
 	if err != nil {
-		fmt.Printf("Run failed: %v\n", err)
+		log.Error("show version failed", "err", err)
 	}
 	fmt.Println(ver)
-}
\ No newline at end of file
+}
@@ -13,7 +13,13 @@
             const body = {
                 lab: $('lab').value.trim(),
                 timeoutSec: parseInt($('timeout').value, 10) || 15,
-                sudo: $('sudo').checked
+                sudo: $('sudo').checked,
+                bootstrap: $('bootstrap').checked,
+                bootstrapVrf: $('bootstrapVrf').value.trim(),
+                inventory: $('inventory').value.trim(),
+                selector: $('selector').value.trim(),
+                user: $('euser').value,
+                pass: $('epass').value
             };
             const res = await fetch('/inspect', {
                 method: 'POST',
@@ -34,6 +40,11 @@
             <td>${n.ipv4_address || n.ipv4 || ''}</td><td>${n.owner || ''}</td>`;
                 tbody.appendChild(tr);
             });
+            const boots = data.bootstrap || [];
+            $('bootstrapResults').textContent = boots.map(b =>
+                `${b.name}: ${b.error ? 'FAIL ' + b.error : (b.result.alreadyEnabled ? 'already enabled' : 'enabled eAPI')}` +
+                (b.result.userCreated ? ', created API user' : '')).join('\n');
+            $('bootstrapOut').hidden = boots.length === 0;
             $('rawJson').textContent = JSON.stringify(data.rawJson ?? {}, null, 2);
             out.hidden = false;
         } catch (err) {
@@ -65,11 +76,14 @@
         const user = $('euser').value;
         const pass = $('epass').value;
         const format = $('fmt').value;
+        const transport = $('transport').value;
         const cmds = splitCmds($('cmds').value);
+        const inventory = $('inventory').value.trim();
+        const selector = $('selector').value.trim();
 
         const res = await fetch('/run-cmds', {
             method: 'POST', headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ lab, sudo, timeoutSec, user, pass, format, cmds })
+            body: JSON.stringify({ lab, sudo, timeoutSec, user, pass, format, transport, cmds, inventory, selector })
         });
         const data = await res.json().catch(() => ({ ok: false, error: 'bad json' }));
         if (!res.ok || !data.ok) {
@@ -83,8 +97,8 @@
             let bodyPretty = '';
             try { bodyPretty = JSON.stringify(JSON.parse(r.body || '{}'), null, 2) } catch { bodyPretty = (r.body || '') + ''; }
             pre.textContent = [
-                `${r.name} (${r.ip}) [${r.kind}]`,
-                `OK=${r.ok} status=${r.status}${r.error ? ' error=' + r.error : ''}`,
+                `${r.name} (${r.ip || 'no mgmt ip'}) [${r.kind} via ${r.transport}]`,
+                `OK=${r.ok}${r.error ? ' error=' + r.error : ''}`,
                 bodyPretty
             ].join('\n');
             div.appendChild(pre);
@@ -100,10 +114,14 @@
         const timeoutSec = parseInt($('timeout').value, 10) || 20;
         const user = $('euser').value;
         const pass = $('epass').value;
+        const bootstrap = $('bootstrap').checked;
+        const bootstrapVrf = $('bootstrapVrf').value.trim();
+        const inventory = $('inventory').value.trim();
+        const selector = $('selector').value.trim();
 
         const res = await fetch('/health', {
             method: 'POST', headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ lab, sudo, timeoutSec, user, pass })
+            body: JSON.stringify({ lab, sudo, timeoutSec, user, pass, bootstrap, bootstrapVrf, inventory, selector })
         });
         const data = await res.json().catch(() => ({ ok: false, error: 'bad json' }));
         if (!res.ok || !data.ok) {
@@ -121,8 +139,94 @@
         out.hidden = false;
     }
 
+    // --- render preview ---
+    function diffBlock(title, diff, err) {
+        const d = document.createElement('details');
+        d.open = !!diff;
+        const s = document.createElement('summary');
+        s.textContent = err ? `${title}: ${err}` : (diff ? title : `${title}: no differences`);
+        d.appendChild(s);
+        const pre = document.createElement('pre');
+        (diff || '').split('\n').forEach(line => {
+            const span = document.createElement('span');
+            if (line.startsWith('+') && !line.startsWith('+++')) span.className = 'diff-add';
+            else if (line.startsWith('-') && !line.startsWith('---')) span.className = 'diff-del';
+            else if (line.startsWith('@@')) span.className = 'muted';
+            span.textContent = line + '\n';
+            pre.appendChild(span);
+        });
+        d.appendChild(pre);
+        return d;
+    }
+
+    async function loadPreviewTemplates() {
+        const res = await fetch('/preview').catch(() => null);
+        if (!res || !res.ok) return;
+        const data = await res.json().catch(() => ({}));
+        const sel = $('ptemplate');
+        sel.innerHTML = '';
+        (data.templates || ['config']).forEach(t => {
+            const o = document.createElement('option');
+            o.value = t; o.textContent = t;
+            sel.appendChild(o);
+        });
+    }
+
+    async function onPreview(e) {
+        e.preventDefault();
+        const role = $('prole').value;
+        const body = {
+            lab: $('lab').value.trim(),
+            sudo: $('sudo').checked,
+            timeoutSec: parseInt($('timeout').value, 10) || 15,
+            user: $('euser').value,
+            pass: $('epass').value,
+            transport: $('transport').value,
+            inventory: $('inventory').value.trim(),
+            device: role ? '' : $('pdevice').value.trim(),
+            role,
+            template: $('ptemplate').value,
+            live: $('plive').checked
+        };
+        const res = await fetch('/preview', {
+            method: 'POST', headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body)
+        });
+        const data = await res.json().catch(() => ({ ok: false, error: 'bad json' }));
+        if (!res.ok || !data.ok) {
+            alert('Preview error: ' + (data.error || res.statusText)); return;
+        }
+
+        const div = $('previewResults');
+        div.innerHTML = '';
+        (data.results || []).forEach(r => {
+            const s = document.createElement('div');
+            const h = document.createElement('h4');
+            h.textContent = r.name;
+            s.appendChild(h);
+            if (r.error) {
+                const p = document.createElement('pre');
+                p.textContent = 'error: ' + r.error;
+                s.appendChild(p);
+                div.appendChild(s);
+                return;
+            }
+            const pre = document.createElement('pre');
+            pre.textContent = r.rendered;
+            s.appendChild(pre);
+            s.appendChild(diffBlock(`vs ${r.startupFile}`, r.startupDiff, r.startupError));
+            if (r.startupRemediation) s.appendChild(diffBlock(`commands from ${r.startupFile}`, r.startupRemediation.join('\n')));
+            if (body.live) s.appendChild(diffBlock('vs running-config', r.runningDiff, r.runningError));
+            if (r.runningRemediation) s.appendChild(diffBlock('commands from running-config', r.runningRemediation.join('\n')));
+            div.appendChild(s);
+        });
+        $('previewOut').hidden = false;
+    }
+
     document.addEventListener('DOMContentLoaded', () => {
         $('execForm').addEventListener('submit', onRunCmds);
         $('healthForm').addEventListener('submit', onHealth);
+        $('previewForm').addEventListener('submit', onPreview);
+        loadPreviewTemplates();
     });
 })();
\ No newline at end of file